@@ -15,23 +15,29 @@
 package common
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/webankfintech/dockin-opagent/internal/config"
 	"github.com/webankfintech/dockin-opagent/internal/log"
-	"github.com/webankfintech/dockin-opagent/internal/utils/aes"
 
 	jsoniter "github.com/json-iterator/go"
 )
 
 var (
 	tokenHeaderKey = "access-token"
-	aesKey         = "doc-opserver8085"
 	user           = "dockin-opagent"
+	timeLayout     = time.RFC3339
 
 	ErrNoAccessToken = errors.New("no access token found")
-	ErrCreateSig     = errors.New("create encrypt sig err")
+	ErrMalformed     = errors.New("access token is malformed")
+	ErrUnknownKey    = errors.New("access token is signed by an unknown or retired key")
+	ErrSignature     = errors.New("access token signature is invalid")
 	ErrUnmarshal     = errors.New("err in unmarshal the token")
 	ErrTokenExpired  = errors.New("access token is expired")
 	ErrInvalidUser   = errors.New("invalid user identity")
@@ -44,58 +50,84 @@ type Identity struct {
 }
 
 func ValidateRequest(req *http.Request, uid string) error {
-	token := req.Header.Get(tokenHeaderKey)
+	return validateToken(req.Header.Get(tokenHeaderKey), uid)
+}
+
+func ValidateRequestV2(req *http.Request, uid string) error {
+	req.ParseForm()
+	return validateToken(req.Form.Get(tokenHeaderKey), uid)
+}
+
+func validateToken(token, uid string) error {
 	if token == "" {
 		return ErrNoAccessToken
 	}
-	aes, err := aes.NewAes(aesKey)
-	if err != nil {
-		return ErrCreateSig
-	}
 
-	out, err := aes.AesDecrypt(token)
 	id := &Identity{}
-	if err := jsoniter.Unmarshal([]byte(out), id); err != nil {
-		log.Logger.Warnf("failed to unmarshal token, err=%v, uid=%s", err, uid)
-		return ErrUnmarshal
+	if err := verifyToken(token, id, time.Now()); err != nil {
+		log.Logger.Warnf("failed to verify token, err=%v, uid=%s", err, uid)
+		return err
 	}
 	if id.UserName != user {
 		log.Logger.Warnf("invalid user name=%s, uid=%s", id.UserName, uid)
 		return ErrInvalidUser
 	}
 	if int64(time.Now().Sub(id.CreateTime).Seconds()) > id.Expire {
-		log.Logger.Warnf("access token is expired, create at %v, uid=%s", id.CreateTime, token)
+		log.Logger.Warnf("access token is expired, create at %v, uid=%s", id.CreateTime, uid)
 		return ErrTokenExpired
 	}
 
 	return nil
 }
 
-func ValidateRequestV2(req *http.Request, uid string) error {
-	req.ParseForm()
-	token := req.Form.Get(tokenHeaderKey)
-	if token == "" {
-		return ErrNoAccessToken
+// verifyToken checks tokens signed by opserver, the keys in app.token.keys
+// must be the same as the token keys of opserver.
+func verifyToken(token string, id *Identity, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
 	}
-	aes, err := aes.NewAes(aesKey)
+
+	secret, err := lookupKey(parts[0], now)
 	if err != nil {
-		return ErrCreateSig
+		return err
 	}
 
-	out, err := aes.AesDecrypt(token)
-	id := &Identity{}
-	if err := jsoniter.Unmarshal([]byte(out), id); err != nil {
-		log.Logger.Warnf("failed to unmarshal token, err=%v, uid=%s", err, uid)
-		return ErrUnmarshal
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(sig), []byte(parts[2])) {
+		return ErrSignature
 	}
-	if id.UserName != user {
-		log.Logger.Warnf("invalid user name=%s, uid=%s", id.UserName, uid)
-		return ErrInvalidUser
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrMalformed
 	}
-	if int64(time.Now().Sub(id.CreateTime).Seconds()) > id.Expire {
-		log.Logger.Warnf("access token is expired, create at %v, uid=%s", id.CreateTime, token)
-		return ErrTokenExpired
+	if err := jsoniter.Unmarshal(payload, id); err != nil {
+		return ErrUnmarshal
 	}
-
 	return nil
 }
+
+func lookupKey(keyId string, now time.Time) (string, error) {
+	for _, k := range config.AgentConf.App.Token.Keys {
+		if k.Id != keyId || k.Secret == "" {
+			continue
+		}
+		if k.NotBefore != "" {
+			nbf, err := time.Parse(timeLayout, k.NotBefore)
+			if err != nil || now.Before(nbf) {
+				return "", ErrUnknownKey
+			}
+		}
+		if k.NotAfter != "" {
+			naf, err := time.Parse(timeLayout, k.NotAfter)
+			if err != nil || !now.Before(naf) {
+				return "", ErrUnknownKey
+			}
+		}
+		return k.Secret, nil
+	}
+	return "", ErrUnknownKey
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/webankfintech/dockin-opagent/internal/config"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func signToken(keyId, secret string, id *Identity) string {
+	payload, _ := jsoniter.Marshal(id)
+	signed := keyId + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateToken(t *testing.T) {
+	keys := `
+app:
+  token:
+    keys:
+      - id: k1
+        secret: secret-1
+        not-after: 2000-01-01T00:00:00Z
+      - id: k2
+        secret: secret-2
+`
+	assert.NoError(t, yaml.Unmarshal([]byte(keys), config.AgentConf))
+
+	id := &Identity{UserName: user, Expire: 3600, CreateTime: time.Now()}
+	assert.NoError(t, validateToken(signToken("k2", "secret-2", id), "t"))
+	assert.Equal(t, ErrUnknownKey, validateToken(signToken("k1", "secret-1", id), "t"))
+	assert.Equal(t, ErrSignature, validateToken(signToken("k2", "secret-1", id), "t"))
+	assert.Equal(t, ErrMalformed, validateToken("k2.abc", "t"))
+
+	other := &Identity{UserName: "root", Expire: 3600, CreateTime: time.Now()}
+	assert.Equal(t, ErrInvalidUser, validateToken(signToken("k2", "secret-2", other), "t"))
+
+	expired := &Identity{UserName: user, Expire: 60, CreateTime: time.Now().Add(-time.Hour)}
+	assert.Equal(t, ErrTokenExpired, validateToken(signToken("k2", "secret-2", expired), "t"))
+}
@@ -74,6 +74,14 @@ type AgentConfig struct {
 			User         string   `yaml:"user"`
 			Passwd		 string	  `yaml:"passwd"`
 		} `yaml:"kubedebug"`
+		Token struct {
+			Keys []struct {
+				Id        string `yaml:"id"`
+				Secret    string `yaml:"secret"`
+				NotBefore string `yaml:"not-before"`
+				NotAfter  string `yaml:"not-after"`
+			} `yaml:"keys"`
+		} `yaml:"token"`
 	} `yaml:"app"`
 }
 
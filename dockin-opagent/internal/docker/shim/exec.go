@@ -22,6 +22,7 @@ import (
 	"github.com/webankfintech/dockin-opagent/internal/log"
 	dockertypes "github.com/docker/docker/api/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	utilexec "k8s.io/utils/exec"
 )
 
 type NativeExecHandler struct{}
@@ -88,7 +89,10 @@ func (n *NativeExecHandler) ExecInContainer(client libdocker.Interface,
 		}
 		if !inspect.Running {
 			if inspect.ExitCode != 0 {
-				err = fmt.Errorf("err executing in Docker Container: %d", inspect.ExitCode)
+				err = utilexec.CodeExitError{
+					Err:  fmt.Errorf("err executing in Docker Container: %d", inspect.ExitCode),
+					Code: inspect.ExitCode,
+				}
 			}
 			break
 		}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/webankfintech/dockin-opsctl/internal/common/printer"
+	"github.com/webankfintech/dockin-opsctl/internal/log"
+	"github.com/webankfintech/dockin-opsctl/internal/ssh"
+	"github.com/webankfintech/dockin-opsctl/internal/utils"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	approvalLong = `approval requests a time-boxed grant of commands the command policy denies on a pod,
+a second user whose role allows the approve action approves or rejects it. Once approved,
+commands of the requester matching the pattern are allowed on the pod until the grant expires,
+* in the pattern matches any text without shell control characters. A grant of --run-as root
+allows ssh and exec with -s root and a reason on the pod until it expires`
+	approvalExample = `dockin-opsctl approval request web-0 -r default --command "systemctl restart nginx" --duration 30m --reason "incident 42" -u alice -p xxx
+  dockin-opsctl approval request web-0 -r default --run-as root --duration 30m --reason "incident 42" -u alice -p xxx
+  dockin-opsctl approval list --status pending -u bob -p xxx
+  dockin-opsctl approval approve 3f2a9c0b1d5e4c6f8a7b9c0d1e2f3a4b -u bob -p xxx
+  dockin-opsctl approval reject 3f2a9c0b1d5e4c6f8a7b9c0d1e2f3a4b --access-token xxx`
+)
+
+func NewApprovalCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	opt := &ApprovalOption{Duration: "30m"}
+	approvalCmd := &cobra.Command{
+		Use:                   "approval (request|list|approve|reject) [pod|request-id]",
+		DisableFlagsInUseLine: true,
+		Short:                 "request, list, approve or reject command approvals",
+		Long:                  approvalLong,
+		Example:               approvalExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			utils.CheckErr(opt.Complete(configFlags, cmd, args))
+			utils.CheckErr(opt.Validate())
+			utils.CheckErr(opt.Run())
+		},
+	}
+	approvalCmd.Flags().StringVarP(&opt.UserName, "user name", "u", opt.UserName, "pass user name")
+	approvalCmd.Flags().StringVarP(&opt.Password, "password", "p", opt.Password, "pass password")
+	approvalCmd.Flags().StringVarP(&opt.AccessToken, "access-token", "a", opt.AccessToken, "access token, generate from auth command")
+	approvalCmd.Flags().StringVar(&opt.Command, "command", opt.Command, "pattern of the commands to request")
+	approvalCmd.Flags().StringVar(&opt.RunAs, "run-as", opt.RunAs, "run as user to request, only root needs an approval")
+	approvalCmd.Flags().StringVar(&opt.Duration, "duration", opt.Duration, "how long the grant lasts once approved")
+	approvalCmd.Flags().StringVar(&opt.Reason, "reason", opt.Reason, "why the commands are needed")
+	approvalCmd.Flags().StringVar(&opt.Status, "status", opt.Status, "only list requests of the status, one of (pending|approved|rejected|expired)")
+	approvalCmd.Flags().StringVarP(&opt.Output, "output", "o", opt.Output, "output format of list, one of (table|json)")
+	return approvalCmd
+}
+
+type ApprovalOption struct {
+	UserName    string
+	Password    string
+	AccessToken string
+	Rule        string
+	Namespace   string
+
+	Verb     string
+	Target   string
+	Command  string
+	RunAs    string
+	Duration string
+	Reason   string
+	Status   string
+	Output   string
+}
+
+type approvalRequest struct {
+	Id       string
+	User     string
+	Pod      string
+	Pattern  string
+	RunAs    string
+	Reason   string
+	Duration int64
+	Status   string
+	Approver string
+	Created  time.Time
+	Expires  time.Time
+}
+
+func (option *ApprovalOption) Complete(configFlags *genericclioptions.ConfigFlags, cmd *cobra.Command, args []string) error {
+	option.Rule, _ = cmd.Flags().GetString("rule")
+	option.Namespace, _ = cmd.Flags().GetString("namespace")
+	option.Verb = "list"
+	if len(args) > 0 {
+		option.Verb = args[0]
+	}
+	if len(args) > 1 {
+		option.Target = args[1]
+	}
+	return nil
+}
+
+func (option *ApprovalOption) Validate() error {
+	if option.AccessToken == "" && option.UserName == "" {
+		return errors.Errorf("user name or access token must be assigned")
+	}
+	switch option.Verb {
+	case "list":
+		if option.Output != "" && option.Output != "table" && option.Output != "json" {
+			return errors.Errorf("unknown output format %s", option.Output)
+		}
+	case "request":
+		if option.Target == "" || (option.Command == "" && option.RunAs == "") {
+			return errors.Errorf("%s\n%s",
+				"pod and command or run as user must be assigned", "See 'dockin-opsctl approval -h' for help and examples.")
+		}
+		if _, err := time.ParseDuration(option.Duration); err != nil {
+			return errors.Errorf("invalid duration %s", option.Duration)
+		}
+	case "approve", "reject":
+		if option.Target == "" {
+			return errors.Errorf("%s\n%s",
+				"no request id provided", "See 'dockin-opsctl approval -h' for help and examples.")
+		}
+	default:
+		return errors.Errorf("unknown action %s, one of (request|list|approve|reject)", option.Verb)
+	}
+	return nil
+}
+
+func (option *ApprovalOption) Run() error {
+	login := &SSHOption{
+		UserName:    option.UserName,
+		Password:    option.Password,
+		AccessToken: option.AccessToken,
+		Rule:        option.Rule,
+	}
+	if err := login.Login(); err != nil {
+		log.Output(err.Error())
+		return err
+	}
+
+	hder := http.Header{}
+	hder.Set(ssh.DockinAesHeader, login.AccessToken)
+	params := url.Values{}
+	switch option.Verb {
+	case "request":
+		params.Set("pod", option.Target)
+		params.Set("rule", option.Rule)
+		params.Set("namespace", option.Namespace)
+		params.Set("command", option.Command)
+		params.Set("runAs", option.RunAs)
+		params.Set("duration", option.Duration)
+		params.Set("reason", option.Reason)
+		return option.change("ctrl/requestApproval", params, hder)
+	case "approve", "reject":
+		params.Set("id", option.Target)
+		return option.change("ctrl/"+option.Verb, params, hder)
+	}
+
+	params.Set("status", option.Status)
+	data, err := ctrlGet("ctrl/getApprovals", params, hder)
+	if err != nil {
+		return err
+	}
+	if option.Output == "json" {
+		buf := &bytes.Buffer{}
+		json.Indent(buf, data, "", "  ")
+		fmt.Fprintln(os.Stdout, buf.String())
+		return nil
+	}
+	var list []*approvalRequest
+	if err := jsoniter.Unmarshal(data, &list); err != nil {
+		return errors.Errorf("failed to parse approval requests, data=%s", string(data))
+	}
+	printApprovals(os.Stdout, list)
+	return nil
+}
+
+// change calls the api which creates or decides a request and prints it.
+func (option *ApprovalOption) change(cmd string, params url.Values, hder http.Header) error {
+	data, err := ctrlGet(cmd, params, hder)
+	if err != nil {
+		return err
+	}
+	r := &approvalRequest{}
+	if err := jsoniter.Unmarshal(data, r); err != nil {
+		return errors.Errorf("failed to parse approval request, data=%s", string(data))
+	}
+	printApprovals(os.Stdout, []*approvalRequest{r})
+	return nil
+}
+
+func printApprovals(out io.Writer, list []*approvalRequest) {
+	w := printer.GetNewTabWriter(out)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tUSER\tPOD\tCOMMAND\tRUN AS\tDURATION\tSTATUS\tAPPROVER\tCREATED\tEXPIRES\tREASON")
+	for _, r := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Id, r.User, r.Pod, r.Pattern, r.RunAs,
+			time.Duration(r.Duration)*time.Second, r.Status, r.Approver, r.Created.Local().Format("2006-01-02 15:04:05"),
+			r.Expires.Local().Format("2006-01-02 15:04:05"), r.Reason)
+	}
+}
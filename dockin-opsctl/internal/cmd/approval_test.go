@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalValidate(t *testing.T) {
+	op := &ApprovalOption{AccessToken: "token", Verb: "request", Duration: "30m"}
+	assert.Error(t, op.Validate())
+	op.Target, op.Command = "web-0", "systemctl restart nginx"
+	assert.NoError(t, op.Validate())
+	op.Duration = "soon"
+	assert.Error(t, op.Validate())
+	op.Duration, op.Command, op.RunAs = "30m", "", "root"
+	assert.NoError(t, op.Validate())
+
+	op = &ApprovalOption{AccessToken: "token", Verb: "approve"}
+	assert.Error(t, op.Validate())
+	op.Target = "a1"
+	assert.NoError(t, op.Validate())
+	op.Verb = "grant"
+	assert.Error(t, op.Validate())
+}
+
+func TestPrintApprovals(t *testing.T) {
+	out := &bytes.Buffer{}
+	printApprovals(out, []*approvalRequest{
+		{Id: "a1", User: "alice", Pod: "web-0", Pattern: "reboot", Duration: 1800, Status: "approved",
+			Approver: "bob", Created: time.Now(), Expires: time.Now(), Reason: "incident"},
+	})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Regexp(t, `^a1\s+alice\s+web-0\s+reboot\s+30m0s\s+approved\s+bob\s`, lines[1])
+}
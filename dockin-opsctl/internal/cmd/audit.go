@@ -0,0 +1,229 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/webankfintech/dockin-opsctl/internal/common"
+	"github.com/webankfintech/dockin-opsctl/internal/common/printer"
+	"github.com/webankfintech/dockin-opsctl/internal/log"
+	"github.com/webankfintech/dockin-opsctl/internal/ssh"
+	"github.com/webankfintech/dockin-opsctl/internal/utils"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	auditLong = `audit queries the audit events of ssh sessions and exec commands, latest first,
+it requires the access token of an admin`
+	auditExample = `dockin-opsctl audit -u admin -p admin --user alice --since 24h
+  dockin-opsctl audit --access-token xxx --pod web-0 --verdict denied --limit 20
+  dockin-opsctl audit --access-token xxx --since 2021-06-01T00:00:00+08:00 --until 2021-06-02T00:00:00+08:00 -o json`
+)
+
+func NewAuditCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	opt := &AuditOption{Limit: 100}
+	auditCmd := &cobra.Command{
+		Use:                   "audit",
+		DisableFlagsInUseLine: true,
+		Short:                 "query audit events",
+		Long:                  auditLong,
+		Example:               auditExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			utils.CheckErr(opt.Complete(configFlags, cmd, args))
+			utils.CheckErr(opt.Validate())
+			utils.CheckErr(opt.Run())
+		},
+	}
+	auditCmd.Flags().StringVarP(&opt.UserName, "user name", "u", opt.UserName, "pass user name")
+	auditCmd.Flags().StringVarP(&opt.Password, "password", "p", opt.Password, "pass password")
+	auditCmd.Flags().StringVarP(&opt.AccessToken, "access-token", "a", opt.AccessToken, "access token of an admin, generate from auth command")
+	auditCmd.Flags().StringVar(&opt.User, "user", opt.User, "only events of the user")
+	auditCmd.Flags().StringVar(&opt.Pod, "pod", opt.Pod, "only events of the pod")
+	auditCmd.Flags().StringVar(&opt.Subsystem, "subsystem", opt.Subsystem, "only events of the subsystem")
+	auditCmd.Flags().StringVar(&opt.Verdict, "verdict", opt.Verdict, "only events with the verdict, one of (allowed|denied|audited|granted)")
+	auditCmd.Flags().StringVar(&opt.Type, "type", opt.Type, "only events of the type, one of (session-start|session-end|command|command-exit|elevate)")
+	auditCmd.Flags().StringVar(&opt.Since, "since", opt.Since, "only events since the time, a duration such as 1h or an RFC 3339 time")
+	auditCmd.Flags().StringVar(&opt.Until, "until", opt.Until, "only events until the time, a duration such as 1h or an RFC 3339 time")
+	auditCmd.Flags().Int64Var(&opt.Offset, "offset", opt.Offset, "skip the latest events")
+	auditCmd.Flags().Int64Var(&opt.Limit, "limit", opt.Limit, "the maximum number of events")
+	auditCmd.Flags().StringVarP(&opt.Output, "output", "o", opt.Output, "output format, one of (table|json)")
+	return auditCmd
+}
+
+type AuditOption struct {
+	UserName    string
+	Password    string
+	AccessToken string
+	Rule        string
+
+	User      string
+	Pod       string
+	Subsystem string
+	Verdict   string
+	Type      string
+	Since     string
+	Until     string
+	Offset    int64
+	Limit     int64
+	Output    string
+
+	now func() time.Time
+}
+
+type auditEvent struct {
+	Time     time.Time
+	Type     string
+	TraceId  string
+	User     string
+	SourceIP string
+	Pod      string
+	Command  string
+	Verdict  string
+	Reason   string
+	ExitCode *int
+}
+
+type auditResult struct {
+	Code    int
+	Message string
+	Data    jsoniter.RawMessage
+}
+
+func (option *AuditOption) Complete(configFlags *genericclioptions.ConfigFlags, cmd *cobra.Command, args []string) error {
+	option.Rule, _ = cmd.Flags().GetString("rule")
+	if option.now == nil {
+		option.now = time.Now
+	}
+	return nil
+}
+
+func (option *AuditOption) Validate() error {
+	if option.AccessToken == "" && option.UserName == "" {
+		return errors.Errorf("user name or access token must be assigned")
+	}
+	if option.Output != "" && option.Output != "table" && option.Output != "json" {
+		return errors.Errorf("unknown output format %s", option.Output)
+	}
+	for _, t := range []string{option.Since, option.Until} {
+		if _, err := option.parseTime(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (option *AuditOption) Run() error {
+	login := &SSHOption{
+		UserName:    option.UserName,
+		Password:    option.Password,
+		AccessToken: option.AccessToken,
+		Rule:        option.Rule,
+	}
+	if err := login.Login(); err != nil {
+		log.Output(err.Error())
+		return err
+	}
+
+	hder := http.Header{}
+	hder.Set(ssh.DockinAesHeader, login.AccessToken)
+	body, err := utils.HttpGetWithHeader(option.url(), time.Second*30, hder)
+	if err != nil {
+		log.Debugf("failed to query audit events, err=%s", err.Error())
+		return fmt.Errorf("failed to query audit events, try again")
+	}
+	result := &auditResult{}
+	if err := jsoniter.Unmarshal(body, result); err != nil {
+		return errors.Errorf("failed to parse audit events, body=%s", string(body))
+	}
+	if result.Code != 0 {
+		return errors.Errorf("failed to query audit events, errMsg=%s", result.Message)
+	}
+	if option.Output == "json" {
+		buf := &bytes.Buffer{}
+		json.Indent(buf, result.Data, "", "  ")
+		fmt.Fprintln(os.Stdout, buf.String())
+		return nil
+	}
+	var events []*auditEvent
+	if err := jsoniter.Unmarshal(result.Data, &events); err != nil {
+		return errors.Errorf("failed to parse audit events, data=%s", string(result.Data))
+	}
+	printAuditEvents(os.Stdout, events)
+	return nil
+}
+
+func (option *AuditOption) url() string {
+	params := url.Values{}
+	for k, v := range map[string]string{
+		"user":      option.User,
+		"pod":       option.Pod,
+		"subsystem": option.Subsystem,
+		"verdict":   option.Verdict,
+		"type":      option.Type,
+	} {
+		if v != "" {
+			params.Set(k, v)
+		}
+	}
+	for k, v := range map[string]string{"since": option.Since, "until": option.Until} {
+		if t, _ := option.parseTime(v); !t.IsZero() {
+			params.Set(k, strconv.FormatInt(t.Unix(), 10))
+		}
+	}
+	params.Set("offset", strconv.FormatInt(option.Offset, 10))
+	params.Set("limit", strconv.FormatInt(option.Limit, 10))
+	return fmt.Sprintf("%s?%s", common.GetCommonUrlByCmd("ctrl/getAudit"), params.Encode())
+}
+
+// parseTime parses a duration before now, or an RFC 3339 time.
+func (option *AuditOption) parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return option.now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, errors.Errorf("invalid time %s, use a duration such as 1h or an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+func printAuditEvents(out io.Writer, events []*auditEvent) {
+	w := printer.GetNewTabWriter(out)
+	defer w.Flush()
+	fmt.Fprintln(w, "TIME\tTYPE\tUSER\tSOURCE\tPOD\tVERDICT\tEXIT\tCOMMAND\tREASON\tTRACEID")
+	for _, e := range events {
+		exit := "-"
+		if e.ExitCode != nil {
+			exit = strconv.Itoa(*e.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"),
+			e.Type, e.User, e.SourceIP, e.Pod, e.Verdict, exit, e.Command, e.Reason, e.TraceId)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditQuery(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	op := &AuditOption{AccessToken: "token", User: "alice", Verdict: "denied", Since: "1h", Until: "2020-09-13T12:30:00Z", Limit: 20,
+		now: func() time.Time { return now }}
+	assert.NoError(t, op.Validate())
+
+	u, err := url.Parse(op.url())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(u.Path, "ctrl/getAudit"))
+	q := u.Query()
+	assert.Equal(t, "alice", q.Get("user"))
+	assert.Equal(t, "denied", q.Get("verdict"))
+	assert.Equal(t, "1599996400", q.Get("since"))
+	assert.Equal(t, "1600000200", q.Get("until"))
+	assert.Equal(t, "20", q.Get("limit"))
+	assert.Empty(t, q.Get("pod"))
+
+	op.Since = "yesterday"
+	assert.Error(t, op.Validate())
+}
+
+func TestPrintAuditEvents(t *testing.T) {
+	code := 1
+	out := &bytes.Buffer{}
+	printAuditEvents(out, []*auditEvent{
+		{Time: time.Now(), Type: "command", User: "alice", Pod: "web-0", Command: "grep x a.log", Verdict: "allowed", ExitCode: &code},
+		{Time: time.Now(), Type: "session-start", User: "bob", Verdict: "denied", Reason: "not allowed"},
+	})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "grep x a.log")
+	assert.Regexp(t, `allowed\s+1\s`, lines[1])
+	assert.Regexp(t, `denied\s+-\s`, lines[2])
+}
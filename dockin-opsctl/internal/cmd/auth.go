@@ -16,8 +16,10 @@ package cmd
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 
+	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/webankfintech/dockin-opsctl/internal/common"
@@ -29,7 +31,9 @@ import (
 
 var (
 	authLong    = `auth return a access token according to the user name and password`
-	authExample = `dockin-opsctl auth -u admin -p admin -r default`
+	authExample = `dockin-opsctl auth -u admin -p admin -r default
+  dockin-opsctl auth -u alice -p secret --provider ldap
+  dockin-opsctl auth --oidc`
 )
 
 func NewAuthCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
@@ -48,6 +52,8 @@ func NewAuthCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 	}
 	sshCmd.Flags().StringVarP(&opt.UserName, "user name", "u", opt.UserName, "pass user name")
 	sshCmd.Flags().StringVarP(&opt.Password, "password", "p", opt.Password, "pass password")
+	sshCmd.Flags().StringVar(&opt.Provider, "provider", opt.Provider, "auth provider, static or ldap, try all of them if empty")
+	sshCmd.Flags().BoolVar(&opt.OIDC, "oidc", opt.OIDC, "login with oidc device authorization in browser")
 	return sshCmd
 }
 
@@ -55,9 +61,30 @@ type AuthOption struct {
 	UserName string
 	Password string
 	Rule     string
+	Provider string
+	OIDC     bool
+}
+
+type deviceResult struct {
+	Code    int
+	Message string
+	Data    struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
 }
 
 func (option *AuthOption) Validate() error {
+	if option.OIDC {
+		if option.Rule == "" {
+			option.Rule = "default"
+		}
+		return nil
+	}
 	if option.UserName == "" {
 		return errors.Errorf("user name is empty")
 	}
@@ -76,6 +103,10 @@ func (option *AuthOption) Complete(configFlags *genericclioptions.ConfigFlags, c
 }
 
 func (option *AuthOption) Run() error {
+	if option.OIDC {
+		return option.runDevice()
+	}
+
 	aes, err := aes.NewAes(common.ResKey)
 	if err != nil {
 		nerr := errors.Errorf("encrypt failed err=%s", err.Error())
@@ -90,6 +121,9 @@ func (option *AuthOption) Run() error {
 		return nerr
 	}
 	authUrl := fmt.Sprintf("%s?userName=%s&password=%s&rule=%s", common.GetCommonUrlByCmd("ctrl/auth"), option.UserName, passAes, option.Rule)
+	if option.Provider != "" {
+		authUrl = fmt.Sprintf("%s&provider=%s", authUrl, option.Provider)
+	}
 	body, err := utils.HttpGetWithTimeout(authUrl, time.Second*3)
 	if err != nil {
 		return err
@@ -97,3 +131,53 @@ func (option *AuthOption) Run() error {
 	fmt.Println(string(body))
 	return nil
 }
+
+func (option *AuthOption) runDevice() error {
+	body, err := utils.HttpGetWithTimeout(common.GetCommonUrlByCmd("ctrl/oidc/device"), time.Second*10)
+	if err != nil {
+		return err
+	}
+	device := &deviceResult{}
+	if err := jsoniter.Unmarshal(body, device); err != nil {
+		log.Debugf("parse device response failed, body=%s", string(body))
+		return errors.Errorf("start oidc login failed")
+	}
+	if device.Code != 0 {
+		return errors.Errorf("start oidc login failed, message=%s", device.Message)
+	}
+
+	da := device.Data
+	if da.VerificationURIComplete != "" {
+		fmt.Printf("open %s in browser to login\n", da.VerificationURIComplete)
+	} else {
+		fmt.Printf("open %s in browser and enter code %s to login\n", da.VerificationURI, da.UserCode)
+	}
+
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(da.ExpiresIn) * time.Second)
+	tokenUrl := fmt.Sprintf("%s?deviceCode=%s&rule=%s", common.GetCommonUrlByCmd("ctrl/oidc/deviceToken"),
+		url.QueryEscape(da.DeviceCode), option.Rule)
+	for da.ExpiresIn <= 0 || time.Now().Before(deadline) {
+		time.Sleep(interval)
+		body, err := utils.HttpGetWithTimeout(tokenUrl, time.Second*10)
+		if err != nil {
+			return err
+		}
+		result := make(map[string]interface{})
+		if err := jsoniter.Unmarshal(body, &result); err != nil {
+			return errors.Errorf("oidc login failed")
+		}
+		if code, _ := result["Code"].(float64); code == 1 {
+			if result["Message"] == "slow down" {
+				interval += 5 * time.Second
+			}
+			continue
+		}
+		fmt.Println(string(body))
+		return nil
+	}
+	return errors.Errorf("oidc login expired, please retry")
+}
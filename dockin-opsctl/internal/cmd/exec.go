@@ -67,6 +67,7 @@ func NewExecCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 	execCmd.Flags().BoolVarP(&opt.Stdin, "stdin", "i", opt.Stdin, "Pass stdin to the container")
 	execCmd.Flags().BoolVarP(&opt.TTY, "tty", "t", opt.TTY, "Stdin is a TTY")
 	execCmd.Flags().StringVarP(&opt.User, "user", "s", opt.User, "run as user name, default to app")
+	execCmd.Flags().StringVar(&opt.Reason, "reason", opt.Reason, "why to run as root, required with -s root")
 	execCmd.Flags().StringArrayVarP(&opt.Env, "env", "e", opt.Env, "exec env variable, like: a=1")
 	execCmd.Flags().StringVarP(&opt.WorkDir, "work-dir", "w", opt.WorkDir, "exec work directory")
 	return execCmd
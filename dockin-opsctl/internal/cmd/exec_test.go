@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/webankfintech/dockin-opsctl/internal/option"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestExecRunPath(t *testing.T) {
+	for _, c := range []struct {
+		args          []string
+		stdinTerminal bool
+		interactive   bool
+	}{
+		{[]string{"web-0", "--", "grep", "x", "/data/app.log"}, true, false},
+		{[]string{"web-0", "-c", "app", "date"}, true, false},
+		{[]string{"web-0", "--", "grep", "x"}, false, true},
+		{[]string{"web-0", "-i=false", "--", "date"}, false, false},
+		{[]string{"web-0", "-i", "--", "cat"}, true, true},
+		{[]string{"web-0", "-t", "--", "top"}, true, true},
+		{[]string{"web-0", "-it", "--", "bash", "-il"}, true, true},
+	} {
+		execCmd := NewExecCmd(genericclioptions.NewConfigFlags(true))
+		assert.NoError(t, execCmd.ParseFlags(c.args))
+		stdin, _ := execCmd.Flags().GetBool("stdin")
+		tty, _ := execCmd.Flags().GetBool("tty")
+		opt := &option.ExecOption{Stdin: stdin, TTY: tty}
+		opt.CompleteStdin(execCmd, c.stdinTerminal)
+		assert.Equal(t, c.interactive, opt.Interactive(), "%v", c.args)
+	}
+}
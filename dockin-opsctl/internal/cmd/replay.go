@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/webankfintech/dockin-opsctl/internal/common"
+	"github.com/webankfintech/dockin-opsctl/internal/log"
+	"github.com/webankfintech/dockin-opsctl/internal/ssh"
+	"github.com/webankfintech/dockin-opsctl/internal/utils"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	replayLong = `replay plays back a recorded ssh or interactive exec session in the terminal,
+the session id is the traceId of the session, listed by ctrl/getRecordings`
+	replayExample = `dockin-opsctl replay 3f2a9c0b1d5e4c6f8a7b9c0d1e2f3a4b -u admin -p admin
+  dockin-opsctl replay 3f2a9c0b1d5e4c6f8a7b9c0d1e2f3a4b --access-token xxx --speed 2 --max-wait 1s`
+
+	sleep = time.Sleep
+)
+
+func NewReplayCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	opt := &ReplayOption{Speed: 1, MaxWait: 2 * time.Second}
+	replayCmd := &cobra.Command{
+		Use:                   "replay session-id",
+		DisableFlagsInUseLine: true,
+		Short:                 "replay a recorded session",
+		Long:                  replayLong,
+		Example:               replayExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			utils.CheckErr(opt.Complete(configFlags, cmd, args))
+			utils.CheckErr(opt.Validate())
+			utils.CheckErr(opt.Run())
+		},
+	}
+	replayCmd.Flags().StringVarP(&opt.UserName, "user name", "u", opt.UserName, "pass user name")
+	replayCmd.Flags().StringVarP(&opt.Password, "password", "p", opt.Password, "pass password")
+	replayCmd.Flags().StringVarP(&opt.AccessToken, "access-token", "a", opt.AccessToken, "access token of an admin, generate from auth command")
+	replayCmd.Flags().Float64Var(&opt.Speed, "speed", opt.Speed, "playback speed")
+	replayCmd.Flags().DurationVar(&opt.MaxWait, "max-wait", opt.MaxWait, "limit the pauses between events to this duration, 0 keeps them")
+	return replayCmd
+}
+
+type ReplayOption struct {
+	SessionId   string
+	UserName    string
+	Password    string
+	AccessToken string
+	Rule        string
+	Speed       float64
+	MaxWait     time.Duration
+}
+
+type castHeader struct {
+	Version   int
+	Width     int
+	Height    int
+	Timestamp int64
+	Title     string
+	Command   string
+
+	// set instead when opserver returns an error
+	Code    int
+	Message string
+}
+
+func (option *ReplayOption) Complete(configFlags *genericclioptions.ConfigFlags, cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.Errorf("%s\n%s",
+			"no session id provided", "See 'dockin-opsctl replay -h' for help and examples.")
+	}
+	option.SessionId = args[0]
+	option.Rule, _ = cmd.Flags().GetString("rule")
+	return nil
+}
+
+func (option *ReplayOption) Validate() error {
+	if option.AccessToken == "" && option.UserName == "" {
+		return errors.Errorf("user name or access token must be assigned")
+	}
+	if option.Speed <= 0 {
+		return errors.Errorf("speed must be larger than 0")
+	}
+	return nil
+}
+
+func (option *ReplayOption) Run() error {
+	login := &SSHOption{
+		UserName:    option.UserName,
+		Password:    option.Password,
+		AccessToken: option.AccessToken,
+		Rule:        option.Rule,
+	}
+	if err := login.Login(); err != nil {
+		log.Output(err.Error())
+		return err
+	}
+
+	reqUrl := fmt.Sprintf("%s?id=%s", common.GetCommonUrlByCmd("ctrl/getRecording"), url.QueryEscape(option.SessionId))
+	hder := http.Header{}
+	hder.Set(ssh.DockinAesHeader, login.AccessToken)
+	body, err := utils.HttpGetWithHeader(reqUrl, time.Second*30, hder)
+	if err != nil {
+		log.Debugf("failed to get recording %s, err=%s", option.SessionId, err.Error())
+		return fmt.Errorf("failed to get recording %s, try again", option.SessionId)
+	}
+	return option.play(body, os.Stdout)
+}
+
+// play writes the output events of the cast to out with their original
+// timing, scaled by the speed and capped by max wait.
+func (option *ReplayOption) play(cast []byte, out io.Writer) error {
+	scanner := bufio.NewScanner(bytes.NewReader(cast))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return errors.Errorf("recording %s is empty", option.SessionId)
+	}
+	h := &castHeader{}
+	if err := jsoniter.Unmarshal(scanner.Bytes(), h); err != nil || h.Version != 2 {
+		if h.Message != "" {
+			return errors.Errorf("failed to get recording %s, errMsg=%s", option.SessionId, h.Message)
+		}
+		return errors.Errorf("recording %s is not an asciinema v2 cast", option.SessionId)
+	}
+	fmt.Fprintf(out, "replay %s, started at %s, terminal %dx%d\r\n",
+		h.Title, time.Unix(h.Timestamp, 0).Format("2006-01-02 15:04:05"), h.Width, h.Height)
+
+	last := 0.0
+	for scanner.Scan() {
+		var event []interface{}
+		if err := jsoniter.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			log.Debugf("skip invalid event %s", scanner.Text())
+			continue
+		}
+		at, _ := event[0].(float64)
+		kind, _ := event[1].(string)
+		data, _ := event[2].(string)
+
+		wait := time.Duration((at - last) / option.Speed * float64(time.Second))
+		if option.MaxWait > 0 && wait > option.MaxWait {
+			wait = option.MaxWait
+		}
+		if wait > 0 {
+			sleep(wait)
+		}
+		last = at
+		if kind == "o" {
+			io.WriteString(out, data)
+		}
+	}
+	fmt.Fprint(out, "\r\nreplay finished\r\n")
+	return scanner.Err()
+}
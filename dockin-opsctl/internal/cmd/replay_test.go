@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayPlay(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	cast := `{"version":2,"width":80,"height":24,"timestamp":0,"title":"alice@pod-0"}
+[0.5,"o","[app@pod-0 ~]$ "]
+[1.0,"i","l"]
+[11.0,"o","ls\r\n"]
+[11.5,"r","100x30"]
+`
+	op := &ReplayOption{SessionId: "abc", Speed: 2, MaxWait: time.Second}
+	out := &bytes.Buffer{}
+	assert.NoError(t, op.play([]byte(cast), out))
+	assert.Contains(t, out.String(), "replay alice@pod-0")
+	assert.Contains(t, out.String(), "[app@pod-0 ~]$ ls\r\n\r\nreplay finished")
+	assert.NotContains(t, out.String(), "100x30")
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond, time.Second, 250 * time.Millisecond}, waits)
+
+	err := op.play([]byte(`{"Code":1,"Message":"recording not found"}`), out)
+	assert.EqualError(t, err, "failed to get recording abc, errMsg=recording not found")
+}
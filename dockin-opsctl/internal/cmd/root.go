@@ -305,6 +305,10 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(NewListCmd(kubeConfigFlags))
 	rootCmd.AddCommand(NewSSHCmd(kubeConfigFlags))
 	rootCmd.AddCommand(NewAuthCmd(kubeConfigFlags))
+	rootCmd.AddCommand(NewReplayCmd(kubeConfigFlags))
+	rootCmd.AddCommand(NewAuditCmd(kubeConfigFlags))
+	rootCmd.AddCommand(NewSessionCmd(kubeConfigFlags))
+	rootCmd.AddCommand(NewApprovalCmd(kubeConfigFlags))
 	return rootCmd
 }
 
@@ -0,0 +1,236 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/webankfintech/dockin-opsctl/internal/common"
+	"github.com/webankfintech/dockin-opsctl/internal/common/printer"
+	"github.com/webankfintech/dockin-opsctl/internal/log"
+	"github.com/webankfintech/dockin-opsctl/internal/ssh"
+	"github.com/webankfintech/dockin-opsctl/internal/utils"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	sessionLong = `session lists the running ssh and interactive exec sessions of every opserver instance,
+watches the output of one session read-only, or kills it, it requires the access token of an admin`
+	sessionExample = `dockin-opsctl session list -u admin -p admin --user alice
+  dockin-opsctl session watch 3f2a9c0b1d5e4c6f8a7b9c0d1e2f3a4b --access-token xxx
+  dockin-opsctl session kill 3f2a9c0b1d5e4c6f8a7b9c0d1e2f3a4b --access-token xxx -m "maintenance of the pod"`
+)
+
+func NewSessionCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	opt := &SessionOption{}
+	sessionCmd := &cobra.Command{
+		Use:                   "session (list|watch|kill) [session-id]",
+		DisableFlagsInUseLine: true,
+		Short:                 "list, watch or kill running sessions",
+		Long:                  sessionLong,
+		Example:               sessionExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			utils.CheckErr(opt.Complete(configFlags, cmd, args))
+			utils.CheckErr(opt.Validate())
+			utils.CheckErr(opt.Run())
+		},
+	}
+	sessionCmd.Flags().StringVarP(&opt.UserName, "user name", "u", opt.UserName, "pass user name")
+	sessionCmd.Flags().StringVarP(&opt.Password, "password", "p", opt.Password, "pass password")
+	sessionCmd.Flags().StringVarP(&opt.AccessToken, "access-token", "a", opt.AccessToken, "access token of an admin, generate from auth command")
+	sessionCmd.Flags().StringVar(&opt.User, "user", opt.User, "only list sessions of the user")
+	sessionCmd.Flags().StringVar(&opt.Pod, "pod", opt.Pod, "only list sessions of the pod")
+	sessionCmd.Flags().StringVarP(&opt.Message, "message", "m", opt.Message, "message shown to the user of a killed session")
+	sessionCmd.Flags().StringVarP(&opt.Output, "output", "o", opt.Output, "output format of list, one of (table|json)")
+	return sessionCmd
+}
+
+type SessionOption struct {
+	UserName    string
+	Password    string
+	AccessToken string
+	Rule        string
+
+	Verb      string
+	SessionId string
+	User      string
+	Pod       string
+	Message   string
+	Output    string
+}
+
+type liveSession struct {
+	Id       string
+	Node     string
+	Action   string
+	User     string
+	SourceIP string
+	Pod      string
+	Start    time.Time
+	Idle     int64
+	BytesIn  int64
+	BytesOut int64
+}
+
+func (option *SessionOption) Complete(configFlags *genericclioptions.ConfigFlags, cmd *cobra.Command, args []string) error {
+	option.Rule, _ = cmd.Flags().GetString("rule")
+	option.Verb = "list"
+	if len(args) > 0 {
+		option.Verb = args[0]
+	}
+	if len(args) > 1 {
+		option.SessionId = args[1]
+	}
+	return nil
+}
+
+func (option *SessionOption) Validate() error {
+	if option.AccessToken == "" && option.UserName == "" {
+		return errors.Errorf("user name or access token must be assigned")
+	}
+	switch option.Verb {
+	case "list":
+		if option.Output != "" && option.Output != "table" && option.Output != "json" {
+			return errors.Errorf("unknown output format %s", option.Output)
+		}
+	case "watch", "kill":
+		if option.SessionId == "" {
+			return errors.Errorf("%s\n%s",
+				"no session id provided", "See 'dockin-opsctl session -h' for help and examples.")
+		}
+	default:
+		return errors.Errorf("unknown action %s, one of (list|watch|kill)", option.Verb)
+	}
+	return nil
+}
+
+func (option *SessionOption) Run() error {
+	login := &SSHOption{
+		UserName:    option.UserName,
+		Password:    option.Password,
+		AccessToken: option.AccessToken,
+		Rule:        option.Rule,
+	}
+	if err := login.Login(); err != nil {
+		log.Output(err.Error())
+		return err
+	}
+
+	hder := http.Header{}
+	hder.Set(ssh.DockinAesHeader, login.AccessToken)
+	switch option.Verb {
+	case "watch":
+		return option.watch(hder)
+	case "kill":
+		params := url.Values{}
+		params.Set("id", option.SessionId)
+		params.Set("message", option.Message)
+		if _, err := ctrlGet("ctrl/killSession", params, hder); err != nil {
+			return err
+		}
+		fmt.Printf("session %s is killed\n", option.SessionId)
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("user", option.User)
+	params.Set("pod", option.Pod)
+	data, err := ctrlGet("ctrl/getSessions", params, hder)
+	if err != nil {
+		return err
+	}
+	if option.Output == "json" {
+		buf := &bytes.Buffer{}
+		json.Indent(buf, data, "", "  ")
+		fmt.Fprintln(os.Stdout, buf.String())
+		return nil
+	}
+	var list []*liveSession
+	if err := jsoniter.Unmarshal(data, &list); err != nil {
+		return errors.Errorf("failed to parse sessions, data=%s", string(data))
+	}
+	printSessions(os.Stdout, list)
+	return nil
+}
+
+// ctrlGet calls the ctrl api and returns the data of its result.
+func ctrlGet(cmd string, params url.Values, hder http.Header) (jsoniter.RawMessage, error) {
+	reqUrl := fmt.Sprintf("%s?%s", common.GetCommonUrlByCmd(cmd), params.Encode())
+	body, err := utils.HttpGetWithHeader(reqUrl, time.Second*30, hder)
+	if err != nil {
+		log.Debugf("failed to request %s, err=%s", cmd, err.Error())
+		return nil, fmt.Errorf("failed to request %s, try again", cmd)
+	}
+	result := &auditResult{}
+	if err := jsoniter.Unmarshal(body, result); err != nil {
+		return nil, errors.Errorf("failed to parse the result of %s, body=%s", cmd, string(body))
+	}
+	if result.Code != 0 {
+		return nil, errors.Errorf("failed to request %s, errMsg=%s", cmd, result.Message)
+	}
+	return result.Data, nil
+}
+
+// watch prints the output of the session until it ends.
+func (option *SessionOption) watch(hder http.Header) error {
+	uri := fmt.Sprintf("%s?id=%s", common.GetInteractiveUrlByCmd("ctrl/watchSession"), url.QueryEscape(option.SessionId))
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 5 * time.Second,
+	}
+	conn, resp, err := dialer.Dial(uri, hder)
+	if err != nil {
+		if resp != nil {
+			body := &bytes.Buffer{}
+			io.Copy(body, resp.Body)
+			result := &auditResult{}
+			if jsoniter.Unmarshal(body.Bytes(), result) == nil && result.Message != "" {
+				return errors.Errorf("failed to watch session %s, errMsg=%s", option.SessionId, result.Message)
+			}
+		}
+		log.Debugf("failed to watch session %s, err=%s", option.SessionId, err.Error())
+		return fmt.Errorf("failed to watch session %s, try again", option.SessionId)
+	}
+	defer conn.Close()
+	fmt.Fprintf(os.Stdout, "watching session %s, press ctrl+c to stop\r\n", option.SessionId)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil
+		}
+		os.Stdout.Write(data)
+	}
+}
+
+func printSessions(out io.Writer, list []*liveSession) {
+	w := printer.GetNewTabWriter(out)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tACTION\tUSER\tSOURCE\tPOD\tSTART\tIDLE\tIN\tOUT\tNODE")
+	for _, s := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", s.Id, s.Action, s.User, s.SourceIP, s.Pod,
+			s.Start.Local().Format("2006-01-02 15:04:05"), time.Duration(s.Idle)*time.Second, s.BytesIn, s.BytesOut, s.Node)
+	}
+}
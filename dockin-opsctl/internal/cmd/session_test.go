@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionValidate(t *testing.T) {
+	op := &SessionOption{AccessToken: "token", Verb: "list"}
+	assert.NoError(t, op.Validate())
+	op.Verb = "kill"
+	assert.Error(t, op.Validate())
+	op.SessionId = "t1"
+	assert.NoError(t, op.Validate())
+	op.Verb = "attach"
+	assert.Error(t, op.Validate())
+}
+
+func TestPrintSessions(t *testing.T) {
+	out := &bytes.Buffer{}
+	printSessions(out, []*liveSession{
+		{Id: "t1", Action: "ssh", User: "alice", Pod: "web-0", Start: time.Now(), Idle: 90, BytesIn: 12, BytesOut: 3400, Node: "op-1"},
+	})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Regexp(t, `^t1\s+ssh\s+alice\s`, lines[1])
+	assert.Regexp(t, `1m30s\s+12\s+3400\s+op-1$`, lines[1])
+}
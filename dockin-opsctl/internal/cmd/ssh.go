@@ -50,6 +50,8 @@ var (
 		# dockin-opsctl ssh 192.168.1.1 -u admin -p admin -r default
 		# ssh according to access token
 		# dockin-opsctl ssh 192.168.1.1 --access-token foiudepjfpghuqwipr1028390eu8fihyedpqrhfuwospkal
+		# ssh as root, which needs a role allowing root or an approved approval request
+		# dockin-opsctl ssh dockin-test-20191012-182050448-0 -r default -s root --reason "incident 42"
 `
 	sshExample = `dockin-opsctl ssh dockin-test-20191012-182050448-0 -u admin -p admin -r default`
 
@@ -75,6 +77,7 @@ func NewSSHCmd(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 	sshCmd.Flags().StringVarP(&opt.ContainerName, "container", "c", "op_server", "ContainerName name. If omitted, the first container in the pod will be chosen")
 	sshCmd.Flags().StringVarP(&opt.AccessToken, "access-token", "a", opt.UserName, "access token to access the pod by ssh command, generate from auth command")
 	sshCmd.Flags().StringVarP(&opt.User, "user", "s", opt.User, "run as user name, default to app")
+	sshCmd.Flags().StringVar(&opt.Reason, "reason", opt.Reason, "why to run as root, required with -s root")
 	sshCmd.Flags().StringArrayVarP(&opt.Env, "env", "e", opt.Env, "exec env variable, like: a=1")
 	sshCmd.Flags().StringVarP(&opt.WorkDir, "work-dir", "w", opt.WorkDir, "exec work directory")
 	return sshCmd
@@ -89,6 +92,7 @@ type SSHOption struct {
 	Rule          string
 	Namespace     string
 	User          string
+	Reason        string
 	Env           []string
 	WorkDir       string
 }
@@ -153,6 +157,8 @@ func (option *SSHOption) Run() error {
 	proto.AccessToken = option.AccessToken
 	proto.Env = option.Env
 	proto.WorkDir = option.WorkDir
+	proto.User = option.User
+	proto.Reason = option.Reason
 	proto.Name = option.PodName
 	proto.Container = option.ContainerName
 
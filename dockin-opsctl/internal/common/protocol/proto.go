@@ -28,6 +28,7 @@ type Proto struct {
 	AccessToken string                 `json:"accessToken"`
 	Env         []string               `json:"env"`
 	User        string                 `json:"user"`
+	Reason      string                 `json:"reason"`
 	WorkDir     string                 `json:"workDir"`
 	Container   string                 `json:"container"`
 }
@@ -15,12 +15,17 @@
 package option
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/webankfintech/dockin-opsctl/internal/common/protocol"
 	"github.com/webankfintech/dockin-opsctl/internal/log"
 	"github.com/webankfintech/dockin-opsctl/internal/ssh"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	utilexec "k8s.io/utils/exec"
 )
 
 type ExecOption struct {
@@ -37,6 +42,7 @@ type ExecOption struct {
 	Stdin bool
 	TTY   bool
 	User          string
+	Reason        string
 	Env           []string
 	WorkDir       string
 }
@@ -52,10 +58,20 @@ func (option *ExecOption) Complete(configFlags *genericclioptions.ConfigFlags, c
 	option.CommandList = args[1:]
 	option.Namespace, _ = cmd.Flags().GetString("namespace")
 	option.Rule, _ = cmd.Flags().GetString("rule")
+	option.CompleteStdin(cmd, terminal.IsTerminal(int(os.Stdin.Fd())))
 
 	return nil
 }
 
+// CompleteStdin leaves stdin out when it is a terminal and -i is not given,
+// so that the output of the command is streamed, while piped data is still
+// passed to the command by default.
+func (option *ExecOption) CompleteStdin(cmd *cobra.Command, stdinTerminal bool) {
+	if stdinTerminal && !cmd.Flags().Changed("stdin") {
+		option.Stdin = false
+	}
+}
+
 func (option *ExecOption) Validate() error {
 	if len(option.CommandList) == 0 {
 		return errors.Errorf("%s\n%s", NoCommandErr, ExecCommandSuggest)
@@ -72,6 +88,8 @@ func (option *ExecOption) Run() error {
 	proto.Flags = option.CommandList
 	proto.Env =	option.Env
 	proto.WorkDir = option.WorkDir
+	proto.User = option.User
+	proto.Reason = option.Reason
 
 	if option.Namespace != "" {
 		proto.Params["namespace"] = option.Namespace
@@ -83,6 +101,36 @@ func (option *ExecOption) Run() error {
 	if option.TTY{
 		proto.Params["tty"] = true
 	}
+	if !option.Interactive() {
+		return runCommon(proto)
+	}
 
 	return  ssh.RunInteractive(proto)
 }
+
+// Interactive reports whether the command is run with stdin, and a terminal
+// with -t, attached, instead of streaming its output.
+func (option *ExecOption) Interactive() bool {
+	return option.Stdin || option.TTY
+}
+
+// runCommon prints the output of a command run without a terminal as it
+// arrives, and returns an exit error with the exit code of the command if it
+// failed.
+func runCommon(proto *protocol.Proto) error {
+	result, err := ssh.RunStream(proto, os.Stdout, os.Stderr)
+	if result != nil {
+		log.Debugf("exec result exitCode=%d, duration=%dms, pod=%s, container=%s, traceId=%s",
+			result.ExitCode, result.Duration, result.Pod, result.Container, result.TraceId)
+	}
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return utilexec.CodeExitError{
+			Err:  fmt.Errorf("command terminated with exit code %d", result.ExitCode),
+			Code: result.ExitCode,
+		}
+	}
+	return nil
+}
@@ -31,6 +31,26 @@ type OPServerResult struct {
 	Data    interface{}
 }
 
+// ExecResult is the result of a command run by common-exec. ExitCode is -1
+// if the command did not run to its end.
+type ExecResult struct {
+	ExitCode  int    `json:"exitCode"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Duration  int64  `json:"duration"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	TraceId   string `json:"traceId"`
+}
+
+// ExecFrame is one line of the streamed result of command-exec, of type
+// stdout, stderr, exit or error.
+type ExecFrame struct {
+	Type   string      `json:"type"`
+	Data   string      `json:"data"`
+	Result *ExecResult `json:"result"`
+}
+
 type UserIdentity struct {
 	UserName    string    `json:"userName"`
 	Password    string    `json:"password"`
@@ -16,8 +16,10 @@ package ssh
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -96,11 +98,84 @@ func RunInteractive(proto *protocol.Proto) error {
 	return Exec(proto, uri)
 }
 
-func RunCommon(proto *protocol.Proto) ([]byte, error) {
+// RunCommon runs the command without a terminal and returns its result, the
+// error tells why the command did not run to its end.
+func RunCommon(proto *protocol.Proto) (*ExecResult, error) {
 	data := createRequestQuery(proto)
 	reqUrl := common.GetCommonUrlByCmd("common-exec") + "?" + data
 	hder := http.Header{}
-	return utils.HttpGetWithHeader(reqUrl, time.Second*60*5, hder)
+	body, err := utils.HttpGetWithHeader(reqUrl, time.Second*60*5, hder)
+	if err != nil {
+		log.Debugf("failed to call common-exec, err=%s", err.Error())
+		return nil, err
+	}
+	return parseExecResult(body)
+}
+
+// RunStream runs the command without a terminal through command-exec, writes
+// its output to stdout and stderr as it arrives and returns its result.
+func RunStream(proto *protocol.Proto, stdout, stderr io.Writer) (*ExecResult, error) {
+	reqUrl := common.GetCommonUrlByCmd("command-exec") + "?" + createRequestQuery(proto) + "&stream=1"
+	log.Debugf("send http request url=%s", reqUrl)
+	resp, err := http.Get(reqUrl)
+	if err != nil {
+		log.Debugf("failed to call command-exec, err=%s", err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readFrames(resp.Body, stdout, stderr)
+}
+
+func readFrames(r io.Reader, stdout, stderr io.Writer) (*ExecResult, error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			f := &ExecFrame{}
+			if err := jsoniter.Unmarshal(line, f); err != nil {
+				return nil, errors.Errorf("failed to parse exec output, data=%s", string(line))
+			}
+			switch f.Type {
+			case "stdout":
+				io.WriteString(stdout, f.Data)
+			case "stderr":
+				io.WriteString(stderr, f.Data)
+			case "exit":
+				if f.Result == nil {
+					return nil, errors.Errorf("exec result is empty, data=%s", string(line))
+				}
+				return f.Result, nil
+			case "error":
+				return f.Result, errors.New(f.Data)
+			default:
+				log.Debugf("skip exec output of unknown type %s", f.Type)
+			}
+		}
+		if err == io.EOF {
+			return nil, errors.New("exec output ended before the command exited")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func parseExecResult(body []byte) (*ExecResult, error) {
+	res := &struct {
+		Code    int
+		Message string
+		Data    *ExecResult
+	}{}
+	if err := jsoniter.Unmarshal(body, res); err != nil {
+		return nil, errors.Errorf("failed to parse exec result, data=%s", string(body))
+	}
+	if res.Code != 0 {
+		return res.Data, errors.New(res.Message)
+	}
+	if res.Data == nil {
+		return nil, errors.Errorf("exec result is empty, data=%s", string(body))
+	}
+	return res.Data, nil
 }
 
 func AttachDebug(proto *protocol.Proto) error {
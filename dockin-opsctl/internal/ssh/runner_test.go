@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExecResult(t *testing.T) {
+	r, err := parseExecResult([]byte(`{"Code":0,"Message":"Success","Data":{"exitCode":1,"stdout":"","stderr":"","duration":12,"pod":"web-0","traceId":"t1"}}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, r.ExitCode)
+		assert.Equal(t, "web-0", r.Pod)
+	}
+
+	r, err = parseExecResult([]byte(`{"Code":-1,"Message":"failed to exec command","Data":{"exitCode":-1,"stderr":"x"}}`))
+	assert.EqualError(t, err, "failed to exec command")
+	if assert.NotNil(t, r) {
+		assert.Equal(t, -1, r.ExitCode)
+	}
+
+	_, err = parseExecResult([]byte(`{"Code":-1,"Message":"permission denied"}`))
+	assert.EqualError(t, err, "permission denied")
+	_, err = parseExecResult([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestReadFrames(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	r, err := readFrames(strings.NewReader(`{"type":"stdout","data":"a\n"}
+{"type":"stderr","data":"warn\n"}
+{"type":"stdout","data":"b"}
+{"type":"exit","result":{"exitCode":2,"duration":5,"pod":"web-0","traceId":"t1"}}
+`), stdout, stderr)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, r.ExitCode)
+	}
+	assert.Equal(t, "a\nb", stdout.String())
+	assert.Equal(t, "warn\n", stderr.String())
+
+	_, err = readFrames(strings.NewReader(`{"type":"error","data":"permission denied"}`), stdout, stderr)
+	assert.EqualError(t, err, "permission denied")
+	_, err = readFrames(strings.NewReader(`{"type":"stdout","data":"a\n"}`), stdout, stderr)
+	assert.Error(t, err)
+	_, err = readFrames(strings.NewReader("not json\n"), stdout, stderr)
+	assert.Error(t, err)
+}
@@ -18,12 +18,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/webankfintech/dockin-opserver/internal/api/ctrl"
 	"github.com/webankfintech/dockin-opserver/internal/api/echo"
 	"github.com/webankfintech/dockin-opserver/internal/api/exec"
 	"github.com/webankfintech/dockin-opserver/internal/api/rm"
 	"github.com/webankfintech/dockin-opserver/internal/api/ssh"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
 	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/client"
 	"github.com/webankfintech/dockin-opserver/internal/config"
@@ -55,6 +57,7 @@ func NewServer(life fx.Lifecycle, cf *config.ProxyConfig) *Server {
 
 	cm := client.NewManager(rc)
 	cm.Initialize()
+	audit.StartForwarder()
 	return &Server{
 		Life:            life,
 		listenStopper:   cm.ListenStopper,
@@ -89,6 +92,10 @@ func Run(s *Server, cf *config.ProxyConfig) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		os.Exit(verifyAudit(os.Args[2:]))
+	}
+
 	go func() {
 		http.ListenAndServe(":10000", nil)
 	}()
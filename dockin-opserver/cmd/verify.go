@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+)
+
+// verifyAudit checks the audit chains in the files, or in stdin without
+// files, with the checkpoint key of application.yaml. It returns 1 when the
+// chains have gaps or edits.
+func verifyAudit(files []string) int {
+	secrets := map[string]string{}
+	if key := config.OpsConfig.Audit.Forward.CheckpointKey; key.Secret != "" {
+		secrets[key.Id] = key.Secret
+	} else {
+		fmt.Println("audit.forward.checkpoint-key is not set, signatures of checkpoints are not checked")
+	}
+
+	var readers []io.Reader
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Printf("failed to open %s, err=%s\n", name, err.Error())
+			return 2
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	if len(readers) == 0 {
+		readers = append(readers, os.Stdin)
+	}
+
+	result, err := audit.Verify(io.MultiReader(readers...), secrets)
+	if err != nil {
+		fmt.Printf("failed to read audit entries, err=%s\n", err.Error())
+		return 2
+	}
+	for _, n := range result.Notes {
+		fmt.Println("note:", n)
+	}
+	for _, p := range result.Problems {
+		fmt.Println("problem:", p)
+	}
+	fmt.Printf("%d entries, %d checkpoints, %d duplicates, %d problems\n",
+		result.Entries, result.Checkpoints, result.Duplicates, len(result.Problems))
+	if len(result.Problems) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -3,9 +3,12 @@ module github.com/webankfintech/dockin-opserver
 go 1.12
 
 require (
+	github.com/coreos/go-oidc v2.2.1+incompatible
 	github.com/evanphx/json-patch v4.5.0+incompatible
 	github.com/fatih/camelcase v1.0.0
 	github.com/ghodss/yaml v1.0.0 // indirect
+	github.com/go-asn1-ber/asn1-ber v1.5.1
+	github.com/go-ldap/ldap/v3 v3.2.4
 	github.com/go-redis/redis/v7 v7.2.0
 	github.com/gogo/protobuf v1.2.1 // indirect
 	github.com/golang/groupcache v0.0.0-20190129154638-5b532d6fd5ef // indirect
@@ -20,6 +23,7 @@ require (
 	github.com/panjf2000/ants/v2 v2.1.0
 	github.com/pkg/errors v0.8.1
 	github.com/pkg/term v1.1.0
+	github.com/pquerna/cachecontrol v0.0.0-20180517163645-1555304b9b35 // indirect
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/pflag v1.0.3
@@ -32,10 +36,13 @@ require (
 	go.uber.org/goleak v0.10.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 	go.uber.org/zap v1.10.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
+	golang.org/x/oauth2 v0.0.0-20190402181905-9f3314589c9a
 	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gopkg.in/square/go-jose.v2 v2.5.1
 	gopkg.in/yaml.v2 v2.3.0
 	k8s.io/api v0.0.0-20190718062839-c8a0b81cb10e
 	k8s.io/apimachinery v0.0.0-20190717022731-0bb8574e0887
@@ -45,5 +52,4 @@ require (
 	k8s.io/kubectl v0.0.0-20190704051832-70c55756d672
 	k8s.io/utils v0.0.0-20190607212802-c55fbcfc754a
 	mvdan.cc/sh/v3 v3.0.2
-
 )
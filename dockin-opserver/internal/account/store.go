@@ -0,0 +1,217 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package account
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/token"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	nameField       = "name"
+	passwdField     = "passwd"
+	updateTimeField = "updateTime"
+
+	minPasswordLen = 8
+)
+
+var (
+	ErrAccountExist    = errors.New("account already exists")
+	ErrAccountNotExist = errors.New("account does not exist")
+	ErrAuthFailed      = errors.New("user name or password is invalid")
+	ErrPasswordTooWeak = errors.Errorf("password must have at least %d characters", minPasswordLen)
+
+	hashCost = bcrypt.DefaultCost
+
+	// compared against when the user does not exist, so a missing account
+	// takes as long to reject as a wrong password
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dockin-opserver"), bcrypt.DefaultCost)
+)
+
+type Store struct {
+	redisClient *redis.RedisClient
+}
+
+func NewStore(r *redis.RedisClient) *Store {
+	return &Store{redisClient: r}
+}
+
+func (s *Store) List() ([]string, error) {
+	return s.redisClient.SMembers(keys.GetAccountKey())
+}
+
+func (s *Store) Exist(userName string) (bool, error) {
+	return s.redisClient.HExist(keys.GetUserNameKey(userName), nameField)
+}
+
+func (s *Store) Add(userName, password string) error {
+	exist, err := s.Exist(userName)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return ErrAccountExist
+	}
+
+	if err := s.setPassword(userName, password); err != nil {
+		return err
+	}
+	return s.redisClient.SAdd(keys.GetAccountKey(), []string{userName})
+}
+
+func (s *Store) Update(userName, password string) error {
+	exist, err := s.Exist(userName)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return ErrAccountNotExist
+	}
+
+	if err := s.setPassword(userName, password); err != nil {
+		return err
+	}
+	return s.revokeTokens(userName)
+}
+
+func (s *Store) ChangePassword(userName, oldPassword, newPassword string) error {
+	if err := s.Validate(userName, oldPassword); err != nil {
+		return err
+	}
+	if err := s.setPassword(userName, newPassword); err != nil {
+		return err
+	}
+	return s.revokeTokens(userName)
+}
+
+func (s *Store) Delete(userName string) error {
+	if err := s.redisClient.SRem(keys.GetAccountKey(), []string{userName}); err != nil {
+		return err
+	}
+	if err := s.redisClient.Del(keys.GetUserNameKey(userName)); err != nil {
+		return err
+	}
+	return s.revokeTokens(userName)
+}
+
+func (s *Store) Validate(userName, password string) error {
+	hash, err := s.redisClient.HGet(keys.GetUserNameKey(userName), passwdField)
+	if err != nil && err != redis.Nil {
+		log.Logger.Warnf("failed to get account, userName=%s, err=%s", userName, err.Error())
+		return err
+	}
+
+	if err == redis.Nil || hash.(string) == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		log.Logger.Infof("account validate failed, no credential stored, userName=%s", userName)
+		return ErrAuthFailed
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash.(string)), []byte(password)); err != nil {
+		log.Logger.Infof("account validate failed, password mismatch, userName=%s", userName)
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// MigrateFromConfig stores the hash of every plaintext account in
+// application.yaml which has no credential in redis yet. Each account is
+// migrated only once, so an account deleted later is not brought back.
+func (s *Store) MigrateFromConfig() {
+	migrated, err := s.redisClient.SMembers(keys.GetMigratedAccountKey())
+	if err != nil {
+		log.Logger.Warnf("failed to get migrated accounts, err=%s", err.Error())
+		return
+	}
+	done := make(map[string]bool, len(migrated))
+	for _, userName := range migrated {
+		done[userName] = true
+	}
+
+	for _, account := range config.OpsConfig.Accounts {
+		userName, passwd := account.Account.UserName, account.Account.Passwd
+		if userName == "" || passwd == "" {
+			continue
+		}
+		if done[userName] {
+			log.Logger.Warnf("account userName=%s was migrated to store, remove its passwd from application.yaml", userName)
+			continue
+		}
+
+		hash, err := s.redisClient.HGet(keys.GetUserNameKey(userName), passwdField)
+		if err != nil && err != redis.Nil {
+			log.Logger.Warnf("failed to migrate account, userName=%s, err=%s", userName, err.Error())
+			continue
+		}
+		if err == nil && hash.(string) != "" {
+			log.Logger.Warnf("account userName=%s already in store, remove its passwd from application.yaml", userName)
+			s.markMigrated(userName)
+			continue
+		}
+
+		if err := s.setPassword(userName, passwd); err != nil {
+			log.Logger.Warnf("failed to migrate account, userName=%s, err=%s", userName, err.Error())
+			continue
+		}
+		if err := s.redisClient.SAdd(keys.GetAccountKey(), []string{userName}); err != nil {
+			log.Logger.Warnf("failed to migrate account, userName=%s, err=%s", userName, err.Error())
+			continue
+		}
+		s.markMigrated(userName)
+		log.Logger.Warnf("migrated account userName=%s to store, remove its passwd from application.yaml", userName)
+	}
+}
+
+func (s *Store) markMigrated(userName string) {
+	if err := s.redisClient.SAdd(keys.GetMigratedAccountKey(), []string{userName}); err != nil {
+		log.Logger.Warnf("failed to mark account migrated, userName=%s, err=%s", userName, err.Error())
+	}
+}
+
+func (s *Store) setPassword(userName, password string) error {
+	if len(password) < minPasswordLen {
+		return ErrPasswordTooWeak
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), hashCost)
+	if err != nil {
+		return errors.Errorf("hash password failed, err=%s", err.Error())
+	}
+
+	userKey := keys.GetUserNameKey(userName)
+	if err := s.redisClient.HSet(userKey, nameField, userName); err != nil {
+		return err
+	}
+	if err := s.redisClient.HSet(userKey, passwdField, string(hash)); err != nil {
+		return err
+	}
+	return s.redisClient.HSet(userKey, updateTimeField, strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+func (s *Store) revokeTokens(userName string) error {
+	if err := token.RevokeUser(s.redisClient, userName); err != nil {
+		log.Logger.Warnf("failed to revoke tokens of userName=%s, err=%s", userName, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package account
+
+import (
+	"testing"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func newTestStore(t *testing.T) *Store {
+	rc, err := redis.NewRedisClient()
+	assert.NoError(t, err)
+	if err := rc.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+	return NewStore(rc)
+}
+
+func TestStore(t *testing.T) {
+	s := newTestStore(t)
+	userName := "dockin_test_user"
+	s.Delete(userName)
+	defer s.Delete(userName)
+
+	assert.Equal(t, ErrPasswordTooWeak, s.Add(userName, "short"))
+	assert.NoError(t, s.Add(userName, "Passw0rd-1"))
+	assert.Equal(t, ErrAccountExist, s.Add(userName, "Passw0rd-1"))
+
+	assert.NoError(t, s.Validate(userName, "Passw0rd-1"))
+	assert.Equal(t, ErrAuthFailed, s.Validate(userName, "passw0rd-1"))
+	assert.Equal(t, ErrAuthFailed, s.Validate("dockin_no_such_user", "Passw0rd-1"))
+
+	assert.Equal(t, ErrAuthFailed, s.ChangePassword(userName, "wrong-password", "Passw0rd-2"))
+	assert.NoError(t, s.ChangePassword(userName, "Passw0rd-1", "Passw0rd-2"))
+	assert.Equal(t, ErrAuthFailed, s.Validate(userName, "Passw0rd-1"))
+
+	assert.NoError(t, s.Update(userName, "Passw0rd-3"))
+	assert.NoError(t, s.Validate(userName, "Passw0rd-3"))
+	assert.Equal(t, ErrAccountNotExist, s.Update("dockin_no_such_user", "Passw0rd-3"))
+
+	hash, err := s.redisClient.HGet(keys.GetUserNameKey(userName), passwdField)
+	assert.NoError(t, err)
+	assert.NotContains(t, hash, "Passw0rd-3")
+}
+
+func TestMigrateFromConfig(t *testing.T) {
+	s := newTestStore(t)
+	accounts := config.OpsConfig.Accounts
+	defer func() { config.OpsConfig.Accounts = accounts }()
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+- account: {user-name: dockin_test_migrated, passwd: Passw0rd-1}
+- account: {user-name: dockin_test_weak, passwd: short}
+`), &config.OpsConfig.Accounts))
+	for _, userName := range []string{"dockin_test_migrated", "dockin_test_weak"} {
+		s.Delete(userName)
+		s.redisClient.SRem(keys.GetMigratedAccountKey(), []string{userName})
+		defer s.Delete(userName)
+	}
+
+	s.MigrateFromConfig()
+	assert.NoError(t, s.Validate("dockin_test_migrated", "Passw0rd-1"))
+	exist, err := s.Exist("dockin_test_weak")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// a deleted account is not migrated again
+	assert.NoError(t, s.Delete("dockin_test_migrated"))
+	s.MigrateFromConfig()
+	exist, err = s.Exist("dockin_test_migrated")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
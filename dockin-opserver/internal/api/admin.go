@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+)
+
+const (
+	AdminRole       = "admin"
+	AccessTokenName = "access-token"
+)
+
+var ErrNotAdmin = fmt.Errorf("admin role is required")
+
+// AccessToken reads the token from the access-token header, the manager page
+// sends it as a cookie instead.
+func AccessToken(req *http.Request) string {
+	if t := req.Header.Get(AccessTokenName); t != "" {
+		return t
+	}
+	if c, err := req.Cookie(AccessTokenName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+func ValidateAdmin(req *http.Request, rc *redis.RedisClient, traceId string) (*model.UserIdentity, error) {
+	ud, err := ValidateAccessToken(AccessToken(req), traceId, rc)
+	if err != nil {
+		return nil, err
+	}
+	if !IsAdmin(ud) {
+		log.Logger.Warnf("user %s is not admin, roles=%v,traceId=%s", ud.UserName, ud.Roles, traceId)
+		return nil, ErrNotAdmin
+	}
+	return ud, nil
+}
+
+func IsAdmin(ud *model.UserIdentity) bool {
+	if ud == nil || ud.UserName == model.OpagentUserName {
+		return false
+	}
+	for _, r := range ud.Roles {
+		if r == AdminRole {
+			return true
+		}
+	}
+	for _, u := range config.OpsConfig.Auth.Admins {
+		if u == ud.UserName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAdmin(t *testing.T) {
+	admins := config.OpsConfig.Auth.Admins
+	defer func() { config.OpsConfig.Auth.Admins = admins }()
+	config.OpsConfig.Auth.Admins = []string{"root-user"}
+
+	assert.False(t, IsAdmin(nil))
+	assert.False(t, IsAdmin(&model.UserIdentity{UserName: "alice"}))
+	assert.True(t, IsAdmin(&model.UserIdentity{UserName: "root-user"}))
+	assert.True(t, IsAdmin(&model.UserIdentity{UserName: "alice", Roles: []string{"dev", AdminRole}}))
+	assert.False(t, IsAdmin(&model.UserIdentity{UserName: model.OpagentUserName, Roles: []string{AdminRole}}))
+}
+
+func TestAccessToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/v1/dockin/opserver/ctrl/manager", nil)
+	assert.Equal(t, "", AccessToken(req))
+
+	req.AddCookie(&http.Cookie{Name: AccessTokenName, Value: "from-cookie"})
+	assert.Equal(t, "from-cookie", AccessToken(req))
+
+	req.Header.Set(AccessTokenName, "from-header")
+	assert.Equal(t, "from-header", AccessToken(req))
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
+	"github.com/webankfintech/dockin-opserver/internal/remote"
+)
+
+// NewAuditEvent returns an event of the request, allowed unless the verdict
+// is changed.
+func NewAuditEvent(typ, action string, opts *model.OpsOption, clientIp, traceId string) *audit.Event {
+	e := &audit.Event{
+		Type:      typ,
+		TraceId:   traceId,
+		Action:    action,
+		User:      opts.UserName,
+		SourceIP:  clientIp,
+		Cluster:   opts.ClusterId,
+		Rule:      opts.Rule,
+		Subsystem: opts.SubSystem,
+		Namespace: opts.Namespace,
+		Pod:       opts.Name,
+		Container: opts.Container,
+		RunAs:     opts.User,
+		Verdict:   audit.VerdictAllowed,
+	}
+	if action != policy.ActionSSH {
+		e.Command = redact.ForRule(opts.Rule, opts.Namespace).String(remote.JoinArgs(opts.Flags))
+	}
+	return e
+}
+
+// auditDenied records a request which is denied before its commands are
+// checked, ssh-v2 requests are recorded as denied sessions.
+func auditDenied(rc *redis.RedisClient, opts *model.OpsOption, action, clientIp, traceId string, err error) {
+	typ := audit.EventCommand
+	if action == policy.ActionSSH {
+		typ = audit.EventSessionStart
+	}
+	e := NewAuditEvent(typ, action, opts, clientIp, traceId)
+	e.Verdict, e.Reason = audit.VerdictDenied, err.Error()
+	if err := audit.NewStore(rc).Record(e); err != nil {
+		log.Logger.Warnf("failed to audit denied %s of %s, err=%s, traceId=%s", action, opts.UserName, err.Error(), traceId)
+	}
+}
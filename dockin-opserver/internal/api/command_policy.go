@@ -0,0 +1,418 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/webankfintech/dockin-opserver/internal/approval"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
+	"github.com/webankfintech/dockin-opserver/internal/remote"
+	"github.com/webankfintech/dockin-opserver/internal/utils/ip"
+)
+
+// CommandPolicy evaluates the commands of one request. ssh-v2, interact-exec,
+// common-exec and command-exec all check commands through it, so a command
+// gets the same verdict whichever entry point runs it.
+type CommandPolicy struct {
+	lock        sync.RWMutex
+	redisClient *redis.RedisClient
+	store       *policy.Store
+	ud          *model.UserIdentity
+	opts        *model.OpsOption
+	action      string
+	traceId     string
+	clientIp    string
+	rules       remote.FuncGetRules
+	record      func(e *audit.Event) error
+	grant       func(user, rule, pod, cmdLine string) (*approval.Request, error)
+	elevation   func(user, rule, pod, runAs string) (*approval.Request, error)
+
+	decision *policy.Decision
+	cmds     *policy.SessionCommands
+	mode     string
+	elevated string
+	checked  *audit.Event
+}
+
+// AuthorizeCommands authorizes the request for the action and loads the
+// command policy which applies to it. The request runs as the default user,
+// unless it asks for root and the elevation is allowed, or asks for another
+// user which the role lists.
+func AuthorizeCommands(req *http.Request, opts *model.OpsOption, action string, rc *redis.RedisClient, traceId string) (*CommandPolicy, error) {
+	clientIp := ip.GetIp(req)
+	runAs := opts.User
+	if runAs == "" || policy.IsRoot(runAs) {
+		opts.User = policy.DefaultRunAsUser
+	}
+	if !config.OpsConfig.Policy.Enabled && opts.User != policy.DefaultRunAsUser {
+		// without the policy there is no role to list other users
+		err := fmt.Errorf("permission denied, run as user %s is not allowed", opts.User)
+		auditDenied(rc, opts, action, clientIp, traceId, err)
+		return nil, err
+	}
+	ud, err := Identify(req, opts, rc, traceId)
+	if err != nil {
+		auditDenied(rc, opts, action, clientIp, traceId, err)
+		return nil, err
+	}
+	decision, err := AuthorizeIdentity(ud, opts, action, rc, traceId)
+	if err != nil {
+		auditDenied(rc, opts, action, clientIp, traceId, err)
+		return nil, err
+	}
+	cp, err := NewCommandPolicy(rc, ud, opts, action, decision, traceId)
+	if err != nil {
+		auditDenied(rc, opts, action, clientIp, traceId, err)
+		return nil, err
+	}
+	cp.clientIp = clientIp
+	if policy.IsRoot(runAs) {
+		if err := cp.Elevate(runAs, opts.Reason); err != nil {
+			return nil, err
+		}
+		opts.User = runAs
+	}
+	return cp, nil
+}
+
+func NewCommandPolicy(rc *redis.RedisClient, ud *model.UserIdentity, opts *model.OpsOption, action string,
+	decision *policy.Decision, traceId string) (*CommandPolicy, error) {
+	var err error
+	cp := &CommandPolicy{
+		redisClient: rc,
+		store:       policy.NewStore(rc),
+		ud:          ud,
+		opts:        opts,
+		action:      action,
+		traceId:     traceId,
+		decision:    decision,
+		record:      audit.NewStore(rc).Record,
+		grant:       approval.NewStore(rc).Grant,
+		elevation:   approval.NewStore(rc).Elevation,
+	}
+	cp.rules = cp.commandRules
+	if cp.cmds, err = cp.store.SessionCommands(opts.Rule, opts.UserName); err != nil {
+		log.Logger.Warnf("failed to load command sets, err=%v, traceId=%s", err, traceId)
+		return nil, err
+	}
+	if cp.mode, err = cp.store.FilterMode(opts.Rule); err != nil {
+		log.Logger.Warnf("failed to load filter mode of rule %s, use %s, err=%v, traceId=%s", opts.Rule, cp.mode, err, traceId)
+	}
+	return cp, nil
+}
+
+// Check checks the command of a request which is executed without a shell
+// session, args are the argv of the command. The verdict is audited.
+func (cp *CommandPolicy) Check(args []string) error {
+	var reasons, granted []string
+	fc := remote.NewFilterChan()
+	fc.SetMode(cp.FilterMode, func(cmd, reason string) {
+		reasons = append(reasons, reason)
+		cp.RecordViolation(cmd, reason)
+	})
+	fc.SetGrant(cp.Grant, func(cmd, reason string) {
+		granted = append(granted, reason)
+	})
+	for _, f := range cp.Filters(cp.workDir) {
+		fc.AddFilter(f)
+	}
+
+	e := NewAuditEvent(audit.EventCommand, cp.action, cp.opts, cp.clientIp, cp.traceId)
+	e.Command = redact.ForRule(cp.opts.Rule, cp.opts.Namespace).String(remote.JoinArgs(args))
+	err := fc.Do(remote.JoinArgs(args))
+	if err != nil {
+		log.Logger.Warnf("command %v is denied, user=%s, rule=%s, err=%s, traceId=%s",
+			args, cp.opts.UserName, cp.opts.Rule, err.Error(), cp.traceId)
+		e.Verdict, e.Reason = audit.VerdictDenied, err.Error()
+	} else if len(granted) > 0 {
+		e.Verdict, e.Reason = audit.VerdictGranted, strings.Join(granted, "; ")
+	} else if len(reasons) > 0 {
+		e.Verdict, e.Reason = audit.VerdictAudited, strings.Join(reasons, "; ")
+	}
+	cp.Audit(e)
+	if err == nil {
+		cp.checked = e
+	}
+	return err
+}
+
+// ReportExit records the exit status of the command Check allowed.
+func (cp *CommandPolicy) ReportExit(status int) {
+	if cp.checked == nil {
+		return
+	}
+	e := cp.checked
+	cp.checked = nil
+	cp.AuditExit(e, status)
+}
+
+// Grant allows a command line the filters denied if an approved request of
+// the user covers it on the pod. The user must be identified by a valid
+// access token, not only by the user name the client claims. Denials in ssh
+// sessions tell the user how to request an approval.
+func (cp *CommandPolicy) Grant(cmdLine string, denied error) (string, error) {
+	if cp.grant == nil || cp.ud == nil {
+		return "", denied
+	}
+	r, err := cp.grant(cp.ud.UserName, cp.opts.Rule, cp.opts.Name, cmdLine)
+	if err != nil {
+		log.Logger.Warnf("failed to load approval grants, err=%s, traceId=%s", err.Error(), cp.traceId)
+	}
+	if r != nil {
+		log.Logger.Infof("command is granted by approval request %s, user=%s, approver=%s, traceId=%s",
+			r.Id, r.User, r.Approver, cp.traceId)
+		return fmt.Sprintf("%s, granted by approval request %s approved by %s until %s",
+			denied, r.Id, r.Approver, r.Expires.Format("2006-01-02 15:04:05")), nil
+	}
+	if cp.action == policy.ActionSSH {
+		return "", fmt.Errorf("%s, request an approval with: dockin-opsctl approval request %s -r %s --command '<pattern>' --duration 30m",
+			denied, cp.opts.Name, cp.opts.Rule)
+	}
+	return "", denied
+}
+
+// Elevate allows the request to run as root if a role of the user allows it,
+// or an approved request of the user grants it on the pod, for a user
+// identified by a valid access token. A reason is required either way, the
+// elevation is audited whether allowed or not.
+func (cp *CommandPolicy) Elevate(runAs, reason string) error {
+	e := NewAuditEvent(audit.EventElevate, cp.action, cp.opts, cp.clientIp, cp.traceId)
+	e.RunAs, e.Command = runAs, "run as "+runAs
+	by, err := cp.elevate(runAs, strings.TrimSpace(reason))
+	if err != nil {
+		log.Logger.Warnf("run as %s is denied, user=%s, pod=%s, err=%s, traceId=%s",
+			runAs, cp.opts.UserName, cp.opts.Name, err.Error(), cp.traceId)
+		e.Verdict, e.Reason = audit.VerdictDenied, err.Error()
+		cp.Audit(e)
+		return err
+	}
+	log.Logger.Infof("run as %s is allowed, user=%s, pod=%s, %s, traceId=%s",
+		runAs, cp.opts.UserName, cp.opts.Name, by, cp.traceId)
+	e.Reason = fmt.Sprintf("%s, reason: %s", by, strings.TrimSpace(reason))
+	cp.Audit(e)
+	cp.lock.Lock()
+	cp.elevated = e.Reason
+	cp.lock.Unlock()
+	return nil
+}
+
+func (cp *CommandPolicy) elevate(runAs, reason string) (string, error) {
+	if reason == "" {
+		return "", fmt.Errorf("a reason is required to run as %s", runAs)
+	}
+	if config.OpsConfig.Policy.Enabled && cp.ud != nil {
+		opts := *cp.opts
+		opts.User = runAs
+		if d, err := AuthorizeIdentity(cp.ud, &opts, cp.action, cp.redisClient, cp.traceId); err == nil {
+			return fmt.Sprintf("allowed by role %s", d.Role), nil
+		}
+	}
+	if cp.elevation != nil && cp.ud != nil {
+		r, err := cp.elevation(cp.ud.UserName, cp.opts.Rule, cp.opts.Name, runAs)
+		if err != nil {
+			log.Logger.Warnf("failed to load approval grants, err=%s, traceId=%s", err.Error(), cp.traceId)
+		}
+		if r != nil {
+			return fmt.Sprintf("granted by approval request %s approved by %s until %s",
+				r.Id, r.Approver, r.Expires.Format("2006-01-02 15:04:05")), nil
+		}
+	}
+	return "", fmt.Errorf("permission denied, run as %s is not allowed, request an approval with: "+
+		"dockin-opsctl approval request %s -r %s --run-as %s --duration 30m --reason '<reason>'",
+		runAs, cp.opts.Name, cp.opts.Rule, runAs)
+}
+
+// Elevation returns how the request is allowed to run as root, with its
+// reason, or empty if it runs as the default user.
+func (cp *CommandPolicy) Elevation() string {
+	cp.lock.RLock()
+	defer cp.lock.RUnlock()
+	return cp.elevated
+}
+
+// Audit records the event, a failure to record it does not stop the request.
+func (cp *CommandPolicy) Audit(e *audit.Event) {
+	if cp.record == nil {
+		return
+	}
+	if err := cp.record(e); err != nil {
+		log.Logger.Warnf("failed to audit %s of %s, err=%s, traceId=%s", e.Type, cp.opts.UserName, err.Error(), cp.traceId)
+	}
+}
+
+// AuditCommand records a command line typed in the shell session of the
+// request, err, audited and granted are the verdict of the filters.
+func (cp *CommandPolicy) AuditCommand(cmd, cwd string, err error, audited, granted []string) *audit.Event {
+	e := NewAuditEvent(audit.EventCommand, cp.action, cp.opts, cp.clientIp, cp.traceId)
+	e.Command, e.Cwd = cmd, cwd
+	if err != nil {
+		e.Verdict, e.Reason = audit.VerdictDenied, err.Error()
+	} else if len(granted) > 0 {
+		e.Verdict, e.Reason = audit.VerdictGranted, strings.Join(granted, "; ")
+	} else if len(audited) > 0 {
+		e.Verdict, e.Reason = audit.VerdictAudited, strings.Join(audited, "; ")
+	}
+	cp.Audit(e)
+	return e
+}
+
+// AuditExit records the exit status of the command of the event in a
+// command-exit event, the command event is not recorded again.
+func (cp *CommandPolicy) AuditExit(e *audit.Event, status int) {
+	exit := NewAuditEvent(audit.EventCommandExit, cp.action, cp.opts, cp.clientIp, cp.traceId)
+	exit.Ref, exit.Command, exit.Cwd, exit.Verdict = e.Id, e.Command, e.Cwd, e.Verdict
+	exit.ExitCode = &status
+	cp.Audit(exit)
+}
+
+// AuditSession records the start or end of the session of the request.
+func (cp *CommandPolicy) AuditSession(typ string) {
+	cp.Audit(NewAuditEvent(typ, cp.action, cp.opts, cp.clientIp, cp.traceId))
+}
+
+// Filters returns the filters of the policy, dir returns the directory
+// commands are run in.
+func (cp *CommandPolicy) Filters(dir remote.FuncGetCurrentWorkDir) []remote.Filter {
+	filters := []remote.Filter{remote.NewBlacklistFilter(cp.deny)}
+	if config.OpsConfig.CmdFilterType != remote.BlacklistMode {
+		filters = append(filters, remote.NewWhitelistFilter(cp.allow))
+	}
+	// the role and the forbidden exec commands are not subject to the filter mode
+	filters = append(filters, remote.NewEnforcedFilter(remote.NewWhitelistFilter(cp.limit)))
+	if cp.action == policy.ActionExec || cp.action == policy.ActionInteract {
+		filters = append(filters, remote.NewEnforcedFilter(remote.NewBlacklistFilter(func() []string {
+			return config.OpsConfig.Limits.ExecForbidden
+		})))
+	}
+	return append(filters, remote.NewRuleFilter(cp.rules, dir))
+}
+
+// Reload loads the part of the policy the topic refers to. Data which can not
+// be loaded keeps its previous value, except the filter mode which falls back
+// to enforce.
+func (cp *CommandPolicy) Reload(topic string) {
+	switch topic {
+	case notify.TopicPolicy:
+		cp.reauthorize()
+	case notify.TopicCommands:
+		if cmds, err := cp.store.SessionCommands(cp.opts.Rule, cp.opts.UserName); err != nil {
+			log.Logger.Warnf("failed to reload command sets, err=%v, traceId=%s", err, cp.traceId)
+		} else {
+			cp.lock.Lock()
+			cp.cmds = cmds
+			cp.lock.Unlock()
+		}
+		// the commands the role allows come from its command sets
+		cp.reauthorize()
+	case notify.TopicFilterMode:
+		mode, err := cp.store.FilterMode(cp.opts.Rule)
+		if err != nil {
+			log.Logger.Warnf("failed to reload filter mode, use %s, err=%v, traceId=%s", mode, err, cp.traceId)
+		}
+		cp.lock.Lock()
+		cp.mode = mode
+		cp.lock.Unlock()
+	}
+	log.Logger.Infof("reload command policy of %s, traceId=%s", topic, cp.traceId)
+}
+
+// reauthorize evaluates the request again with the current roles and command
+// sets, the decision is kept if it can not be evaluated.
+func (cp *CommandPolicy) reauthorize() {
+	// an elevated request is authorized as the default user it started
+	// with, the elevation is not checked again
+	opts := cp.opts
+	if cp.Elevation() != "" {
+		o := *cp.opts
+		o.User = policy.DefaultRunAsUser
+		opts = &o
+	}
+	d, err := AuthorizeIdentity(cp.ud, opts, cp.action, cp.redisClient, cp.traceId)
+	if d == nil {
+		log.Logger.Warnf("failed to reload policy, err=%v, traceId=%s", err, cp.traceId)
+		return
+	}
+	cp.lock.Lock()
+	cp.decision = d
+	cp.lock.Unlock()
+}
+
+func (cp *CommandPolicy) Decision() *policy.Decision {
+	cp.lock.RLock()
+	defer cp.lock.RUnlock()
+	return cp.decision
+}
+
+func (cp *CommandPolicy) Commands() *policy.SessionCommands {
+	cp.lock.RLock()
+	defer cp.lock.RUnlock()
+	return cp.cmds
+}
+
+func (cp *CommandPolicy) FilterMode() string {
+	cp.lock.RLock()
+	defer cp.lock.RUnlock()
+	return cp.mode
+}
+
+func (cp *CommandPolicy) allow() []string {
+	return cp.Commands().Allow
+}
+
+func (cp *CommandPolicy) deny() []string {
+	return cp.Commands().Deny
+}
+
+// limit returns the commands the role of the user allows, no command is
+// allowed once the role no longer allows the request.
+func (cp *CommandPolicy) limit() []string {
+	d := cp.Decision()
+	if !d.Allowed {
+		return []string{}
+	}
+	if d.AllCommands {
+		return []string{policy.Wildcard}
+	}
+	return d.Commands
+}
+
+func (cp *CommandPolicy) workDir() string {
+	return cp.opts.WorkDir
+}
+
+func (cp *CommandPolicy) RecordViolation(cmd, reason string) {
+	if err := cp.store.RecordViolation(cp.opts.Rule, cmd, reason); err != nil {
+		log.Logger.Warnf("failed to record violation of %s, err=%s, traceId=%s", cmd, err.Error(), cp.traceId)
+	}
+}
+
+func (cp *CommandPolicy) commandRules() ([]*policy.CommandRule, error) {
+	rules, err := cp.store.ListCommandRules()
+	if err != nil {
+		log.Logger.Warnf("failed to load command rules, err=%s, traceId=%s", err.Error(), cp.traceId)
+	}
+	return rules, err
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/approval"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/remote"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPolicyOption func(cp *CommandPolicy)
+
+func withAction(action string) testPolicyOption {
+	return func(cp *CommandPolicy) { cp.action = action }
+}
+
+func withDecision(d *policy.Decision) testPolicyOption {
+	return func(cp *CommandPolicy) { cp.decision = d }
+}
+
+func withEvents(events *[]*audit.Event) testPolicyOption {
+	return func(cp *CommandPolicy) {
+		cp.record = func(e *audit.Event) error {
+			if e.Id == "" {
+				e.Id = fmt.Sprintf("e%d", len(*events))
+			}
+			*events = append(*events, e)
+			return nil
+		}
+	}
+}
+
+func withApproval(r *approval.Request) testPolicyOption {
+	return func(cp *CommandPolicy) {
+		cp.grant = func(user, rule, pod, cmdLine string) (*approval.Request, error) {
+			if r.Grants(user, rule, pod, cmdLine, time.Now()) {
+				return r, nil
+			}
+			return nil, nil
+		}
+		cp.elevation = func(user, rule, pod, runAs string) (*approval.Request, error) {
+			if r.Elevates(user, rule, pod, runAs, time.Now()) {
+				return r, nil
+			}
+			return nil, nil
+		}
+	}
+}
+
+// newTestPolicy returns an enforcing exec policy for alice on web-0 which
+// allows every command of the session lists, adjusted by the options.
+func newTestPolicy(t *testing.T, opts ...testPolicyOption) *CommandPolicy {
+	t.Helper()
+	cp := &CommandPolicy{
+		ud:       &model.UserIdentity{UserName: "alice"},
+		opts:     &model.OpsOption{Rule: "default", UserName: "alice", Name: "web-0"},
+		action:   policy.ActionExec,
+		traceId:  "trace",
+		clientIp: "10.0.0.1",
+		rules:    func() ([]*policy.CommandRule, error) { return nil, nil },
+		record:   func(e *audit.Event) error { return nil },
+		decision: &policy.Decision{Allowed: true, AllCommands: true},
+		cmds:     &policy.SessionCommands{Allow: []string{"ls", "sh", "grep", "vi", "cat"}, Deny: []string{"reboot"}},
+		mode:     policy.FilterModeEnforce,
+	}
+	for _, opt := range opts {
+		opt(cp)
+	}
+	return cp
+}
+
+func TestCommandPolicyCheck(t *testing.T) {
+	filterType, forbidden := config.OpsConfig.CmdFilterType, config.OpsConfig.Limits.ExecForbidden
+	defer func() {
+		config.OpsConfig.CmdFilterType, config.OpsConfig.Limits.ExecForbidden = filterType, forbidden
+	}()
+	config.OpsConfig.CmdFilterType = remote.WhitelistMode
+	config.OpsConfig.Limits.ExecForbidden = []string{"vi"}
+
+	cp := newTestPolicy(t)
+	assert.NoError(t, cp.Check([]string{"ls", "-l"}))
+	assert.NoError(t, cp.Check([]string{"/bin/sh", "-c", "ls /data | grep log"}))
+	assert.Error(t, cp.Check([]string{"/bin/sh", "-c", "rm -rf /data"}))
+	assert.Error(t, cp.Check([]string{"sh", "-c", "ls; reboot"}))
+	assert.Error(t, cp.Check([]string{"sh", "-c", "vi /etc/hosts"}))
+	assert.Error(t, cp.Check([]string{"sh", "-c", "$CMD"}))
+
+	cp = newTestPolicy(t, withAction(policy.ActionSSH))
+	assert.Len(t, cp.Filters(cp.workDir), 4)
+	assert.NoError(t, cp.Check([]string{"vi", "/etc/hosts"}))
+
+	cp = newTestPolicy(t, withDecision(&policy.Decision{Allowed: true, Commands: []string{"ls", "sh"}}))
+	assert.NoError(t, cp.Check([]string{"sh", "-c", "ls"}))
+	assert.Error(t, cp.Check([]string{"sh", "-c", "cat /etc/hosts"}))
+
+	config.OpsConfig.CmdFilterType = remote.BlacklistMode
+	cp = newTestPolicy(t)
+	assert.NoError(t, cp.Check([]string{"sh", "-c", "rm -rf /data"}))
+	assert.Error(t, cp.Check([]string{"sh", "-c", "reboot"}))
+}
+
+func TestCommandPolicyAudit(t *testing.T) {
+	var events []*audit.Event
+	cp := newTestPolicy(t, withEvents(&events))
+
+	assert.NoError(t, cp.Check([]string{"ls", "-l"}))
+	assert.Error(t, cp.Check([]string{"sh", "-c", "reboot"}))
+	cp.AuditSession(audit.EventSessionEnd)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, audit.EventCommand, events[0].Type)
+		assert.Equal(t, "ls -l", events[0].Command)
+		assert.Equal(t, audit.VerdictAllowed, events[0].Verdict)
+		assert.Equal(t, "alice", events[0].User)
+		assert.Equal(t, "10.0.0.1", events[0].SourceIP)
+		assert.Equal(t, audit.VerdictDenied, events[1].Verdict)
+		assert.NotEmpty(t, events[1].Reason)
+		assert.Equal(t, audit.EventSessionEnd, events[2].Type)
+		assert.Equal(t, "trace", events[2].TraceId)
+	}
+
+	e := cp.AuditCommand("reboot", "/data", nil, []string{"command [reboot] is not allowd to exec"}, nil)
+	assert.Equal(t, audit.VerdictAudited, e.Verdict)
+	assert.Equal(t, "/data", e.Cwd)
+	assert.Equal(t, "reboot", e.Command)
+	assert.Len(t, events, 4)
+
+	cp.AuditExit(e, 2)
+	assert.Nil(t, e.ExitCode)
+	if assert.Len(t, events, 5) {
+		exit := events[4]
+		assert.Equal(t, audit.EventCommandExit, exit.Type)
+		assert.Equal(t, e.Id, exit.Ref)
+		assert.NotEqual(t, e.Id, exit.Id)
+		assert.Equal(t, "reboot", exit.Command)
+		assert.Equal(t, audit.VerdictAudited, exit.Verdict)
+		if assert.NotNil(t, exit.ExitCode) {
+			assert.Equal(t, 2, *exit.ExitCode)
+		}
+	}
+}
+
+func TestCommandPolicyGrant(t *testing.T) {
+	var events []*audit.Event
+	grant := &approval.Request{Id: "a1", User: "alice", Rule: "default", Pod: "web-0", Pattern: "reboot*",
+		Status: approval.StatusApproved, Approver: "bob", Expires: time.Now().Add(time.Hour)}
+	cp := newTestPolicy(t, withEvents(&events), withApproval(grant))
+
+	assert.NoError(t, cp.Check([]string{"reboot", "-f"}))
+	assert.Error(t, cp.Check([]string{"kill", "1"}))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, audit.VerdictGranted, events[0].Verdict)
+		assert.Contains(t, events[0].Reason, "approval request a1 approved by bob")
+		assert.Equal(t, audit.VerdictDenied, events[1].Verdict)
+	}
+
+	cp.action = policy.ActionSSH
+	_, err := cp.Grant("kill 1", fmt.Errorf("command [kill] is not allowd to exec"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "dockin-opsctl approval request web-0 -r default")
+	}
+
+	e := cp.AuditCommand("reboot", "/data", nil, nil, []string{"granted by approval request a1"})
+	assert.Equal(t, audit.VerdictGranted, e.Verdict)
+
+	// the user name the client claims is not granted without a valid token
+	cp.ud = nil
+	_, err = cp.Grant("reboot -f", fmt.Errorf("command [reboot] is not allowd to exec"))
+	assert.Error(t, err)
+}
+
+func TestCommandPolicyElevate(t *testing.T) {
+	var events []*audit.Event
+	grant := &approval.Request{Id: "a2", User: "alice", Rule: "default", Pod: "web-0", RunAs: "root",
+		Status: approval.StatusApproved, Approver: "bob", Expires: time.Now().Add(time.Hour)}
+	cp := newTestPolicy(t, withAction(policy.ActionSSH), withEvents(&events), withApproval(grant))
+	cp.opts.User = "app"
+
+	assert.Error(t, cp.Elevate("root", " "))
+	cp.opts.Name = "web-1"
+	err := cp.Elevate("root", "fix disk")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "dockin-opsctl approval request web-1 -r default --run-as root")
+	}
+	assert.Empty(t, cp.Elevation())
+
+	cp.opts.Name = "web-0"
+	assert.NoError(t, cp.Elevate("root", "fix disk"))
+	assert.Contains(t, cp.Elevation(), "approval request a2 approved by bob")
+	if assert.Len(t, events, 3) {
+		for _, e := range events {
+			assert.Equal(t, audit.EventElevate, e.Type)
+			assert.Equal(t, "root", e.RunAs)
+		}
+		assert.Equal(t, audit.VerdictDenied, events[0].Verdict)
+		assert.Equal(t, audit.VerdictDenied, events[1].Verdict)
+		assert.Equal(t, audit.VerdictAllowed, events[2].Verdict)
+		assert.Contains(t, events[2].Reason, "reason: fix disk")
+	}
+
+	cp.ud = nil
+	assert.Error(t, cp.Elevate("root", "fix disk"))
+}
+
+func TestCommandPolicyReportExit(t *testing.T) {
+	var events []*audit.Event
+	cp := newTestPolicy(t, withEvents(&events))
+
+	assert.NoError(t, cp.Check([]string{"grep", "x", "/data/app.log"}))
+	cp.ReportExit(1)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, audit.EventCommand, events[0].Type)
+		assert.Nil(t, events[0].ExitCode)
+		assert.Equal(t, audit.EventCommandExit, events[1].Type)
+		assert.Equal(t, events[0].Id, events[1].Ref)
+		assert.Equal(t, "grep x /data/app.log", events[1].Command)
+		if assert.NotNil(t, events[1].ExitCode) {
+			assert.Equal(t, 1, *events[1].ExitCode)
+		}
+	}
+	cp.ReportExit(0)
+	assert.Len(t, events, 2)
+
+	assert.Error(t, cp.Check([]string{"reboot"}))
+	cp.ReportExit(0)
+	assert.Len(t, events, 3)
+
+	// every event is recorded, and so forwarded, exactly once
+	recorded := map[string]int{}
+	for _, e := range events {
+		recorded[e.Id]++
+	}
+	assert.Len(t, recorded, len(events))
+}
+
+func TestCommandPolicyReloadCommands(t *testing.T) {
+	rc, err := redis.NewRedisClient()
+	assert.NoError(t, err)
+	if err := rc.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+	enabled := config.OpsConfig.Policy.Enabled
+	config.OpsConfig.Policy.Enabled = true
+	defer func() { config.OpsConfig.Policy.Enabled = enabled }()
+
+	store := policy.NewStore(rc)
+	cs := &policy.CommandSet{Name: "test-reload", Commands: []string{"ls"}}
+	assert.NoError(t, store.SaveCommandSet(cs))
+	assert.NoError(t, store.SaveRole(&policy.Role{Name: "test-reload", Pods: []string{"web-0"},
+		RunAsUsers: []string{policy.DefaultRunAsUser}, CommandSets: []string{cs.Name}}))
+	assert.NoError(t, store.SaveBinding(&policy.Binding{Name: "test-reload", Role: "test-reload", Users: []string{"test-reload"}}))
+	defer func() {
+		store.DeleteBinding("test-reload")
+		store.DeleteRole("test-reload")
+		store.DeleteCommandSet(cs.Name)
+	}()
+
+	ud := &model.UserIdentity{UserName: "test-reload"}
+	opts := &model.OpsOption{Rule: "default", Name: "web-0", User: policy.DefaultRunAsUser}
+	d, err := AuthorizeIdentity(ud, opts, policy.ActionExec, rc, "trace")
+	assert.NoError(t, err)
+	cp, err := NewCommandPolicy(rc, ud, opts, policy.ActionExec, d, "trace")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ls"}, cp.limit())
+
+	cs.Commands = []string{"ls", "cat"}
+	assert.NoError(t, store.SaveCommandSet(cs))
+	cp.Reload(notify.TopicCommands)
+	assert.ElementsMatch(t, []string{"ls", "cat"}, cp.limit())
+}
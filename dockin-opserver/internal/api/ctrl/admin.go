@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/webankfintech/dockin-opserver/internal/api"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/token"
+	"github.com/webankfintech/dockin-opserver/internal/utils/ip"
+	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
+)
+
+const ctrlPath = "/v1/dockin/opserver/ctrl"
+
+type adminContextKey struct{}
+
+type adminContext struct {
+	ud      *model.UserIdentity
+	traceId string
+}
+
+func (c *Control) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		traceId := trace.TraceID()
+		ud, err := api.ValidateAdmin(request, c.redisClient, traceId)
+		if err != nil {
+			log.Logger.Warnf("reject admin request %s from %s, err=%s,traceId=%s",
+				request.URL.Path, ip.GetIp(request), err.Error(), traceId)
+			status := http.StatusUnauthorized
+			if err == api.ErrNotAdmin {
+				status = http.StatusForbidden
+			}
+			writer.WriteHeader(status)
+			writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+			return
+		}
+
+		log.Logger.Infof("admin request %s, userName=%s,traceId=%s", request.URL.Path, ud.UserName, traceId)
+		ctx := context.WithValue(request.Context(), adminContextKey{}, &adminContext{ud: ud, traceId: traceId})
+		h(writer, request.WithContext(ctx))
+	}
+}
+
+// requireUser lets any user with a valid access token in, the identity is
+// kept in the same context as the one of admins.
+func (c *Control) requireUser(h http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		traceId := trace.TraceID()
+		ud, err := api.ValidateAccessToken(api.AccessToken(request), traceId, c.redisClient)
+		if err != nil {
+			log.Logger.Warnf("reject request %s from %s, err=%s,traceId=%s",
+				request.URL.Path, ip.GetIp(request), err.Error(), traceId)
+			writer.WriteHeader(http.StatusUnauthorized)
+			writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+			return
+		}
+
+		log.Logger.Infof("request %s, userName=%s,traceId=%s", request.URL.Path, ud.UserName, traceId)
+		ctx := context.WithValue(request.Context(), adminContextKey{}, &adminContext{ud: ud, traceId: traceId})
+		h(writer, request.WithContext(ctx))
+	}
+}
+
+// record adds a mutation to the admin audit trail, err is the result of the
+// mutation and nil means success.
+func (c *Control) record(request *http.Request, action, target, before, after string, err error) {
+	e := &audit.AdminEvent{
+		SourceIP: ip.GetIp(request),
+		Action:   action,
+		Target:   target,
+		Before:   before,
+		After:    after,
+		Result:   "success",
+	}
+	if ac, ok := request.Context().Value(adminContextKey{}).(*adminContext); ok {
+		e.Actor = ac.ud.UserName
+		e.TraceId = ac.traceId
+	}
+	if err != nil {
+		e.Result = err.Error()
+	}
+	c.adminTrail.Record(e)
+}
+
+func (c *Control) ManagerLogin(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	request.ParseForm()
+	if request.Method != http.MethodPost {
+		c.loginTmpl.Execute(writer, "")
+		return
+	}
+
+	userName := request.Form.Get("userName")
+	id, err := c.auth.Authenticate(request.Context(), request.Form.Get("provider"), userName, request.Form.Get("password"))
+	if err != nil {
+		log.Logger.Warnf("manager login failed,userName=%s,err=%s,traceId=%s", userName, err.Error(), traceId)
+		c.loginTmpl.Execute(writer, err.Error())
+		return
+	}
+	ac, err := newUserIdentity(id, "default")
+	if err != nil {
+		log.Logger.Warnf("failed to create access token string, userName=%s, err=%s,traceId=%s", userName, err.Error(), traceId)
+		c.loginTmpl.Execute(writer, "create access token failed")
+		return
+	}
+
+	e := &audit.AdminEvent{TraceId: traceId, Actor: userName, SourceIP: ip.GetIp(request), Action: "managerLogin", Result: "success"}
+	if !api.IsAdmin(ac) {
+		e.Result = api.ErrNotAdmin.Error()
+		c.adminTrail.Record(e)
+		log.Logger.Warnf("manager login rejected, userName=%s is not admin,traceId=%s", userName, traceId)
+		c.loginTmpl.Execute(writer, api.ErrNotAdmin.Error())
+		return
+	}
+	c.adminTrail.Record(e)
+
+	http.SetCookie(writer, &http.Cookie{
+		Name:     api.AccessTokenName,
+		Value:    ac.AccessToken,
+		Path:     ctrlPath,
+		MaxAge:   int(token.Expire()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	log.Logger.Infof("manager login success, userName=%s,traceId=%s", userName, traceId)
+	http.Redirect(writer, request, ctrlPath+"/manager", http.StatusFound)
+}
+
+func (c *Control) GetAdminAudit(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	offset, _ := strconv.ParseInt(request.Form.Get("offset"), 10, 64)
+	limit, _ := strconv.ParseInt(request.Form.Get("limit"), 10, 64)
+
+	events, err := c.adminTrail.List(offset, limit)
+	if err != nil {
+		log.Logger.Warnf("failed to list admin audit events, err=%s", err.Error())
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to list admin audit events")).ToByte())
+		return
+	}
+	writer.Write(model.SuccessOpsResult(events).ToByte())
+}
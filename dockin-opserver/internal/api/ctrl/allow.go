@@ -76,3 +76,29 @@ func (b *AllowCmd) RemoveCommon(cmd []string) error {
 
 	return nil
 }
+
+func (b *AllowCmd) LoadBlacklist() []string {
+	cmds, err := b.redisClient.SMembers(keys.GetBlacklistCmdRedisKey())
+	if err != nil {
+		log.Logger.Warnf(err.Error())
+	}
+	return cmds
+}
+
+func (b *AllowCmd) AddBlacklist(cmd []string) error {
+	if err := b.redisClient.SAdd(keys.GetBlacklistCmdRedisKey(), cmd); err != nil {
+		log.Logger.Warnf(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (b *AllowCmd) RemoveBlacklist(cmd []string) error {
+	if err := b.redisClient.SRem(keys.GetBlacklistCmdRedisKey(), cmd); err != nil {
+		log.Logger.Warnf(err.Error())
+		return err
+	}
+
+	return nil
+}
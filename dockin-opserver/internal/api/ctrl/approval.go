@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/api"
+	"github.com/webankfintech/dockin-opserver/internal/approval"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// RequestApproval asks for a grant of the commands matching the pattern, or
+// of the elevation to the run as user, on a pod the user may ssh into.
+func (c *Control) RequestApproval(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	ac := request.Context().Value(adminContextKey{}).(*adminContext)
+	duration, err := time.ParseDuration(request.Form.Get("duration"))
+	if err != nil {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("invalid duration %s", request.Form.Get("duration"))).ToByte())
+		return
+	}
+
+	opts := &model.OpsOption{
+		Name:      request.Form.Get("pod"),
+		Namespace: request.Form.Get("namespace"),
+		Rule:      request.Form.Get("rule"),
+	}
+	if opts.Name == "" {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("pod is empty")).ToByte())
+		return
+	}
+	if err := api.SetPodOption(opts); err != nil {
+		log.Logger.Warnf("failed to get pod %s, err=%s,traceId=%s", opts.Name, err.Error(), ac.traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to get pod %s", opts.Name)).ToByte())
+		return
+	}
+	if _, err := api.AuthorizeIdentity(ac.ud, opts, policy.ActionSSH, c.redisClient, ac.traceId); err != nil {
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write(model.FailedOpsResult(err).ToByte())
+		return
+	}
+
+	r := &approval.Request{
+		User:      ac.ud.UserName,
+		Rule:      opts.Rule,
+		Subsystem: opts.SubSystem,
+		Namespace: opts.Namespace,
+		Pod:       opts.Name,
+		Pattern:   request.Form.Get("command"),
+		RunAs:     request.Form.Get("runAs"),
+		Reason:    request.Form.Get("reason"),
+		Duration:  int64(duration / time.Second),
+	}
+	err = c.approvals.Create(r)
+	after, _ := jsoniter.MarshalToString(r)
+	c.record(request, "requestApproval", r.Id, "", after, err)
+	if err != nil {
+		log.Logger.Warnf("failed to create approval request, user=%s, err=%s,traceId=%s", r.User, err.Error(), ac.traceId)
+		writer.Write(model.FailedOpsResult(err).ToByte())
+		return
+	}
+	log.Logger.Infof("approval request %s is created, user=%s, pod=%s,traceId=%s", r.Id, r.User, r.Pod, ac.traceId)
+	writer.Write(model.SuccessOpsResult(r).ToByte())
+}
+
+// GetApprovals lists the requests of the user and the requests the user may
+// decide, optionally of one status.
+func (c *Control) GetApprovals(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	ac := request.Context().Value(adminContextKey{}).(*adminContext)
+	status := request.Form.Get("status")
+	list, err := c.approvals.List()
+	if err != nil {
+		log.Logger.Warnf("failed to list approval requests, err=%s,traceId=%s", err.Error(), ac.traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to list approval requests")).ToByte())
+		return
+	}
+
+	now := time.Now()
+	result := make([]*approval.Request, 0)
+	for _, r := range list {
+		r.Status = r.State(now)
+		if status != "" && r.Status != status {
+			continue
+		}
+		if r.User == ac.ud.UserName || c.mayApprove(ac, r) == nil {
+			result = append(result, r)
+		}
+	}
+	writer.Write(model.SuccessOpsResult(result).ToByte())
+}
+
+func (c *Control) Approve(writer http.ResponseWriter, request *http.Request) {
+	c.decide(writer, request, true)
+}
+
+func (c *Control) Reject(writer http.ResponseWriter, request *http.Request) {
+	c.decide(writer, request, false)
+}
+
+// decide approves or rejects a pending request of another user, the approver
+// must be allowed to approve requests on its pod.
+func (c *Control) decide(writer http.ResponseWriter, request *http.Request, approve bool) {
+	request.ParseForm()
+	ac := request.Context().Value(adminContextKey{}).(*adminContext)
+	id := request.Form.Get("id")
+	action := "rejectApproval"
+	if approve {
+		action = "approveApproval"
+	}
+
+	r, err := c.approvals.Get(id)
+	if err == approval.ErrNotFound {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("approval request %s is not found", id)).ToByte())
+		return
+	}
+	if err != nil {
+		log.Logger.Warnf("failed to get approval request %s, err=%s,traceId=%s", id, err.Error(), ac.traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to get approval request %s", id)).ToByte())
+		return
+	}
+	if err := c.mayApprove(ac, r); err != nil {
+		c.record(request, action, id, "", "", err)
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write(model.FailedOpsResult(err).ToByte())
+		return
+	}
+
+	before, _ := jsoniter.MarshalToString(r)
+	r, err = c.approvals.Decide(id, ac.ud.UserName, approve)
+	after, _ := jsoniter.MarshalToString(r)
+	c.record(request, action, id, before, after, err)
+	if err != nil {
+		log.Logger.Warnf("failed to decide approval request %s, err=%s,traceId=%s", id, err.Error(), ac.traceId)
+		writer.Write(model.FailedOpsResult(err).ToByte())
+		return
+	}
+	log.Logger.Infof("approval request %s is %s by %s,traceId=%s", id, r.Status, ac.ud.UserName, ac.traceId)
+	writer.Write(model.SuccessOpsResult(r).ToByte())
+}
+
+// mayApprove checks that the user is not the requester and has a role which
+// allows approve on the pod, or is an admin when the policy is disabled.
+func (c *Control) mayApprove(ac *adminContext, r *approval.Request) error {
+	if r.User == ac.ud.UserName {
+		return approval.ErrSelf
+	}
+	if !config.OpsConfig.Policy.Enabled {
+		if api.IsAdmin(ac.ud) {
+			return nil
+		}
+		return api.ErrNotAdmin
+	}
+	opts := &model.OpsOption{SubSystem: r.Subsystem, Namespace: r.Namespace, Name: r.Pod}
+	_, err := api.AuthorizeIdentity(ac.ud, opts, policy.ActionApprove, c.redisClient, ac.traceId)
+	return err
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+)
+
+// GetAudit queries the audit events of sessions and commands, latest first.
+func (c *Control) GetAudit(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	q := &audit.Query{
+		User:      request.Form.Get("user"),
+		Pod:       request.Form.Get("pod"),
+		Subsystem: request.Form.Get("subsystem"),
+		Verdict:   request.Form.Get("verdict"),
+		Type:      request.Form.Get("type"),
+	}
+	q.Offset, _ = strconv.ParseInt(request.Form.Get("offset"), 10, 64)
+	q.Limit, _ = strconv.ParseInt(request.Form.Get("limit"), 10, 64)
+	var err error
+	if q.Since, err = parseTime(request.Form.Get("since")); err != nil {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("invalid since, %s", err.Error())).ToByte())
+		return
+	}
+	if q.Until, err = parseTime(request.Form.Get("until")); err != nil {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("invalid until, %s", err.Error())).ToByte())
+		return
+	}
+
+	events, err := c.audits.Query(q)
+	if err != nil {
+		log.Logger.Warnf("failed to query audit events, err=%s", err.Error())
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to query audit events")).ToByte())
+		return
+	}
+	writer.Write(model.SuccessOpsResult(events).ToByte())
+}
+
+// parseTime parses RFC 3339 times or unix seconds, empty is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -19,16 +19,23 @@ import (
 	"html/template"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/webankfintech/dockin-opserver/internal/common"
 
+	"github.com/webankfintech/dockin-opserver/internal/account"
 	"github.com/webankfintech/dockin-opserver/internal/api"
+	"github.com/webankfintech/dockin-opserver/internal/approval"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/auth"
 	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
 	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/client"
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/record"
+	"github.com/webankfintech/dockin-opserver/internal/token"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
@@ -41,12 +48,19 @@ import (
 
 type Control struct {
 	indexTmpl   *template.Template
+	loginTmpl   *template.Template
 	redisClient *redis.RedisClient
 	cm          *client.Manager
 	access      *Access
 	allow       *AllowCmd
-	account     *Account
+	account     *account.Store
+	auth        *auth.Manager
 	version     *Version
+	policy      *policy.Store
+	adminTrail  *audit.AdminTrail
+	audits      *audit.Store
+	recordings  record.Store
+	approvals   *approval.Store
 }
 
 func NewControl(cm *client.Manager, r *redis.RedisClient) *Control {
@@ -56,29 +70,73 @@ func NewControl(cm *client.Manager, r *redis.RedisClient) *Control {
 		log.Logger.Panicf(err.Error())
 	}
 	c.indexTmpl = indexTmpl
+	c.loginTmpl = template.Must(template.New("login").Parse(loginTemplate))
 	c.allow = &AllowCmd{redisClient: r}
 	c.access = &Access{redisClient: r}
-	c.account = &Account{redisClient: r}
+	c.account = account.NewStore(r)
+	c.account.MigrateFromConfig()
+	c.auth = auth.NewManager(c.account)
 	c.version = &Version{redisClient: r}
+	c.policy = policy.NewStore(r)
+	c.adminTrail = audit.NewAdminTrail(r)
+	c.audits = audit.NewStore(r)
+	c.recordings = record.NewStore(r)
+	c.approvals = approval.NewStore(r)
 	c.redisClient = r
 	c.cm = cm
 
 	http.HandleFunc("/v1/dockin/opserver/ctrl/manager", c.ManagerIndex)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/manager/login", c.ManagerLogin)
 
 	http.HandleFunc("/v1/dockin/opserver/ctrl/auth", c.Auth)
 	http.HandleFunc("/v1/dockin/opserver/ctrl/login", c.Login)
-
-	http.HandleFunc("/v1/dockin/opserver/ctrl/addRawCmd", c.AddRawCmd)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteRawCmd", c.DeleteRawCmd)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/addCmd", c.AddCmd)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteCmd", c.DeleteCmd)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteIP", c.DeleteIP)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/addWhitelist", c.AddWhitelist)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/getCmdList", c.GetCmdList)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/logout", c.Logout)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/oidc/login", c.OIDCLogin)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/oidc/callback", c.OIDCCallback)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/oidc/device", c.OIDCDevice)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/oidc/deviceToken", c.OIDCDeviceToken)
 	http.HandleFunc("/v1/dockin/opserver/ctrl/getPodByName", c.GetPodByName)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/addAccount", c.AddAccount)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteAccount", c.DeleteAccount)
-	http.HandleFunc("/v1/dockin/opserver/ctrl/updateVersion", c.UpdateVersion)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/changePassword", c.ChangePassword)
+	http.HandleFunc("/v1/dockin/opserver/ctrl/requestApproval", c.requireUser(c.RequestApproval))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getApprovals", c.requireUser(c.GetApprovals))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/approve", c.requireUser(c.Approve))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/reject", c.requireUser(c.Reject))
+
+	http.HandleFunc("/v1/dockin/opserver/ctrl/addRawCmd", c.requireAdmin(c.AddRawCmd))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteRawCmd", c.requireAdmin(c.DeleteRawCmd))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/addCmd", c.requireAdmin(c.AddCmd))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteCmd", c.requireAdmin(c.DeleteCmd))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/addBlacklistCmd", c.requireAdmin(c.AddBlacklistCmd))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteBlacklistCmd", c.requireAdmin(c.DeleteBlacklistCmd))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteIP", c.requireAdmin(c.DeleteIP))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/addWhitelist", c.requireAdmin(c.AddWhitelist))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getCmdList", c.requireAdmin(c.GetCmdList))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/addAccount", c.requireAdmin(c.AddAccount))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/updateAccount", c.requireAdmin(c.UpdateAccount))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteAccount", c.requireAdmin(c.DeleteAccount))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/updateVersion", c.requireAdmin(c.UpdateVersion))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getPolicy", c.requireAdmin(c.GetPolicy))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/saveRole", c.requireAdmin(c.SaveRole))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteRole", c.requireAdmin(c.DeleteRole))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/saveBinding", c.requireAdmin(c.SaveBinding))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteBinding", c.requireAdmin(c.DeleteBinding))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getCommandRules", c.requireAdmin(c.GetCommandRules))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/saveCommandRule", c.requireAdmin(c.SaveCommandRule))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteCommandRule", c.requireAdmin(c.DeleteCommandRule))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getCommandSets", c.requireAdmin(c.GetCommandSets))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/saveCommandSet", c.requireAdmin(c.SaveCommandSet))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/deleteCommandSet", c.requireAdmin(c.DeleteCommandSet))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getFilterModes", c.requireAdmin(c.GetFilterModes))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/setFilterMode", c.requireAdmin(c.SetFilterMode))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getFilterReport", c.requireAdmin(c.GetFilterReport))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/resetFilterReport", c.requireAdmin(c.ResetFilterReport))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getAdminAudit", c.requireAdmin(c.GetAdminAudit))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getAudit", c.requireAdmin(c.GetAudit))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getRecordings", c.requireAdmin(c.GetRecordings))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getRecording", c.requireAdmin(c.GetRecording))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/getSessions", c.requireAdmin(c.GetSessions))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/killSession", c.requireAdmin(c.KillSession))
+	http.HandleFunc("/v1/dockin/opserver/ctrl/watchSession", c.requireAdmin(c.WatchSession))
 	return c
 }
 
@@ -90,7 +148,15 @@ type PodInfo struct {
 }
 
 func (c *Control) ManagerIndex(writer http.ResponseWriter, request *http.Request) {
-	data := &model.ControlDTO{}
+	traceId := trace.TraceID()
+	ud, err := api.ValidateAdmin(request, c.redisClient, traceId)
+	if err != nil {
+		log.Logger.Infof("manager page requires admin login, err=%s,traceId=%s", err.Error(), traceId)
+		c.loginTmpl.Execute(writer, "")
+		return
+	}
+
+	data := &model.ControlDTO{UserName: ud.UserName, ResKey: common.ResKey}
 	rawCmd, commonCmd := c.allow.LoadFromCache()
 
 	for _, c := range commonCmd {
@@ -99,6 +165,9 @@ func (c *Control) ManagerIndex(writer http.ResponseWriter, request *http.Request
 	for _, c := range rawCmd {
 		data.RawCommand = append(data.RawCommand, model.CMD{Cmd: c})
 	}
+	for _, cmd := range c.allow.LoadBlacklist() {
+		data.BlacklistCommand = append(data.BlacklistCommand, model.CMD{Cmd: cmd})
+	}
 
 	d := c.access.LoadFromCache()
 	for rule, ipList := range d {
@@ -114,14 +183,20 @@ func (c *Control) ManagerIndex(writer http.ResponseWriter, request *http.Request
 		})
 	}
 
-	userList := c.account.LoadAccountFromCache()
+	userList, err := c.account.List()
+	if err != nil {
+		log.Logger.Warnf("failed to get all account, err=%s", err.Error())
+	}
 	for _, user := range userList {
 		data.Account = append(data.Account, model.Account{UserName: user})
 	}
 
 	data.Version = c.version.LoadVersionFromCache()
+	if data.Audit, err = c.adminTrail.List(0, 20); err != nil {
+		log.Logger.Warnf("failed to list admin audit events, err=%s", err.Error())
+	}
 
-	log.Logger.Infof("success get manager index %+v", data)
+	log.Logger.Infof("success get manager index %+v,traceId=%s", data, traceId)
 	c.indexTmpl.Execute(writer, data)
 }
 
@@ -137,25 +212,18 @@ func (c *Control) Login(writer http.ResponseWriter, request *http.Request) {
 }
 
 func (c *Control) loginByAccessToken(writer http.ResponseWriter, token, traceId string) {
-	log.Logger.Infof("start to loginByAccessToken token=%s,traceId=%s", token, traceId)
-	ud, err := api.ParseAccessToken(token, traceId)
+	log.Logger.Infof("start to loginByAccessToken,traceId=%s", traceId)
+	ud, err := api.ValidateAccessToken(token, traceId, c.redisClient)
 	if err != nil {
-		log.Logger.Warnf("ParseAccessToken failed,err=%s,traceId=%s", err.Error(), traceId)
+		log.Logger.Warnf("ValidateAccessToken failed,err=%s,traceId=%s", err.Error(), traceId)
 		result := model.FailedOpsResult(errors.Errorf("%s,traceId=%s", err.Error(), traceId))
 		writer.Write(result.ToByte())
 		return
 	}
-	if int64(time.Now().Sub(ud.CreateTime).Seconds()) > ud.Expire {
-		err := errors.Errorf("access token is expired, userName=%s, create at %v,traceId=%s", ud.UserName, ud.CreateTime, traceId)
-		log.Logger.Warnf("access token is expired, create at %v, token=%s,traceId=%s", ud.CreateTime, token, traceId)
-		result := model.FailedOpsResult(err)
-		writer.Write(result.ToByte())
-		return
-	}
 	ud.AccessToken = token
 	udstr, _ := jsoniter.MarshalToString(ud)
 	result := model.SuccessOpsResult(udstr)
-	log.Logger.Infof("loginByAccessToken success %s, user info=%#v,traceId=%s", ud, traceId)
+	log.Logger.Infof("loginByAccessToken success, userName=%s, tokenId=%s,traceId=%s", ud.UserName, ud.TokenId, traceId)
 	writer.Write(result.ToByte())
 }
 
@@ -174,16 +242,9 @@ func (c *Control) loginByAccount(writer http.ResponseWriter, request *http.Reque
 		writer.Write(model.FailedOpsResult(fmt.Errorf("password is empty,traceId=%s", traceId)).ToByte())
 		return
 	}
-	aes, err := aes.NewAes(common.ResKey)
-	if err != nil {
-		log.Logger.Warnf("NewAes failed err=%s,traceId=%s", err.Error(), traceId)
-		writer.Write(model.FailedOpsResult(fmt.Errorf("aes decode failed,traceId=%s", traceId)).ToByte())
-		return
-	}
-
-	password, err = aes.AesDecrypt(password)
+	password, err := decodePassword(password)
 	if err != nil {
-		log.Logger.Warnf("AesDecrypt err=%s,traceId=%s", err.Error(), traceId)
+		log.Logger.Warnf("decode password err=%s,traceId=%s", err.Error(), traceId)
 		writer.Write(model.FailedOpsResult(fmt.Errorf("aes decode failed,traceId=%s", traceId)).ToByte())
 		return
 	}
@@ -191,20 +252,19 @@ func (c *Control) loginByAccount(writer http.ResponseWriter, request *http.Reque
 	if rule == "" {
 		rule = "default"
 	}
-	if err := c.account.AccountAuth(userName, password, traceId); err != nil {
-		log.Logger.Warnf("AccountAuth fail,err=%s,traceId=%s", err.Error(), traceId)
+	id, err := c.auth.Authenticate(request.Context(), request.Form.Get("provider"), userName, password)
+	if err != nil {
+		log.Logger.Warnf("account validate failed,userName=%s,err=%s,traceId=%s", userName, err.Error(), traceId)
 		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
 		return
 	}
 
-	ac := model.NewUserIdentity(userName, password, rule)
-	acStr, err := ac.ToString()
+	ac, err := newUserIdentity(id, rule)
 	if err != nil {
-		log.Logger.Warnf("failed to create access token string, ac=%v, err=%v,traceId=%s", ac, err, traceId)
-		writer.Write(model.FailedOpsResult(fmt.Errorf("password is empty,traceId=%s", traceId)).ToByte())
+		log.Logger.Warnf("failed to create access token string, userName=%s, err=%v,traceId=%s", userName, err, traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("create access token failed,traceId=%s", traceId)).ToByte())
 		return
 	}
-	ac.AccessToken = acStr
 	udstr, _ := jsoniter.MarshalToString(ac)
 	result := model.SuccessOpsResult(udstr)
 	writer.Write(result.ToByte())
@@ -217,23 +277,16 @@ func (c *Control) Auth(writer http.ResponseWriter, request *http.Request) {
 	userName := request.Form.Get("userName")
 	password := request.Form.Get("password")
 	rule := request.Form.Get("rule")
-	log.Logger.Infof("recv auth request,userName=%s ,password=%s,rule=%s traceId=%s", userName, password, rule, traceId)
+	log.Logger.Infof("recv auth request,userName=%s,rule=%s traceId=%s", userName, rule, traceId)
 	if userName == "" {
 		log.Logger.Infof("request user name is empty,traceId=%s", traceId)
 		writer.Write(model.FailedOpsResult(fmt.Errorf("user name is empty,traceId=%s", traceId)).ToByte())
 		return
 	}
 
-	aes, err := aes.NewAes(common.ResKey)
+	password, err := decodePassword(password)
 	if err != nil {
-		log.Logger.Warnf("New aes failed err=%s,traceId=%s", err.Error(), traceId)
-		writer.Write(model.FailedOpsResult(fmt.Errorf("aes decode failed,traceId=%s", traceId)).ToByte())
-		return
-	}
-
-	password, err = aes.AesDecrypt(password)
-	if err != nil {
-		log.Logger.Warnf("aes decrypt err=%s,traceId=%s", err.Error(), traceId)
+		log.Logger.Warnf("decode password err=%s,traceId=%s", err.Error(), traceId)
 		writer.Write(model.FailedOpsResult(fmt.Errorf("aes decode failed,traceId=%s", traceId)).ToByte())
 		return
 	}
@@ -246,25 +299,64 @@ func (c *Control) Auth(writer http.ResponseWriter, request *http.Request) {
 		writer.Write(model.FailedOpsResult(fmt.Errorf("password is empty,traceId=%s", traceId)).ToByte())
 		return
 	}
-	if err := c.account.AccountAuth(userName, password, traceId); err != nil {
-		log.Logger.Warnf("account auth failed,err=%s,traceId=%s", err.Error(), traceId)
+	id, err := c.auth.Authenticate(request.Context(), request.Form.Get("provider"), userName, password)
+	if err != nil {
+		log.Logger.Warnf("account validate failed,userName=%s,err=%s,traceId=%s", userName, err.Error(), traceId)
 		writer.Write(model.FailedOpsResult(err).ToByte())
 		return
 	}
 
-	ac := model.NewUserIdentity(userName, password, rule)
-	acStr, err := ac.ToString()
+	ac, err := newUserIdentity(id, rule)
 	if err != nil {
-		log.Logger.Warnf("failed to create access token string, ac=%v, err=%s,traceId", ac, err.Error(), traceId)
-		writer.Write(model.FailedOpsResult(fmt.Errorf("password is empty,traceId=%s", traceId)).ToByte())
+		log.Logger.Warnf("failed to create access token string, userName=%s, err=%s,traceId=%s", userName, err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("create access token failed,traceId=%s", traceId)).ToByte())
 		return
 	}
 
-	result := model.SuccessOpsResult(acStr)
-	log.Logger.Infof("success create access token for user name=%s, access token=%s,traceId=%s", userName, acStr, traceId)
+	result := model.SuccessOpsResult(ac.AccessToken)
+	log.Logger.Infof("success create access token for user name=%s, tokenId=%s,traceId=%s", userName, ac.TokenId, traceId)
 	writer.Write(result.ToByte())
 }
 
+func newUserIdentity(id *auth.Identity, rule string) (*model.UserIdentity, error) {
+	ac := model.NewUserIdentity(id.UserName, rule)
+	ac.Groups = id.Groups
+	ac.Roles = id.Roles
+	acStr, err := ac.ToString()
+	if err != nil {
+		return nil, err
+	}
+	ac.AccessToken = acStr
+	return ac, nil
+}
+
+func (c *Control) Logout(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	request.ParseForm()
+	ud, err := api.ParseAccessToken(api.AccessToken(request), traceId)
+	if err != nil {
+		log.Logger.Warnf("ParseAccessToken failed,err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(errors.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+
+	if request.Form.Get("all") == "true" {
+		err = token.RevokeUser(c.redisClient, ud.UserName)
+	} else {
+		err = token.Revoke(c.redisClient, ud.TokenId, ud.ExpireAt())
+	}
+	if err != nil {
+		log.Logger.Warnf("revoke access token failed, userName=%s, tokenId=%s, err=%s,traceId=%s",
+			ud.UserName, ud.TokenId, err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(errors.Errorf("revoke access token failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+
+	http.SetCookie(writer, &http.Cookie{Name: api.AccessTokenName, Path: ctrlPath, MaxAge: -1})
+	log.Logger.Infof("logout success, userName=%s, tokenId=%s,traceId=%s", ud.UserName, ud.TokenId, traceId)
+	writer.Write(model.SuccessOpsResult("success").ToByte())
+}
+
 func (c *Control) AddRawCmd(writer http.ResponseWriter, request *http.Request) {
 	request.ParseForm()
 	cmdName := request.Form.Get("cmdName")
@@ -282,12 +374,17 @@ func (c *Control) AddRawCmd(writer http.ResponseWriter, request *http.Request) {
 		writer.Write([]byte("cmd list is empty"))
 		return
 	}
-	if err := c.allow.AddRaw(cmdList); err != nil {
+	before, _ := c.allow.LoadFromCache()
+	err := c.allow.AddRaw(cmdList)
+	after, _ := c.allow.LoadFromCache()
+	c.record(request, "addRawCmd", cmdName, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
 		log.Logger.Warnf("failed to add raw cmd=%s, err=%s", cmdName, err.Error())
 		writer.Write([]byte("failed to add raw cmd"))
 		return
 	}
 
+	c.cm.Notifier.Publish(notify.TopicCommands)
 	writer.Write([]byte("success"))
 	log.Logger.Infof("add raw cmd success %s\n", cmdName)
 }
@@ -304,12 +401,17 @@ func (c *Control) DeleteRawCmd(writer http.ResponseWriter, request *http.Request
 	log.Logger.Infof("delete raw command receive %s", cmdName)
 	cmdList := strings.Split(cmdName, ",")
 
-	if err := c.allow.RemoveRaw(cmdList); err != nil {
+	before, _ := c.allow.LoadFromCache()
+	err := c.allow.RemoveRaw(cmdList)
+	after, _ := c.allow.LoadFromCache()
+	c.record(request, "deleteRawCmd", cmdName, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
 		log.Logger.Warnf("failed remove raw command %s", cmdName)
 		writer.Write([]byte("failed remove raw command"))
 		return
 	}
 
+	c.cm.Notifier.Publish(notify.TopicCommands)
 	writer.Write([]byte("success"))
 	log.Logger.Infof("delete raw cmd %s success", cmdName)
 }
@@ -330,12 +432,17 @@ func (c *Control) AddCmd(writer http.ResponseWriter, request *http.Request) {
 		writer.Write([]byte("cmd list is empty"))
 		return
 	}
-	if err := c.allow.AddCommon(cmdList); err != nil {
+	_, before := c.allow.LoadFromCache()
+	err := c.allow.AddCommon(cmdList)
+	_, after := c.allow.LoadFromCache()
+	c.record(request, "addCmd", cmdName, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
 		log.Logger.Warnf("failed to add common cmd=%s, err=%s", cmdName, err.Error())
 		writer.Write([]byte("failed to add common cmd"))
 		return
 	}
 
+	c.cm.Notifier.Publish(notify.TopicCommands)
 	writer.Write([]byte("success"))
 	log.Logger.Infof("add common cmd success %s\n", cmdName)
 }
@@ -351,16 +458,73 @@ func (c *Control) DeleteCmd(writer http.ResponseWriter, request *http.Request) {
 	log.Logger.Infof("delete common command receive %s", cmdName)
 	cmdList := strings.Split(cmdName, ",")
 
-	if err := c.allow.RemoveCommon(cmdList); err != nil {
+	_, before := c.allow.LoadFromCache()
+	err := c.allow.RemoveCommon(cmdList)
+	_, after := c.allow.LoadFromCache()
+	c.record(request, "deleteCmd", cmdName, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
 		log.Logger.Warnf("failed remove common command %s", cmdName)
 		writer.Write([]byte("failed remove common command"))
 		return
 	}
 
+	c.cm.Notifier.Publish(notify.TopicCommands)
 	writer.Write([]byte("success"))
 	log.Logger.Infof("delete common cmd %s success", cmdName)
 }
 
+func (c *Control) AddBlacklistCmd(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	cmdName := request.Form.Get("cmdName")
+	log.Logger.Infof("add blacklist command receive %s", cmdName)
+	if cmdName == "" {
+		log.Logger.Infof("cmdName is empty")
+		writer.Write([]byte("cmdName is empty"))
+		return
+	}
+
+	cmdList := strings.Split(cmdName, ",")
+	before := c.allow.LoadBlacklist()
+	err := c.allow.AddBlacklist(cmdList)
+	after := c.allow.LoadBlacklist()
+	c.record(request, "addBlacklistCmd", cmdName, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
+		log.Logger.Warnf("failed to add blacklist cmd=%s, err=%s", cmdName, err.Error())
+		writer.Write([]byte("failed to add blacklist cmd"))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicCommands)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("add blacklist cmd success %s", cmdName)
+}
+
+func (c *Control) DeleteBlacklistCmd(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	cmdName := request.Form.Get("cmdName")
+	if cmdName == "" {
+		log.Logger.Infof("cmd name is empty")
+		writer.Write([]byte("cmd name is empty"))
+		return
+	}
+	log.Logger.Infof("delete blacklist command receive %s", cmdName)
+	cmdList := strings.Split(cmdName, ",")
+
+	before := c.allow.LoadBlacklist()
+	err := c.allow.RemoveBlacklist(cmdList)
+	after := c.allow.LoadBlacklist()
+	c.record(request, "deleteBlacklistCmd", cmdName, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
+		log.Logger.Warnf("failed remove blacklist command %s", cmdName)
+		writer.Write([]byte("failed remove blacklist command"))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicCommands)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("delete blacklist cmd %s success", cmdName)
+}
+
 func (c *Control) AddWhitelist(writer http.ResponseWriter, request *http.Request) {
 	request.ParseForm()
 	whiteIp := request.Form.Get("ip")
@@ -374,12 +538,17 @@ func (c *Control) AddWhitelist(writer http.ResponseWriter, request *http.Request
 	log.Logger.Infof("add whitelist receive, rule=%s, ip=%s", rule, whiteIp)
 
 	ips := strings.Split(strings.TrimSpace(whiteIp), ",")
-	if err := c.access.AddAccess(rule, ips); err != nil {
+	before := c.access.LoadFromCache()[rule]
+	err := c.access.AddAccess(rule, ips)
+	after := c.access.LoadFromCache()[rule]
+	c.record(request, "addWhitelist", rule, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
 		log.Logger.Warnf("failed to add whitelist, rule=%s, ip=%s, err=%s", rule, whiteIp, err.Error())
 		writer.Write([]byte("failed to add whitelist"))
 		return
 	}
 
+	c.cm.Notifier.Publish(notify.TopicWhitelist)
 	writer.Write([]byte("success"))
 	log.Logger.Infof("add whitelist success, rule=%s, ip=%s", rule, whiteIp)
 }
@@ -397,12 +566,17 @@ func (c *Control) DeleteIP(writer http.ResponseWriter, request *http.Request) {
 	log.Logger.Infof("delete whitelist receive, rule=%s, ip=%s", rule, whiteIp)
 
 	ips := strings.Split(strings.TrimSpace(whiteIp), ",")
-	if err := c.access.RemoveAccess(rule, ips); err != nil {
+	before := c.access.LoadFromCache()[rule]
+	err := c.access.RemoveAccess(rule, ips)
+	after := c.access.LoadFromCache()[rule]
+	c.record(request, "deleteIP", rule, strings.Join(before, ","), strings.Join(after, ","), err)
+	if err != nil {
 		log.Logger.Warnf("failed to remove whitelist, rule=%s, ip=%s, err=%s", rule, whiteIp, err.Error())
 		writer.Write([]byte("failed to remove whitelist"))
 		return
 	}
 
+	c.cm.Notifier.Publish(notify.TopicWhitelist)
 	writer.Write([]byte("success"))
 	log.Logger.Infof("delete whitelist success, rule=%s, ip=%s", rule, whiteIp)
 }
@@ -412,6 +586,7 @@ func (c *Control) GetCmdList(writer http.ResponseWriter, request *http.Request)
 	rawCmd, commonCmd := c.allow.LoadFromCache()
 	mm["raw"] = rawCmd
 	mm["common"] = commonCmd
+	mm["blacklist"] = c.allow.LoadBlacklist()
 	data, _ := jsoniter.Marshal(mm)
 	log.Logger.Infof("command:%s", commonCmd)
 	writer.Write(data)
@@ -489,25 +664,108 @@ func (c *Control) GetPodByName(writer http.ResponseWriter, request *http.Request
 func (c *Control) AddAccount(writer http.ResponseWriter, request *http.Request) {
 	request.ParseForm()
 	userName := request.Form.Get("userName")
-	if userName == "" {
-		log.Logger.Infof("user name  is empty")
-		writer.Write([]byte("user name is empty"))
+	password := request.Form.Get("password")
+	if userName == "" || password == "" {
+		log.Logger.Infof("user name or password is empty")
+		writer.Write([]byte("user name or password is empty"))
+		return
+	}
+
+	password, err := decodePassword(password)
+	if err != nil {
+		log.Logger.Warnf("decode password err=%s, userName=%s", err.Error(), userName)
+		writer.Write([]byte("aes decode failed"))
 		return
 	}
 
 	log.Logger.Infof("add account, userName=%s", userName)
 
-	if err := c.account.AddAccount(userName, nameField); err != nil {
-		log.Logger.Warnf("failed to add acount, as %s userName=%s, nameField=%s",
-			err.Error(), userName, nameField)
-		writer.Write([]byte("add account failed"))
+	err = c.account.Add(userName, password)
+	c.record(request, "addAccount", userName, "", userName, err)
+	if err != nil {
+		log.Logger.Warnf("failed to add account, as %s userName=%s", err.Error(), userName)
+		writer.Write([]byte(fmt.Sprintf("add account failed, %s", err.Error())))
+		return
+	}
+
+	log.Logger.Infof("success add account, userName=%s", userName)
+	writer.Write([]byte("success"))
+}
+
+func (c *Control) UpdateAccount(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	userName := request.Form.Get("userName")
+	password := request.Form.Get("password")
+	if userName == "" || password == "" {
+		log.Logger.Infof("user name or password is empty")
+		writer.Write([]byte("user name or password is empty"))
+		return
+	}
+
+	password, err := decodePassword(password)
+	if err != nil {
+		log.Logger.Warnf("decode password err=%s, userName=%s", err.Error(), userName)
+		writer.Write([]byte("aes decode failed"))
+		return
+	}
+
+	log.Logger.Infof("update account, userName=%s", userName)
+
+	err = c.account.Update(userName, password)
+	c.record(request, "updateAccount", userName, "password", "password reset", err)
+	if err != nil {
+		log.Logger.Warnf("failed to update account, as %s userName=%s", err.Error(), userName)
+		writer.Write([]byte(fmt.Sprintf("update account failed, %s", err.Error())))
 		return
 	}
 
-	log.Logger.Infof("success add account, userName=%s, nameField=%s", userName, nameField)
+	log.Logger.Infof("success update account, userName=%s", userName)
 	writer.Write([]byte("success"))
 }
 
+func (c *Control) ChangePassword(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	request.ParseForm()
+	userName := request.Form.Get("userName")
+	oldPassword := request.Form.Get("oldPassword")
+	newPassword := request.Form.Get("newPassword")
+	log.Logger.Infof("recv change password request,userName=%s,traceId=%s", userName, traceId)
+	if userName == "" || oldPassword == "" || newPassword == "" {
+		log.Logger.Infof("user name or password is empty,traceId=%s", traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("user name or password is empty,traceId=%s", traceId)).ToByte())
+		return
+	}
+
+	oldPassword, err := decodePassword(oldPassword)
+	if err != nil {
+		log.Logger.Warnf("decode password err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("aes decode failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+	newPassword, err = decodePassword(newPassword)
+	if err != nil {
+		log.Logger.Warnf("decode password err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("aes decode failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+
+	err = c.account.ChangePassword(userName, oldPassword, newPassword)
+	e := &audit.AdminEvent{TraceId: traceId, Actor: userName, SourceIP: ip.GetIp(request),
+		Action: "changePassword", Target: userName, Result: "success"}
+	if err != nil {
+		e.Result = err.Error()
+	}
+	c.adminTrail.Record(e)
+	if err != nil {
+		log.Logger.Warnf("change password failed,userName=%s,err=%s,traceId=%s", userName, err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+
+	log.Logger.Infof("change password success,userName=%s,traceId=%s", userName, traceId)
+	writer.Write(model.SuccessOpsResult("success").ToByte())
+}
+
 func (c *Control) DeleteAccount(writer http.ResponseWriter, request *http.Request) {
 	request.ParseForm()
 	userName := request.Form.Get("userName")
@@ -518,7 +776,9 @@ func (c *Control) DeleteAccount(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
-	if err := c.account.DeleteAccount(userName); err != nil {
+	err := c.account.Delete(userName)
+	c.record(request, "deleteAccount", userName, userName, "", err)
+	if err != nil {
 		log.Logger.Warnf("failed to delete account, as %s, userName=%s", err.Error(), userName)
 		writer.Write([]byte("delete account failed"))
 		return
@@ -538,7 +798,10 @@ func (c *Control) UpdateVersion(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
-	if err := c.version.UpdateVersion(version); err != nil {
+	before := c.version.LoadVersionFromCache()
+	err := c.version.UpdateVersion(version)
+	c.record(request, "updateVersion", "version", before, version, err)
+	if err != nil {
 		log.Logger.Warnf("failed to update version=%s,err=%s", version, err.Error())
 		writer.Write([]byte("update version failed"))
 		return
@@ -547,3 +810,12 @@ func (c *Control) UpdateVersion(writer http.ResponseWriter, request *http.Reques
 	log.Logger.Infof("success update version=%s", version)
 	writer.Write([]byte("success"))
 }
+
+// decodePassword reverses the aes encoding clients apply to password form fields.
+func decodePassword(password string) (string, error) {
+	aes, err := aes.NewAes(common.ResKey)
+	if err != nil {
+		return "", err
+	}
+	return aes.AesDecrypt(password)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/webankfintech/dockin-opserver/internal/account"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/auth"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/common"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/token"
+	"github.com/webankfintech/dockin-opserver/internal/utils/aes"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestControl(t *testing.T) *Control {
+	rc, err := redis.NewRedisClient()
+	assert.NoError(t, err)
+	if err := rc.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+	kr, err := token.NewKeyring([]*token.Key{{Id: "k1", Secret: "secret-1"}})
+	assert.NoError(t, err)
+	token.SetKeyring(kr)
+	c := &Control{redisClient: rc, account: account.NewStore(rc), adminTrail: audit.NewAdminTrail(rc)}
+	c.auth = auth.NewManagerWithProviders(nil, auth.NewStaticProvider(c.account))
+	return c
+}
+
+func encodePassword(t *testing.T, password string) string {
+	a, err := aes.NewAes(common.ResKey)
+	assert.NoError(t, err)
+	password, err = a.AesEncrypt(password)
+	assert.NoError(t, err)
+	return password
+}
+
+func postForm(handler http.HandlerFunc, form url.Values) []byte {
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder.Body.Bytes()
+}
+
+func login(t *testing.T, c *Control, userName, password string) int {
+	res := &model.OpsResult{}
+	body := postForm(c.Login, url.Values{"userName": {userName}, "password": {encodePassword(t, password)}})
+	assert.NoError(t, jsoniter.Unmarshal(body, res))
+	return res.Code
+}
+
+func TestAddAccountLogin(t *testing.T) {
+	c := newTestControl(t)
+	userName := "dockin_test_ctrl_user"
+	c.account.Delete(userName)
+	defer c.account.Delete(userName)
+
+	body := postForm(c.AddAccount, url.Values{"userName": {userName}, "password": {encodePassword(t, "first-pass")}})
+	assert.Equal(t, "success", string(body))
+	assert.Equal(t, model.Success, login(t, c, userName, "first-pass"))
+
+	body = postForm(c.UpdateAccount, url.Values{"userName": {userName}, "password": {encodePassword(t, "second-pass")}})
+	assert.Equal(t, "success", string(body))
+	assert.Equal(t, model.Failed, login(t, c, userName, "first-pass"))
+	assert.Equal(t, model.Success, login(t, c, userName, "second-pass"))
+
+	body = postForm(c.AddAccount, url.Values{"userName": {"dockin_test_plain"}, "password": {"plain-pass"}})
+	assert.Equal(t, "aes decode failed", string(body))
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/auth"
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const oidcStateExpire = 10 * time.Minute
+
+func (c *Control) OIDCLogin(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	request.ParseForm()
+	rule := request.Form.Get("rule")
+	if rule == "" {
+		rule = "default"
+	}
+
+	p, err := c.auth.OIDC()
+	if err != nil {
+		log.Logger.Warnf("oidc login failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+
+	state := uuid.New().String()
+	if err := c.redisClient.Set(keys.OIDCStateKey(state), rule, oidcStateExpire); err != nil {
+		log.Logger.Warnf("save oidc state failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("oidc login failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+	url, err := p.AuthCodeURL(request.Context(), state)
+	if err != nil {
+		log.Logger.Warnf("oidc login failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("oidc login failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+	log.Logger.Infof("redirect to oidc issuer, state=%s,traceId=%s", state, traceId)
+	http.Redirect(writer, request, url, http.StatusFound)
+}
+
+func (c *Control) OIDCCallback(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	request.ParseForm()
+	state := request.Form.Get("state")
+	code := request.Form.Get("code")
+	if state == "" || code == "" {
+		log.Logger.Infof("oidc callback without state or code,traceId=%s", traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("state or code is empty,traceId=%s", traceId)).ToByte())
+		return
+	}
+
+	rule, err := c.redisClient.Get(keys.OIDCStateKey(state))
+	if err != nil {
+		log.Logger.Warnf("unknown oidc state=%s, err=%s,traceId=%s", state, err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("invalid or expired state,traceId=%s", traceId)).ToByte())
+		return
+	}
+	c.redisClient.Del(keys.OIDCStateKey(state))
+
+	p, err := c.auth.OIDC()
+	if err != nil {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+	id, err := p.Exchange(request.Context(), code)
+	if err != nil {
+		log.Logger.Warnf("oidc exchange failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("oidc login failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+	c.writeOIDCIdentity(writer, id, rule.(string), traceId)
+}
+
+func (c *Control) OIDCDevice(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	p, err := c.auth.OIDC()
+	if err != nil {
+		log.Logger.Warnf("oidc device login failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+
+	da, err := p.StartDevice(request.Context())
+	if err != nil {
+		log.Logger.Warnf("start oidc device login failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("oidc device login failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+	log.Logger.Infof("start oidc device login, userCode=%s,traceId=%s", da.UserCode, traceId)
+	writer.Write(model.SuccessOpsResult(da).ToByte())
+}
+
+func (c *Control) OIDCDeviceToken(writer http.ResponseWriter, request *http.Request) {
+	traceId := trace.TraceID()
+	request.ParseForm()
+	deviceCode := request.Form.Get("deviceCode")
+	rule := request.Form.Get("rule")
+	if rule == "" {
+		rule = "default"
+	}
+	if deviceCode == "" {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("device code is empty,traceId=%s", traceId)).ToByte())
+		return
+	}
+
+	p, err := c.auth.OIDC()
+	if err != nil {
+		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+	id, err := p.PollDevice(request.Context(), deviceCode)
+	if err == auth.ErrAuthorizationPending || err == auth.ErrSlowDown {
+		writer.Write((&model.OpsResult{Code: model.Pending, Message: err.Error()}).ToByte())
+		return
+	}
+	if err != nil {
+		log.Logger.Warnf("oidc device login failed, err=%s,traceId=%s", err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId)).ToByte())
+		return
+	}
+	c.writeOIDCIdentity(writer, id, rule, traceId)
+}
+
+func (c *Control) writeOIDCIdentity(writer http.ResponseWriter, id *auth.Identity, rule, traceId string) {
+	c.auth.MapRoles(id)
+	ac, err := newUserIdentity(id, rule)
+	if err != nil {
+		log.Logger.Warnf("failed to create access token string, userName=%s, err=%s,traceId=%s", id.UserName, err.Error(), traceId)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("create access token failed,traceId=%s", traceId)).ToByte())
+		return
+	}
+	udstr, _ := jsoniter.MarshalToString(ac)
+	writer.Write(model.SuccessOpsResult(udstr).ToByte())
+	log.Logger.Infof("oidc login userName=%s success, groups=%v, roles=%v,traceId=%s", id.UserName, id.Groups, id.Roles, traceId)
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func (c *Control) GetPolicy(writer http.ResponseWriter, request *http.Request) {
+	roles, err := c.policy.ListRoles()
+	if err != nil {
+		log.Logger.Warnf("failed to list roles, err=%s", err.Error())
+		writer.Write([]byte("failed to list roles"))
+		return
+	}
+	bindings, err := c.policy.ListBindings()
+	if err != nil {
+		log.Logger.Warnf("failed to list bindings, err=%s", err.Error())
+		writer.Write([]byte("failed to list bindings"))
+		return
+	}
+
+	data, _ := jsoniter.Marshal(map[string]interface{}{
+		"roles":    roles,
+		"bindings": bindings,
+	})
+	writer.Write(data)
+}
+
+func (c *Control) SaveRole(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	data := request.Form.Get("role")
+	role := &policy.Role{}
+	if err := jsoniter.UnmarshalFromString(data, role); err != nil {
+		log.Logger.Infof("invalid role %s, err=%s", data, err.Error())
+		writer.Write([]byte("invalid role"))
+		return
+	}
+
+	log.Logger.Infof("save role receive %s", data)
+	before := c.policy.RoleSnapshot(role.Name)
+	err := c.policy.SaveRole(role)
+	c.record(request, "saveRole", role.Name, before, c.policy.RoleSnapshot(role.Name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to save role=%s, err=%s", role.Name, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to save role, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicPolicy)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("save role success %s", role.Name)
+}
+
+func (c *Control) DeleteRole(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	name := request.Form.Get("name")
+	if name == "" {
+		log.Logger.Infof("role name is empty")
+		writer.Write([]byte("role name is empty"))
+		return
+	}
+
+	before := c.policy.RoleSnapshot(name)
+	err := c.policy.DeleteRole(name)
+	c.record(request, "deleteRole", name, before, c.policy.RoleSnapshot(name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to delete role=%s, err=%s", name, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to delete role, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicPolicy)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("delete role success %s", name)
+}
+
+func (c *Control) SaveBinding(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	data := request.Form.Get("binding")
+	binding := &policy.Binding{}
+	if err := jsoniter.UnmarshalFromString(data, binding); err != nil {
+		log.Logger.Infof("invalid binding %s, err=%s", data, err.Error())
+		writer.Write([]byte("invalid binding"))
+		return
+	}
+
+	log.Logger.Infof("save binding receive %s", data)
+	before := c.policy.BindingSnapshot(binding.Name)
+	err := c.policy.SaveBinding(binding)
+	c.record(request, "saveBinding", binding.Name, before, c.policy.BindingSnapshot(binding.Name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to save binding=%s, err=%s", binding.Name, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to save binding, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicPolicy)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("save binding success %s", binding.Name)
+}
+
+func (c *Control) DeleteBinding(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	name := request.Form.Get("name")
+	if name == "" {
+		log.Logger.Infof("binding name is empty")
+		writer.Write([]byte("binding name is empty"))
+		return
+	}
+
+	before := c.policy.BindingSnapshot(name)
+	err := c.policy.DeleteBinding(name)
+	c.record(request, "deleteBinding", name, before, c.policy.BindingSnapshot(name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to delete binding=%s, err=%s", name, err.Error())
+		writer.Write([]byte("failed to delete binding"))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicPolicy)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("delete binding success %s", name)
+}
+
+func (c *Control) GetCommandRules(writer http.ResponseWriter, request *http.Request) {
+	rules, err := c.policy.ListCommandRules()
+	if err != nil {
+		log.Logger.Warnf("failed to list command rules, err=%s", err.Error())
+		writer.Write([]byte("failed to list command rules"))
+		return
+	}
+	data, _ := jsoniter.Marshal(rules)
+	writer.Write(data)
+}
+
+func (c *Control) SaveCommandRule(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	data := request.Form.Get("rule")
+	rule := &policy.CommandRule{}
+	if err := jsoniter.UnmarshalFromString(data, rule); err != nil {
+		log.Logger.Infof("invalid command rule %s, err=%s", data, err.Error())
+		writer.Write([]byte("invalid command rule"))
+		return
+	}
+
+	log.Logger.Infof("save command rule receive %s", data)
+	before := c.policy.CommandRuleSnapshot(rule.Name)
+	err := c.policy.SaveCommandRule(rule)
+	c.record(request, "saveCommandRule", rule.Name, before, c.policy.CommandRuleSnapshot(rule.Name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to save command rule=%s, err=%s", rule.Name, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to save command rule, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicCommandRules)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("save command rule success %s", rule.Name)
+}
+
+func (c *Control) DeleteCommandRule(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	name := request.Form.Get("name")
+	if name == "" {
+		log.Logger.Infof("command rule name is empty")
+		writer.Write([]byte("command rule name is empty"))
+		return
+	}
+
+	before := c.policy.CommandRuleSnapshot(name)
+	err := c.policy.DeleteCommandRule(name)
+	c.record(request, "deleteCommandRule", name, before, c.policy.CommandRuleSnapshot(name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to delete command rule=%s, err=%s", name, err.Error())
+		writer.Write([]byte("failed to delete command rule"))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicCommandRules)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("delete command rule success %s", name)
+}
+
+func (c *Control) GetCommandSets(writer http.ResponseWriter, request *http.Request) {
+	sets, err := c.policy.ListCommandSets()
+	if err != nil {
+		log.Logger.Warnf("failed to list command sets, err=%s", err.Error())
+		writer.Write([]byte("failed to list command sets"))
+		return
+	}
+	data, _ := jsoniter.Marshal(sets)
+	writer.Write(data)
+}
+
+func (c *Control) SaveCommandSet(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	data := request.Form.Get("set")
+	cs := &policy.CommandSet{}
+	if err := jsoniter.UnmarshalFromString(data, cs); err != nil {
+		log.Logger.Infof("invalid command set %s, err=%s", data, err.Error())
+		writer.Write([]byte("invalid command set"))
+		return
+	}
+
+	log.Logger.Infof("save command set receive %s", data)
+	before := c.policy.CommandSetSnapshot(cs.Name)
+	err := c.policy.SaveCommandSet(cs)
+	c.record(request, "saveCommandSet", cs.Name, before, c.policy.CommandSetSnapshot(cs.Name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to save command set=%s, err=%s", cs.Name, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to save command set, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicCommands)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("save command set success %s", cs.Name)
+}
+
+func (c *Control) DeleteCommandSet(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	name := request.Form.Get("name")
+	if name == "" {
+		log.Logger.Infof("command set name is empty")
+		writer.Write([]byte("command set name is empty"))
+		return
+	}
+
+	before := c.policy.CommandSetSnapshot(name)
+	err := c.policy.DeleteCommandSet(name)
+	c.record(request, "deleteCommandSet", name, before, c.policy.CommandSetSnapshot(name), err)
+	if err != nil {
+		log.Logger.Warnf("failed to delete command set=%s, err=%s", name, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to delete command set, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicCommands)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("delete command set success %s", name)
+}
+
+func (c *Control) GetFilterModes(writer http.ResponseWriter, request *http.Request) {
+	modes, err := c.policy.ListFilterModes()
+	if err != nil {
+		log.Logger.Warnf("failed to list filter modes, err=%s", err.Error())
+		writer.Write([]byte("failed to list filter modes"))
+		return
+	}
+	data, _ := jsoniter.Marshal(map[string]interface{}{
+		"default": policy.DefaultFilterMode(),
+		"rules":   modes,
+	})
+	writer.Write(data)
+}
+
+func (c *Control) SetFilterMode(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	rule := request.Form.Get("rule")
+	mode := request.Form.Get("mode")
+	if rule == "" {
+		log.Logger.Infof("rule is empty")
+		writer.Write([]byte("rule is empty"))
+		return
+	}
+
+	log.Logger.Infof("set filter mode receive, rule=%s, mode=%s", rule, mode)
+	before, _ := c.policy.FilterMode(rule)
+	err := c.policy.SetFilterMode(rule, mode)
+	after, _ := c.policy.FilterMode(rule)
+	c.record(request, "setFilterMode", rule, before, after, err)
+	if err != nil {
+		log.Logger.Warnf("failed to set filter mode, rule=%s, mode=%s, err=%s", rule, mode, err.Error())
+		writer.Write([]byte(fmt.Sprintf("failed to set filter mode, %s", err.Error())))
+		return
+	}
+
+	c.cm.Notifier.Publish(notify.TopicFilterMode)
+	writer.Write([]byte("success"))
+	log.Logger.Infof("set filter mode success, rule=%s, mode=%s", rule, mode)
+}
+
+func (c *Control) GetFilterReport(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	report, err := c.policy.FilterReport(request.Form.Get("rule"))
+	if err != nil {
+		log.Logger.Warnf("failed to get filter report, err=%s", err.Error())
+		writer.Write([]byte("failed to get filter report"))
+		return
+	}
+	data, _ := jsoniter.Marshal(report)
+	writer.Write(data)
+}
+
+func (c *Control) ResetFilterReport(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	rule := request.Form.Get("rule")
+
+	err := c.policy.ResetFilterReport(rule)
+	c.record(request, "resetFilterReport", rule, "", "", err)
+	if err != nil {
+		log.Logger.Warnf("failed to reset filter report, rule=%s, err=%s", rule, err.Error())
+		writer.Write([]byte("failed to reset filter report"))
+		return
+	}
+
+	writer.Write([]byte("success"))
+	log.Logger.Infof("reset filter report success, rule=%s", rule)
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/record"
+)
+
+// GetRecordings lists the recorded sessions, latest first, optionally of
+// one user or pod.
+func (c *Control) GetRecordings(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	offset, _ := strconv.ParseInt(request.Form.Get("offset"), 10, 64)
+	limit, _ := strconv.ParseInt(request.Form.Get("limit"), 10, 64)
+	user, pod := request.Form.Get("user"), request.Form.Get("pod")
+
+	metas, err := c.recordings.List(&record.Query{User: user, Pod: pod, Offset: offset, Limit: limit})
+	if err != nil {
+		log.Logger.Warnf("failed to list recordings, err=%s", err.Error())
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to list recordings")).ToByte())
+		return
+	}
+	writer.Write(model.SuccessOpsResult(metas).ToByte())
+}
+
+// GetRecording returns the cast of the session, id is the traceId of the
+// request which opened it.
+func (c *Control) GetRecording(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	id := request.Form.Get("id")
+	if !record.ValidId(id) {
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("invalid recording id %s", id)).ToByte())
+		return
+	}
+
+	data, err := c.recordings.Read(id)
+	if err == record.ErrNotFound {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write(model.FailedOpsResult(err).ToByte())
+		return
+	}
+	if err != nil {
+		log.Logger.Warnf("failed to read recording %s, err=%s", id, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to read recording %s", id)).ToByte())
+		return
+	}
+	if ac, ok := request.Context().Value(adminContextKey{}).(*adminContext); ok {
+		log.Logger.Infof("replay recording %s, userName=%s,traceId=%s", id, ac.ud.UserName, ac.traceId)
+	}
+	writer.Header().Set("Content-Type", "application/x-asciicast")
+	writer.Write(data)
+}
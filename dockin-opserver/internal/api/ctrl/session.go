@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package ctrl
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/sessions"
+
+	"github.com/gorilla/websocket"
+)
+
+var watchUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// GetSessions lists the running sessions of every instance, optionally of
+// one user or pod.
+func (c *Control) GetSessions(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	user, pod := request.Form.Get("user"), request.Form.Get("pod")
+	result := make([]*sessions.Info, 0)
+	for _, info := range c.cm.Sessions.List() {
+		if (user == "" || info.User == user) && (pod == "" || info.Pod == pod) {
+			result = append(result, info)
+		}
+	}
+	writer.Write(model.SuccessOpsResult(result).ToByte())
+}
+
+// KillSession ends the session, the message is shown to its user.
+func (c *Control) KillSession(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	id, message := request.Form.Get("id"), request.Form.Get("message")
+	notice := "this session is terminated by an administrator"
+	if ac, ok := request.Context().Value(adminContextKey{}).(*adminContext); ok {
+		notice = fmt.Sprintf("this session is terminated by %s", ac.ud.UserName)
+	}
+	if message != "" {
+		notice = fmt.Sprintf("%s: %s", notice, message)
+	}
+
+	err := c.cm.Sessions.Kill(id, notice)
+	c.record(request, "killSession", id, "", message, err)
+	if err == sessions.ErrNotFound {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("session %s is not found", id)).ToByte())
+		return
+	}
+	if err != nil {
+		log.Logger.Warnf("failed to kill session %s, err=%s", id, err.Error())
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to kill session %s", id)).ToByte())
+		return
+	}
+	writer.Write(model.SuccessOpsResult(nil).ToByte())
+}
+
+// WatchSession streams the output of the session over a websocket, as the
+// user of the session sees it. Input of the watcher is ignored.
+func (c *Control) WatchSession(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	id := request.Form.Get("id")
+	output, cancel, err := c.cm.Sessions.Watch(id)
+	c.record(request, "watchSession", id, "", "", err)
+	if err == sessions.ErrNotFound {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write(model.FailedOpsResult(fmt.Errorf("session %s is not found", id)).ToByte())
+		return
+	}
+	if err != nil {
+		log.Logger.Warnf("failed to watch session %s, err=%s", id, err.Error())
+		writer.Write(model.FailedOpsResult(fmt.Errorf("failed to watch session %s", id)).ToByte())
+		return
+	}
+	defer cancel()
+
+	conn, err := watchUpgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		log.Logger.Warnf("failed to upgrade the connection to watch session %s, err=%v", id, err)
+		return
+	}
+	defer conn.Close()
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for data := range output {
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("\r\n\r\nthe session is finished\r\n"))
+}
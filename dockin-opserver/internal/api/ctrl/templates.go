@@ -26,11 +26,56 @@ var indexTemplate = `
 <h1 label="标题居中" style="font-size: 32px; font-weight: bold; padding: 0px 4px 0px 0px; text-align: left; margin: 0px 0px 20px;" dir="rtl">
     DOCKIN-OPSERVER控制管理台<br/>
 </h1>
+<p>当前管理员: {{.UserName}}&nbsp;&nbsp;<button type="button" onclick="logout();">退出</button></p>
 <hr width="60%" align="left"/>
 
 
 <script language="javascript">
 	
+	function aesEncrypt(plaintext) {
+		var sbox = [], xtime = function(a) { return ((a << 1) ^ (a & 0x80 ? 0x1b : 0)) & 0xff };
+		for (var i = 0, p = 1, q = 1; i < 256; i++) {
+			if (i > 0) {
+				p = p ^ xtime(p);
+				q ^= q << 1; q ^= q << 2; q ^= q << 4; q &= 0xff; if (q & 0x80) q ^= 0x09;
+			}
+			var s = q ^ ((q << 1) | (q >> 7)) ^ ((q << 2) | (q >> 6)) ^ ((q << 3) | (q >> 5)) ^ ((q << 4) | (q >> 4));
+			sbox[p] = (s ^ 0x63) & 0xff;
+		}
+		sbox[0] = 0x63;
+		var w = [], key = "{{.ResKey}}", rcon = 1;
+		for (var i = 0; i < 16; i++) w[i] = key.charCodeAt(i);
+		for (var i = 16; i < 176; i += 4) {
+			var t = w.slice(i - 4, i);
+			if (i % 16 == 0) {
+				t = [sbox[t[1]] ^ rcon, sbox[t[2]], sbox[t[3]], sbox[t[0]]];
+				rcon = xtime(rcon);
+			}
+			for (var j = 0; j < 4; j++) w[i + j] = w[i - 16 + j] ^ t[j];
+		}
+		var encryptBlock = function(b) {
+			var s = b.map(function(v, i) { return v ^ w[i] });
+			for (var r = 1; r <= 10; r++) {
+				var t = [];
+				for (var i = 0; i < 16; i++) t[i] = sbox[s[(i + 4 * (i % 4)) % 16]];
+				for (var c = 0; c < 4 && r < 10; c++) {
+					var a = t.slice(4 * c, 4 * c + 4), x = a[0] ^ a[1] ^ a[2] ^ a[3];
+					for (var i = 0; i < 4; i++) t[4 * c + i] = a[i] ^ x ^ xtime(a[i] ^ a[(i + 1) % 4]);
+				}
+				s = t.map(function(v, i) { return v ^ w[16 * r + i] });
+			}
+			return s;
+		};
+		var data = Array.from(new TextEncoder().encode(plaintext));
+		var iv = Array.from(crypto.getRandomValues(new Uint8Array(16)));
+		var out = iv.slice(), prev = iv;
+		for (var i = 0; i < data.length; i += 16) {
+			var k = encryptBlock(prev), chunk = data.slice(i, i + 16).map(function(v, j) { return v ^ k[j] });
+			out = out.concat(chunk);
+			prev = chunk.concat(k.slice(chunk.length));
+		}
+		return out.map(function(v) { return ("0" + v.toString(16)).slice(-2) }).join("");
+	}
 	function addRawCmd(){
 		var cmdName = document.getElementsByName("cmdRawNameAdd")[0].value
 		if (cmdName == "") {
@@ -86,6 +131,60 @@ var indexTemplate = `
 			}
 		}
 	}
+	function addBlacklistCmd(){
+		var cmdName = document.getElementsByName("cmdBlacklistNameAdd")[0].value
+		if (cmdName == "") {
+			alert("输入的命令为空")
+			return false
+		}
+		if (!confirm("确认增加黑名单命令:" + cmdName)) {
+			return false
+		}
+		var httpClient = new XMLHttpRequest();
+		httpClient.open("post", "/v1/dockin/opserver/ctrl/addBlacklistCmd?cmdName=" + cmdName);
+		httpClient.send();
+		httpClient.onreadystatechange = function() {
+			if (this.readyState == 4) {
+				if (this.status != 200) {
+					alert("http code = " + this.status);
+					return false;
+				} else {
+					alert(this.responseText);
+					location.reload();
+				}
+			}
+		}
+	}
+	function deleteBlacklistCmd(){
+		var blCheckBox = document.getElementsByName("cmdBlacklistNameDelete")
+		var cmdList = new Array()
+		for (let i = 0; i < blCheckBox.length; i++) {
+			if (blCheckBox[i].checked) {
+				cmdList.push(blCheckBox[i].value)
+			}
+		}
+		if (cmdList == 0) {
+			alert("没有勾选要被删除的命令")
+			return false;
+		}
+		if (!confirm("确认删除黑名单命令:" + cmdList.join(","))) {
+			return false
+		}
+		var httpClient = new XMLHttpRequest();
+		httpClient.open("post", "/v1/dockin/opserver/ctrl/deleteBlacklistCmd?cmdName=" + cmdList.join(","));
+		httpClient.send();
+		httpClient.onreadystatechange = function() {
+			if (this.readyState == 4) {
+				if (this.status != 200) {
+					alert("http code = " + this.status);
+					return false;
+				} else {
+					alert(this.responseText);
+					location.reload();
+				}
+			}
+		}
+	}
 	function addCmd(){ 
 		var cmdName = document.getElementsByName("cmdNameAdd")[0].value
 		if (cmdName == "") {
@@ -222,16 +321,40 @@ var indexTemplate = `
 	}
 	function addAccount() {
 		var userName = document.getElementsByName("userNameAdd")[0].value
-		if (userName=="") {
-			alert("用户名为空")
+		var password = document.getElementsByName("passwordAdd")[0].value
+		if (userName=="" || password=="") {
+			alert("用户名或密码为空")
 			return false
 		}
 		if (!confirm("确认新增账号:用户名=" + userName)) {
 			return false
 		}
 		var httpClient = new XMLHttpRequest();
-		httpClient.open("post", "/v1/dockin/opserver/ctrl/addAccount?userName=" + userName);
-		httpClient.send();
+		httpClient.open("post", "/v1/dockin/opserver/ctrl/addAccount");
+		httpClient.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+		httpClient.send("userName=" + encodeURIComponent(userName) + "&password=" + aesEncrypt(password));
+		httpClient.onreadystatechange = function() {
+			if (this.readyState == 4) {
+				if (this.status != 200) {
+					alert("http code = " + this.status);
+					return false;
+				} else {
+					alert(this.responseText);
+					location.reload();
+				}
+			}
+		}
+	}
+	function updateAccount(btn) {
+		var userName = btn.getAttribute("userName")
+		var password = prompt("输入账号" + userName + "的新密码")
+		if (password == null || password == "") {
+			return false
+		}
+		var httpClient = new XMLHttpRequest();
+		httpClient.open("post", "/v1/dockin/opserver/ctrl/updateAccount");
+		httpClient.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+		httpClient.send("userName=" + encodeURIComponent(userName) + "&password=" + aesEncrypt(password));
 		httpClient.onreadystatechange = function() {
 			if (this.readyState == 4) {
 				if (this.status != 200) {
@@ -288,10 +411,20 @@ function deleteAccount(btn) {
 			}
 		}
 	}
+	function logout() {
+		var httpClient = new XMLHttpRequest();
+		httpClient.open("post", "/v1/dockin/opserver/ctrl/logout");
+		httpClient.send();
+		httpClient.onreadystatechange = function() {
+			if (this.readyState == 4) {
+				location.reload();
+			}
+		}
+	}
 </script>
 
 <h2>
-    一、远程执行命令白名单
+    一、远程执行命令黑白名单
 </h2>
 <h3>
     1.&nbsp; 交互式命令<br/>
@@ -319,6 +452,20 @@ function deleteAccount(btn) {
 </br></br>
 <input type="text" name="cmdNameAdd" placeholder="输入命令，逗号分隔"/>
 <input type="submit" value="新增" onclick="addCmd();"/>
+</br>
+<hr width="60%" align="left"/>
+<h3>
+    3.&nbsp; 黑名单命令<br/>
+</h3>
+
+{{range .BlacklistCommand}}
+<input type="checkbox" name="cmdBlacklistNameDelete" value="{{.Cmd}}">{{.Cmd}}</input>
+{{end}}
+</br>
+<button type="button" onclick="deleteBlacklistCmd();">删除勾选</button>
+</br></br>
+<input type="text" name="cmdBlacklistNameAdd" placeholder="输入命令，逗号分隔"/>
+<input type="submit" value="新增" onclick="addBlacklistCmd();"/>
 
 <hr width="60%" align="left"/>
 <h2>
@@ -388,6 +535,7 @@ function deleteAccount(btn) {
 				<label>{{.UserName}}</label>
 			</td>
 			<td width="100" valign="middle">
+				<button type="button" name="updateAccountBtn" userName="{{.UserName}}" onclick="updateAccount(this);">重置密码</button>
 				<button type="button" name="deleteAccountBtn" userName="{{.UserName}}" onclick="deleteAccount(this);">删除勾选</button>
 			</td>
 		</tr>
@@ -395,6 +543,7 @@ function deleteAccount(btn) {
 		<tr>
 			<td width="100" valign="middle">
 				<input type="text" name="userNameAdd" placeholder="输入用户名"/>
+				<input type="password" name="passwordAdd" placeholder="输入密码"/>
 			</td>
 			<td width="100" valign="middle">
 				<button type="button" name="addAccountBtn" onclick="addAccount();">新增账户</button>
@@ -426,6 +575,37 @@ function deleteAccount(btn) {
 		</tr>
     </tbody>
 </table>
+<h2>
+    五、最近管理操作
+</h2>
+<table border="2px" cellspacing="0px" style="border-collapse:collapse">
+    <tbody border="1px" cellspacing="0px" style="border-collapse:collapse">
+        <tr class="firstRow">
+            <td width="160" valign="top">时间</td>
+            <td width="100" valign="top">操作人</td>
+            <td width="120" valign="top">来源IP</td>
+            <td width="120" valign="top">操作</td>
+            <td width="160" valign="top">对象</td>
+            <td width="200" valign="top">变更前</td>
+            <td width="200" valign="top">变更后</td>
+            <td width="100" valign="top">结果</td>
+            <td width="160" valign="top">traceId</td>
+        </tr>
+		{{range .Audit}}
+		<tr>
+			<td valign="middle">{{.Time.Format "2006-01-02 15:04:05"}}</td>
+			<td valign="middle">{{.Actor}}</td>
+			<td valign="middle">{{.SourceIP}}</td>
+			<td valign="middle">{{.Action}}</td>
+			<td valign="middle">{{.Target}}</td>
+			<td valign="middle">{{.Before}}</td>
+			<td valign="middle">{{.After}}</td>
+			<td valign="middle">{{.Result}}</td>
+			<td valign="middle">{{.TraceId}}</td>
+		</tr>
+		{{end}}
+    </tbody>
+</table>
 <br/>
 <br/>
 <br/>
@@ -433,3 +613,25 @@ function deleteAccount(btn) {
 </body>
 </html>
 `
+
+var loginTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dockin-OPSERVER控制管理台</title>
+</head>
+<body>
+<h1 style="font-size: 32px; font-weight: bold; text-align: left; margin: 0px 0px 20px;">
+    DOCKIN-OPSERVER控制管理台<br/>
+</h1>
+<hr width="60%" align="left"/>
+<form method="post" action="/v1/dockin/opserver/ctrl/manager/login">
+	<p><input type="text" name="userName" placeholder="输入用户名"/></p>
+	<p><input type="password" name="password" placeholder="输入密码"/></p>
+	<p><input type="submit" value="管理员登录"/></p>
+</form>
+{{if .}}<p style="color: red;">{{.}}</p>{{end}}
+</body>
+</html>
+`
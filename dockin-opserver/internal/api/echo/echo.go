@@ -30,6 +30,7 @@ import (
 	"github.com/webankfintech/dockin-opserver/internal/client"
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
 	"github.com/webankfintech/dockin-opserver/internal/utils/base"
 	"github.com/webankfintech/dockin-opserver/internal/utils/cmap"
 	"github.com/webankfintech/dockin-opserver/internal/utils/ip"
@@ -65,11 +66,19 @@ func (e *Echo) Handle(writer http.ResponseWriter, req *http.Request) {
 	}
 
 	log.Logger.Infof("recv echo request, option=%#v, traceId=%s", opsOpts, traceId)
+	ud, err := api.Identify(req, opsOpts, e.RedisClient, traceId)
+	if err != nil {
+		log.Logger.Infof("identify request failed, err=%v, traceId=%s", err, traceId)
+		opsResult = model.FailedOpsResult(fmt.Errorf("%s,traceId=%s", err.Error(), traceId))
+		writer.Write(opsResult.ToByte())
+		return
+	}
+
 	reqIp := ip.GetIp(req)
 	if opsOpts.Name != "" {
-		opsResult = e.getResource(reqIp, opsOpts, traceId)
+		opsResult = e.getResource(reqIp, ud, opsOpts, traceId)
 	} else {
-		opsResult = e.batchGetResource(reqIp, opsOpts, traceId)
+		opsResult = e.batchGetResource(reqIp, ud, opsOpts, traceId)
 	}
 
 	log.Logger.Infof("success handle echo request=%#v, traceId=%s", opsOpts, traceId)
@@ -92,12 +101,12 @@ func (e *Echo) getK8sPodByUuid(uuid, traceId string) (string, error) {
 	}
 }
 
-func (e *Echo) getResource(reqIp string, opsOpts *model.OpsOption, traceId string) *model.OpsResult {
+func (e *Echo) getResource(reqIp string, ud *model.UserIdentity, opsOpts *model.OpsOption, traceId string) *model.OpsResult {
 	log.Logger.Infof("start to getResource,traceId=%s", traceId)
 	switch opsOpts.Resource {
 	case "pods", "pod", "po":
 		if base.IsUUid(opsOpts.Name) {
-			log.Logger.Infof("get pod resource by uuid, traceId=%s", opsOpts.Name, traceId)
+			log.Logger.Infof("get pod resource by uuid=%s, traceId=%s", opsOpts.Name, traceId)
 			result, err := e.getK8sPodByUuid(opsOpts.Name, traceId)
 			if err != nil {
 				return model.FailedOpsResult(err)
@@ -113,13 +122,16 @@ func (e *Echo) getResource(reqIp string, opsOpts *model.OpsOption, traceId strin
 			opsOpts.Name, err.Error(), traceId))
 	}
 	clusterId := opsOpts.ClusterId
-	log.Logger.Infof("---- cluster id in opts:", clusterId)
+	log.Logger.Infof("cluster id in opts=%s,traceId=%s", clusterId, traceId)
 	pc, err := e.Cm.GetProxyClient(reqIp, opsOpts.Rule, clusterId)
 	if err != nil {
 		log.Logger.Warnf("no proxy config found for ns=%s,traceId=%s", opsOpts.Namespace, traceId)
 		return model.FailedOpsResult(err)
 	}
 	api.SetNamespace(opsOpts, pc.K8sConfig.Contexts[0].Context.Namespace)
+	if _, err := api.AuthorizeIdentity(ud, opsOpts, policy.ActionGet, e.RedisClient, traceId); err != nil {
+		return model.FailedOpsResult(errors.Errorf("%s,traceId=%s", err.Error(), traceId))
+	}
 	getops := &GetOps{}
 	getops.ProxyClient = pc
 	getops.RedisClient = e.RedisClient
@@ -136,7 +148,7 @@ func (e *Echo) getResource(reqIp string, opsOpts *model.OpsOption, traceId strin
 	return model.SuccessOpsResult(md)
 }
 
-func (e *Echo) batchGetResource(ip string, opsOpts *model.OpsOption, traceId string) *model.OpsResult {
+func (e *Echo) batchGetResource(ip string, ud *model.UserIdentity, opsOpts *model.OpsOption, traceId string) *model.OpsResult {
 	log.Logger.Infof("start to batchGetResource,traceId=%s", traceId)
 	var (
 		wg  = new(sync.WaitGroup)
@@ -147,9 +159,23 @@ func (e *Echo) batchGetResource(ip string, opsOpts *model.OpsOption, traceId str
 		return model.FailedOpsResult(errors.Errorf("get podInfo from rm failed ip=%s, rule=%s err=%v, traceId=%s",
 			ip, opsOpts.Rule, err, traceId))
 	}
-	mapdata := cmap.New()
-	wg.Add(len(list))
+	var allowed []*client.ProxyClient
 	for _, pc := range list {
+		checkOpts := *opsOpts
+		checkOpts.Namespace = pc.K8sConfig.Contexts[0].Context.Namespace
+		if _, err := api.AuthorizeIdentity(ud, &checkOpts, policy.ActionGet, e.RedisClient, traceId); err != nil {
+			continue
+		}
+		allowed = append(allowed, pc)
+	}
+	if len(allowed) == 0 {
+		return model.FailedOpsResult(errors.Errorf("permission denied for all clusters of rule=%s, traceId=%s",
+			opsOpts.Rule, traceId))
+	}
+
+	mapdata := cmap.New()
+	wg.Add(len(allowed))
+	for _, pc := range allowed {
 		go func(proxyClient *client.ProxyClient) {
 			defer wg.Done()
 			defer func() {
@@ -161,9 +187,10 @@ func (e *Echo) batchGetResource(ip string, opsOpts *model.OpsOption, traceId str
 			getops := &GetOps{}
 			getops.ProxyClient = proxyClient
 			getops.RedisClient = e.RedisClient
-			opsOpts.ClusterId = pc.K8sConfig.Dockin.ClusterID
-			opsOpts.Namespace = pc.K8sConfig.Contexts[0].Context.Namespace
-			res, err := getops.GetResource(opsOpts, traceId)
+			opts := *opsOpts
+			opts.ClusterId = proxyClient.K8sConfig.Dockin.ClusterID
+			opts.Namespace = proxyClient.K8sConfig.Contexts[0].Context.Namespace
+			res, err := getops.GetResource(&opts, traceId)
 			if err != nil {
 				return
 			}
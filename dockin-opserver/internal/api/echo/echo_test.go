@@ -38,24 +38,31 @@ func init() {
 	}
 }
 
+func skipWithoutRedis(t *testing.T) {
+	if err := RClient.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+}
+
 func TestGetPods(t *testing.T) {
+	skipWithoutRedis(t)
 	m := client.NewManager(RClient)
 	m.Initialize()
 	echo := &Echo{Cm: m}
 
 	t.Run("func get pod without pod", func(t *testing.T) {
-		res := echo.batchGetResource("127.0.0.1", &model.OpsOption{
+		res := echo.batchGetResource("127.0.0.1", nil, &model.OpsOption{
 			Resource:  "pods",
 			Rule:      "tctp",
 			PrintType: "wide",
-		})
+		}, trace.TraceID())
 
 		assert.Equal(t, 0, res.Code)
 		t.Log(res.ToString())
 	})
 
 	t.Run("func get pod with pod", func(t *testing.T) {
-		res := echo.getResource("127.0.0.1", &model.OpsOption{
+		res := echo.getResource("127.0.0.1", nil, &model.OpsOption{
 			Resource:  "pods",
 			Name:      "bcces-cls-20190828-160144420-0",
 			Rule:      "tctp",
@@ -67,7 +74,7 @@ func TestGetPods(t *testing.T) {
 	})
 
 	t.Run("func get pod with pod, with namespace", func(t *testing.T) {
-		res := echo.getResource("127.0.0.1", &model.OpsOption{
+		res := echo.getResource("127.0.0.1", nil, &model.OpsOption{
 			Resource:  "pods",
 			Name:      "bcces-cls-20190828-160144420-0",
 			Rule:      "tctp",
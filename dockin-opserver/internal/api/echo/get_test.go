@@ -19,6 +19,7 @@ import (
 
 	"github.com/webankfintech/dockin-opserver/internal/client"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
 	"github.com/webankfintech/dockin-opserver/internal/utils/url"
 
 	jsoniter "github.com/json-iterator/go"
@@ -26,6 +27,7 @@ import (
 )
 
 func TestGetOps_GetPod(t *testing.T) {
+	skipWithoutRedis(t)
 	m := client.NewManager(RClient)
 	m.Initialize()
 	assert.Equal(t, 2, len(m.ProxyIpRuleClusterMap), "has tctp and cnc")
@@ -41,7 +43,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Resource:  "pods",
 			PrintType: "yaml",
 			Namespace: "dockin",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -52,7 +54,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Name:      "dockin-test-20190321-152445825",
 			Namespace: "dockin",
 			Container: "dockin-test-20190321-152445825",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -63,7 +65,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Namespace: "dockin",
 			Container: "dockin-test-20190321-152445825",
 			PrintType: "wide",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -75,7 +77,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Namespace: "dockin",
 			Container: "dockin-test-20190321-152445825",
 			PrintType: "yaml",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -88,7 +90,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Params: map[string]interface{}{
 				"all": true,
 			},
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -101,7 +103,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Params: map[string]interface{}{
 				"all": true,
 			},
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -112,7 +114,7 @@ func TestGetOps_GetPod(t *testing.T) {
 			Name:      "not-exist",
 			Namespace: "dockin",
 			Container: "not-exist",
-		})
+		}, trace.TraceID())
 		assert.Error(t, err)
 		t.Log(string(resp.ToByte()))
 	})
@@ -125,13 +127,14 @@ func TestGetOps_GetPod(t *testing.T) {
 			Namespace: "",
 			Container: "",
 			PrintType: "wide",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp.Data.(string))
 	})
 }
 
 func TestGetOps_GetNode(t *testing.T) {
+	skipWithoutRedis(t)
 	m := client.NewManager(RClient)
 	m.Initialize()
 	assert.Equal(t, 2, len(m.ProxyIpRuleClusterMap), "has tctp and cnc")
@@ -145,7 +148,7 @@ func TestGetOps_GetNode(t *testing.T) {
 		resp, err := getops.GetResource(&model.OpsOption{
 			Resource: "nodes",
 			Name:     "192-168-1-74",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -156,7 +159,7 @@ func TestGetOps_GetNode(t *testing.T) {
 			Name:      "192-168-1-74",
 			Namespace: "dockin",
 			PrintType: "yaml",
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
@@ -168,7 +171,7 @@ func TestGetOps_GetNode(t *testing.T) {
 			Params: map[string]interface{}{
 				"all": true,
 			},
-		})
+		}, trace.TraceID())
 		assert.NoError(t, err)
 		t.Log(resp)
 	})
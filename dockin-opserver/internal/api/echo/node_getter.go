@@ -93,7 +93,7 @@ func (p *NodeGetter) GetNodeWide(traceId string) (string, error) {
 		return "", err
 	}
 	if p.RedisClient.Set(key, pws, expiration); err != nil {
-		log.Logger.Warnf("failed to set row data to redis, as err=%s, data=%s,traceId=%s",
+		log.Logger.Warnf("failed to set row data to redis, as err=%s, data=%v,traceId=%s",
 			err.Error(), pws, traceId)
 	}
 
@@ -120,7 +120,7 @@ func (p *PodGetter) GetPodWide(traceId string) (string, error) {
 	}
 
 	if p.RedisClient.Set(key, content, expiration); err != nil {
-		log.Logger.Warnf("failed to set row data to redis, as err=%s, data=%s,traceId=%s",
+		log.Logger.Warnf("failed to set row data to redis, as err=%s, data=%v,traceId=%s",
 			err.Error(), pws, traceId)
 	}
 	log.Logger.Infof("end to GetPodWide,traceId=%s", traceId)
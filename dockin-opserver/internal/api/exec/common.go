@@ -26,6 +26,8 @@ import (
 	"github.com/webankfintech/dockin-opserver/internal/client"
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
 	"github.com/webankfintech/dockin-opserver/internal/remote"
 	"github.com/webankfintech/dockin-opserver/internal/utils/ip"
 	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
@@ -50,55 +52,142 @@ func NewCommon(cm *client.Manager, r *redis.RedisClient) *Common {
 }
 
 func (c *Common) Handle(writer http.ResponseWriter, req *http.Request) {
-	var (
-		opsResult *model.OpsResult
-		opsOpts   *model.OpsOption
-		err       error
-		pod       *v1.Pod
-	)
+	var opsResult *model.OpsResult
 	traceId := trace.TraceID()
 	log.Logger.Infof("recv common v2 request,traceId=%s", traceId)
-	if opsOpts, err = api.ValidateReq(req); err != nil {
-		opsResult = model.FailedOpsResult(errors.Errorf("validate common req err=%s,traceId=%s", err.Error(), traceId))
+	exec, cp, redactor, err := c.prepare(req, traceId)
+	if err != nil {
+		opsResult = model.FailedOpsResult(err)
 		writer.Write(opsResult.ToByte())
 		return
 	}
+
+	ioStreams, _, _, _ := remote.NewIOStreams()
+
+	start := time.Now()
+	err = exec.RunNoTty(traceId, ioStreams)
+	result := &model.ExecResult{
+		Stdout:    redactor.String(ioStreams.Out.(*bytes.Buffer).String()),
+		Stderr:    redactor.String(ioStreams.ErrOut.(*bytes.Buffer).String()),
+		Duration:  int64(time.Since(start) / time.Millisecond),
+		Pod:       exec.OpsOpts.Name,
+		Container: exec.OpsOpts.Container,
+		TraceId:   traceId,
+	}
+	if err != nil {
+		code, exited := remote.ExitCode(err)
+		if !exited {
+			result.ExitCode = -1
+			opsResult = model.FailedOpsResult(errors.Errorf("failed to exec command, err=%s,traceId=%s", err.Error(), traceId))
+			opsResult.Data = result
+			writer.Write(opsResult.ToByte())
+			return
+		}
+		result.ExitCode = code
+	}
+	cp.ReportExit(result.ExitCode)
+	opsResult = model.SuccessOpsResult(result)
+	writer.Write(opsResult.ToByte())
+	log.Logger.Infof("end to common v2, exitCode=%d, duration=%dms, traceId=%s", result.ExitCode, result.Duration, traceId)
+}
+
+// CommandHandle runs the command like Handle, or streams its output as it
+// arrives when the request asks for stream.
+func (c *Common) CommandHandle(writer http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	if req.Form.Get("stream") != "true" && req.Form.Get("stream") != "1" {
+		c.Handle(writer, req)
+		return
+	}
+
+	traceId := trace.TraceID()
+	log.Logger.Infof("recv command stream request,traceId=%s", traceId)
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	fw := newFrameWriter(writer)
+	exec, cp, redactor, err := c.prepare(req, traceId)
+	if err != nil {
+		fw.write(&model.ExecFrame{Type: model.FrameError, Data: err.Error()})
+		return
+	}
+
+	stdout, stderr := fw.stream(model.FrameStdout, redactor), fw.stream(model.FrameStderr, redactor)
+	ioStreams := &remote.IOStreams{In: &bytes.Buffer{}, Out: stdout, ErrOut: stderr}
+	start := time.Now()
+	err = exec.RunNoTty(traceId, ioStreams)
+	stdout.Flush()
+	stderr.Flush()
+	result := &model.ExecResult{
+		Duration:  int64(time.Since(start) / time.Millisecond),
+		Pod:       exec.OpsOpts.Name,
+		Container: exec.OpsOpts.Container,
+		TraceId:   traceId,
+	}
+	if err != nil {
+		code, exited := remote.ExitCode(err)
+		if !exited {
+			result.ExitCode = -1
+			fw.write(&model.ExecFrame{Type: model.FrameError,
+				Data: fmt.Sprintf("failed to exec command, err=%s,traceId=%s", err.Error(), traceId), Result: result})
+			return
+		}
+		result.ExitCode = code
+	}
+	cp.ReportExit(result.ExitCode)
+	fw.write(&model.ExecFrame{Type: model.FrameExit, Result: result})
+	log.Logger.Infof("end to command stream, exitCode=%d, duration=%dms, traceId=%s", result.ExitCode, result.Duration, traceId)
+}
+
+// prepare validates and authorizes the request, checks its command and finds
+// the container to run it in.
+func (c *Common) prepare(req *http.Request, traceId string) (*ExecCommand, *api.CommandPolicy, *redact.Redactor, error) {
+	var (
+		opsOpts *model.OpsOption
+		err     error
+		pod     *v1.Pod
+	)
+	if opsOpts, err = api.ValidateReq(req); err != nil {
+		return nil, nil, nil, errors.Errorf("validate common req err=%s,traceId=%s", err.Error(), traceId)
+	}
 	log.Logger.Infof("data=%s, traceId=%s", opsOpts.String(), traceId)
 	reqIp := ip.GetIp(req)
+	redactor := redact.ForRule(opsOpts.Rule, opsOpts.Namespace)
 	log.CommandLogger.Info("exec",
 		zap.String("operator", opsOpts.Operator),
 		zap.String("ip", reqIp),
-		zap.String("command", strings.Join(opsOpts.Flags, " ")),
+		zap.String("command", redactor.String(strings.Join(opsOpts.Flags, " "))),
 		zap.String("timestamp", fmt.Sprintf("%d", time.Now().Unix())),
 		zap.String("podName", opsOpts.Name),
 		zap.String("podIp", opsOpts.PodIp))
 
 	if err := api.SetPodOption(opsOpts); err != nil {
-		opsResult = model.FailedOpsResult(errors.Errorf("get podInfo from rm failed podName=%s, err=%s,traceId=%s",
-			opsOpts.Name, err.Error(), traceId))
-		writer.Write(opsResult.ToByte())
-		return
+		return nil, nil, nil, errors.Errorf("get podInfo from rm failed podName=%s, err=%s,traceId=%s",
+			opsOpts.Name, err.Error(), traceId)
+	}
+
+	cp, err := api.AuthorizeCommands(req, opsOpts, policy.ActionExec, c.RedisClient, traceId)
+	if err != nil {
+		return nil, nil, nil, errors.Errorf("%s,traceId=%s", err.Error(), traceId)
+	}
+	if err := cp.Check(opsOpts.Flags); err != nil {
+		return nil, nil, nil, errors.Errorf("%s,traceId=%s", err.Error(), traceId)
 	}
 
 	pod, err = api.GetPodStructFromRedis(opsOpts.Name, c.RedisClient)
 	if err != nil {
 		log.Logger.Warnf("failed to get pod struct from redis,podName=%s,err=%s traceId=%s", opsOpts.Name, err, traceId)
-		writer.Write([]byte(err.Error()))
-		return
+		return nil, nil, nil, errors.Errorf("%s,traceId=%s", err.Error(), traceId)
 	}
 
 	hostIp, err := api.GetHostIpByPod(opsOpts, pod, c.Cm, reqIp, traceId)
 	if err != nil {
 		log.Logger.Warnf("failed to get the host ip for ops=%s, traceId=%s", opsOpts.String(), traceId)
-		writer.Write([]byte(err.Error()))
-		return
+		return nil, nil, nil, errors.Errorf("%s,traceId=%s", err.Error(), traceId)
 	}
 
 	cid, err := api.GetContainerIdByPod(opsOpts.Name, pod)
 	if err != nil {
-		nerr := errors.Errorf("get containerId from pod struct by pod=%s failed,err=%s", opsOpts.Name, err.Error())
-		writer.Write([]byte(nerr.Error()))
-		return
+		return nil, nil, nil, errors.Errorf("get containerId from pod struct by pod=%s failed,err=%s,traceId=%s",
+			opsOpts.Name, err.Error(), traceId)
 	}
 
 	opsOpts.Container = cid
@@ -107,24 +196,5 @@ func (c *Common) Handle(writer http.ResponseWriter, req *http.Request) {
 		Conn:    nil,
 		HostIp:  hostIp,
 	}
-	ioStreams := &remote.IOStreams{
-		In:     bytes.NewBuffer([]byte{}),
-		Out:    writer,
-		ErrOut: bytes.NewBuffer([]byte{}),
-	}
-
-	ioStreams, _, _, _ = remote.NewIOStreams()
-
-	if err := exec.RunNoTty(traceId, ioStreams); err != nil {
-		opsResult = model.FailedOpsResult(errors.Errorf(ioStreams.ErrOut.(*bytes.Buffer).String()))
-		writer.Write(opsResult.ToByte())
-		return
-	}
-	opsResult = model.SuccessOpsResult(ioStreams.Out.(*bytes.Buffer).String())
-	writer.Write(opsResult.ToByte())
-	log.Logger.Infof("end to common v2")
-}
-
-func (c *Common) CommandHandle(writer http.ResponseWriter, req *http.Request) {
-	c.Handle(writer, req)
+	return exec, cp, redactor, nil
 }
@@ -20,10 +20,12 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/webankfintech/dockin-opserver/internal/config"
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/record"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
 	"github.com/webankfintech/dockin-opserver/internal/remote"
+	"github.com/webankfintech/dockin-opserver/internal/sessions"
 
 	"github.com/gorilla/websocket"
 )
@@ -33,18 +35,14 @@ var (
 	ErrNoContainerExec  = fmt.Errorf("could not find container to exec")
 	DefaultTimeout      = time.Duration(15 * time.Minute)
 	DefaultNoTtyTimeout = time.Duration(5 * time.Second)
-	forbiddenExecCmd    []string
 )
 
-func init() {
-	forbiddenExecCmd = config.OpsConfig.Limits.ExecForbidden
-	log.Logger.Infof("load forbidden command %#v", forbiddenExecCmd)
-}
-
 type ExecCommand struct {
-	OpsOpts *model.OpsOption
-	Conn    *websocket.Conn
-	HostIp  string
+	OpsOpts  *model.OpsOption
+	Conn     *websocket.Conn
+	HostIp   string
+	Recorder *record.Recorder
+	Live     *sessions.Session
 }
 
 func (e *ExecCommand) RunWithTty(traceId string) error {
@@ -61,6 +59,8 @@ func (e *ExecCommand) RunWithTty(traceId string) error {
 		remote.HandleWSError(e.Conn, err)
 		return err
 	}
+	session.SetRecorder(e.Recorder)
+	session.Track(e.Live)
 	session.Start(cancelCtx, traceId)
 	if err := session.Executor.ExecInteractive(execParam, session.InterStream); err != nil {
 		log.Logger.Warnf("run interactive exec err:%v, traceId=%s", err, traceId)
@@ -87,7 +87,12 @@ func (e *ExecCommand) RunNoTty(traceId string, ioStream *remote.IOStreams) error
 	//session.Start(cancelCtx)
 	err = session.Executor.Exec(execParam, ioStream)
 	if err != nil {
-		log.Logger.Warnf("run common exec err:%v, stderr=%s, traceId=%s", err, ioStream.ErrOut.(*bytes.Buffer).String(), traceId)
+		// streamed stderr is already sent to the client
+		var stderr string
+		if buf, ok := ioStream.ErrOut.(*bytes.Buffer); ok {
+			stderr = redact.ForRule(e.OpsOpts.Rule, e.OpsOpts.Namespace).String(buf.String())
+		}
+		log.Logger.Warnf("run common exec err:%v, stderr=%s, traceId=%s", err, stderr, traceId)
 		return err
 	}
 
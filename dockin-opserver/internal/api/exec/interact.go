@@ -15,20 +15,19 @@
 package exec
 
 import (
-	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/webankfintech/dockin-opserver/internal/client"
-	"github.com/webankfintech/dockin-opserver/internal/utils/cmd"
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/gorilla/websocket"
 	"github.com/webankfintech/dockin-opserver/internal/api"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
 	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
 	"github.com/webankfintech/dockin-opserver/internal/remote"
 	"github.com/webankfintech/dockin-opserver/internal/utils/ip"
 	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
@@ -75,8 +74,13 @@ func (i *Interact) Handle(writer http.ResponseWriter, req *http.Request) {
 	}
 	log.Logger.Infof("success to Upgrade webSocket protocol traceId=%s", traceId)
 
-	if err := i.validateCmd(opsOpts); err != nil {
-		log.Logger.Warnf("validateCmd err:%v, traceId=%s", err, traceId)
+	cp, err := api.AuthorizeCommands(req, opsOpts, policy.ActionInteract, i.RedisClient, traceId)
+	if err != nil {
+		log.Logger.Warnf("interact request is not authorized, err:%v, traceId=%s", err, traceId)
+		remote.HandleWSError(conn, err)
+		return
+	}
+	if err := cp.Check(opsOpts.Flags); err != nil {
 		remote.HandleWSError(conn, err)
 		return
 	}
@@ -104,10 +108,23 @@ func (i *Interact) Handle(writer http.ResponseWriter, req *http.Request) {
 
 	opsOpts.Container = cid
 
+	live, err := api.RegisterSession(i.Cm.Sessions, opsOpts, policy.ActionInteract, reqIp, traceId)
+	if err != nil {
+		log.Logger.Warnf("interact session is rejected, err:%v, traceId=%s", err, traceId)
+		remote.HandleWSError(conn, err)
+		return
+	}
+	defer live.Close()
+	cp.AuditSession(audit.EventSessionStart)
+	defer cp.AuditSession(audit.EventSessionEnd)
+	rec := api.StartRecording(i.RedisClient, opsOpts, policy.ActionInteract, reqIp, traceId)
+	defer rec.Close()
 	exec := &ExecCommand{
-		OpsOpts: opsOpts,
-		Conn:    conn,
-		HostIp:  hostIp,
+		OpsOpts:  opsOpts,
+		Conn:     conn,
+		HostIp:   hostIp,
+		Recorder: rec,
+		Live:     live,
 	}
 	if err := exec.RunWithTty(traceId); err != nil {
 		log.Logger.Warnf("run interactive exec err:%v, traceId=%s", err, traceId)
@@ -117,33 +134,3 @@ func (i *Interact) Handle(writer http.ResponseWriter, req *http.Request) {
 
 	log.Logger.Infof("finish interactive v2 request, traceId=%s", traceId)
 }
-
-func (i *Interact) validateCmd(opt *model.OpsOption) error {
-	cmdInStr := strings.Join(opt.Flags, " ")
-	cmdList, err := cmd.GetCommandList(cmdInStr)
-	if err != nil {
-		ne := fmt.Errorf("failed to parse cmd %s, err=%v", cmdInStr, err)
-		log.Logger.Infof(ne.Error())
-		return ne
-	}
-	sz := len(cmdList)
-	for i := 0; i < sz; i++ {
-		in := cmdList[i]
-		for _, cmd := range forbiddenExecCmd {
-			if strings.EqualFold(in, cmd) {
-				if strings.EqualFold("/bin/bash", in) || strings.EqualFold("/bin/sh", in) {
-					if i < sz-1 {
-						nextC := cmdList[i+1]
-						if nextC == "-c" {
-							continue
-						}
-					}
-				}
-				log.Logger.Warnf("not allowed command, cmd=%s", in)
-				return errors.Errorf("command [%s] is not allowed to execute", in)
-			}
-		}
-	}
-	return nil
-}
-
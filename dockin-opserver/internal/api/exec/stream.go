@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package exec
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// maxPending is how much output without a newline is held back, so that a
+// secret on a line is redacted as a whole.
+const maxPending = 4096
+
+// frameWriter writes frames to the response as newline delimited JSON and
+// flushes each of them, stdout and stderr are written concurrently.
+type frameWriter struct {
+	lock    sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newFrameWriter(w http.ResponseWriter) *frameWriter {
+	fw := &frameWriter{w: w}
+	fw.flusher, _ = w.(http.Flusher)
+	return fw
+}
+
+func (fw *frameWriter) write(f *model.ExecFrame) error {
+	data, err := jsoniter.Marshal(f)
+	if err != nil {
+		return err
+	}
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	if _, err := fw.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return nil
+}
+
+func (fw *frameWriter) stream(typ string, r *redact.Redactor) *frameStream {
+	return &frameStream{typ: typ, fw: fw, redactor: r.NewStream()}
+}
+
+// frameStream writes the output of one stream as frames of whole lines, a
+// line longer than maxPending is written in parts.
+type frameStream struct {
+	typ      string
+	fw       *frameWriter
+	redactor *redact.Stream
+	pending  []byte
+}
+
+func (s *frameStream) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	end := bytes.LastIndexByte(s.pending, '\n') + 1
+	if end == 0 && len(s.pending) >= maxPending {
+		end = len(s.pending)
+	}
+	if end == 0 {
+		return len(p), nil
+	}
+	chunk := s.pending[:end]
+	s.pending = append([]byte{}, s.pending[end:]...)
+	if err := s.send(s.redactor.String(string(chunk))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the output held back, once the command exits.
+func (s *frameStream) Flush() error {
+	chunk := s.pending
+	s.pending = nil
+	return s.send(s.redactor.String(string(chunk)) + s.redactor.Flush())
+}
+
+func (s *frameStream) send(data string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return s.fw.write(&model.ExecFrame{Type: s.typ, Data: data})
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package exec
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	fw := newFrameWriter(rec)
+	stdout, stderr := fw.stream(model.FrameStdout, nil), fw.stream(model.FrameStderr, nil)
+
+	stdout.Write([]byte("line 1\nli"))
+	stdout.Write([]byte("ne 2"))
+	stderr.Write([]byte("warn\n"))
+	stdout.Write([]byte("\nline 3"))
+	stdout.Write([]byte(strings.Repeat("x", maxPending)))
+	stdout.Write([]byte("tail"))
+	stdout.Flush()
+	stderr.Flush()
+	fw.write(&model.ExecFrame{Type: model.FrameExit, Result: &model.ExecResult{ExitCode: 1, TraceId: "t1"}})
+	assert.True(t, rec.Flushed)
+
+	var frames []*model.ExecFrame
+	for _, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+		f := &model.ExecFrame{}
+		assert.NoError(t, jsoniter.UnmarshalFromString(line, f))
+		frames = append(frames, f)
+	}
+	if assert.Len(t, frames, 6) {
+		assert.Equal(t, &model.ExecFrame{Type: model.FrameStdout, Data: "line 1\n"}, frames[0])
+		assert.Equal(t, &model.ExecFrame{Type: model.FrameStderr, Data: "warn\n"}, frames[1])
+		assert.Equal(t, &model.ExecFrame{Type: model.FrameStdout, Data: "line 2\n"}, frames[2])
+		assert.Equal(t, "line 3"+strings.Repeat("x", maxPending), frames[3].Data)
+		assert.Equal(t, "tail", frames[4].Data)
+		assert.Equal(t, model.FrameExit, frames[5].Type)
+		assert.Equal(t, 1, frames[5].Result.ExitCode)
+	}
+}
+
+func TestFrameStreamRedact(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stdout := newFrameWriter(rec).stream(model.FrameStdout, redact.New([]string{redact.Password}))
+	stdout.Write([]byte("password=s3cr"))
+	stdout.Write([]byte("etValue\n"))
+	assert.NotContains(t, rec.Body.String(), "s3cretValue")
+}
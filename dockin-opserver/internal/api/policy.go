@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/remote"
+)
+
+func Authorize(req *http.Request, opts *model.OpsOption, action string, rc *redis.RedisClient, traceId string) (*policy.Decision, error) {
+	ud, err := Identify(req, opts, rc, traceId)
+	if err != nil {
+		return nil, err
+	}
+	return AuthorizeIdentity(ud, opts, action, rc, traceId)
+}
+
+// Identify validates the access token of the request. Without the policy the
+// token is optional, the identity is nil unless a valid token is given, and
+// only such an identity is granted approvals.
+func Identify(req *http.Request, opts *model.OpsOption, rc *redis.RedisClient, traceId string) (*model.UserIdentity, error) {
+	accessToken := opts.AccessToken
+	if accessToken == "" {
+		accessToken = req.Header.Get(model.WcsAesHeader)
+	}
+	if !config.OpsConfig.Policy.Enabled {
+		if accessToken == "" {
+			return nil, nil
+		}
+		ud, err := ValidateAccessToken(accessToken, traceId, rc)
+		if err != nil {
+			log.Logger.Warnf("ignore the invalid access token of %s, err=%s,traceId=%s", opts.UserName, err.Error(), traceId)
+			return nil, nil
+		}
+		return ud, nil
+	}
+	return ValidateAccessToken(accessToken, traceId, rc)
+}
+
+func AuthorizeIdentity(ud *model.UserIdentity, opts *model.OpsOption, action string, rc *redis.RedisClient, traceId string) (*policy.Decision, error) {
+	var err error
+	if !config.OpsConfig.Policy.Enabled {
+		return &policy.Decision{Allowed: true, AllCommands: true}, nil
+	}
+	if ud == nil {
+		return nil, errTokenEmpty
+	}
+
+	pr := &policy.Request{
+		User:      ud.UserName,
+		Groups:    ud.Groups,
+		Roles:     ud.Roles,
+		Action:    action,
+		Subsystem: opts.SubSystem,
+		Namespace: opts.Namespace,
+		Pod:       opts.Name,
+		RunAs:     opts.User,
+	}
+	if action == policy.ActionGet {
+		switch opts.Resource {
+		case "pods", "pod", "po":
+		default:
+			pr.Pod = ""
+		}
+	}
+	if action != policy.ActionGet && action != policy.ActionApprove && pr.RunAs == "" {
+		pr.RunAs = policy.DefaultRunAsUser
+	}
+	if action == policy.ActionInteract || action == policy.ActionExec {
+		if pr.Commands, err = remote.ParseCmdlineToCmdList(remote.JoinArgs(opts.Flags)); err != nil {
+			log.Logger.Warnf("failed to parse command %v, err=%s,traceId=%s", opts.Flags, err.Error(), traceId)
+			return nil, err
+		}
+	}
+
+	d := policy.NewEngine(policy.NewStore(rc)).Evaluate(pr)
+	if !d.Allowed {
+		log.Logger.Warnf("policy denied, user=%s, action=%s, pod=%s, reason=%s,traceId=%s",
+			pr.User, action, pr.Pod, d.Reason, traceId)
+		return d, d.Error()
+	}
+	log.Logger.Infof("policy allowed, user=%s, action=%s, pod=%s, role=%s,traceId=%s",
+		pr.User, action, pr.Pod, d.Role, traceId)
+	opts.UserName = ud.UserName
+	return d, nil
+}
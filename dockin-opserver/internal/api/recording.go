@@ -0,0 +1,42 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+	"github.com/webankfintech/dockin-opserver/internal/record"
+	"github.com/webankfintech/dockin-opserver/internal/remote"
+)
+
+// StartRecording starts to record the interactive session of the request,
+// the recording is keyed by the traceId.
+func StartRecording(rc *redis.RedisClient, opts *model.OpsOption, action, clientIp, traceId string) *record.Recorder {
+	meta := &record.Meta{
+		Id:        traceId,
+		User:      opts.UserName,
+		Pod:       opts.Name,
+		Namespace: opts.Namespace,
+		Container: opts.Container,
+		Rule:      opts.Rule,
+		Action:    action,
+		ClientIP:  clientIp,
+	}
+	if action == policy.ActionInteract {
+		meta.Command = remote.JoinArgs(opts.Flags)
+	}
+	return record.Start(record.NewStore(rc), meta)
+}
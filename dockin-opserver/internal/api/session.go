@@ -0,0 +1,37 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package api
+
+import (
+	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/sessions"
+)
+
+// RegisterSession adds the interactive session of the request to the
+// registry of running sessions, the session is keyed by the traceId. It fails
+// when the user or the pod has too many sessions.
+func RegisterSession(reg *sessions.Registry, opts *model.OpsOption, action, clientIp, traceId string) (*sessions.Session, error) {
+	return reg.Register(&sessions.Info{
+		Id:        traceId,
+		Action:    action,
+		User:      opts.UserName,
+		SourceIP:  clientIp,
+		Cluster:   opts.ClusterId,
+		Rule:      opts.Rule,
+		Namespace: opts.Namespace,
+		Pod:       opts.Name,
+		Container: opts.Container,
+	})
+}
@@ -19,15 +19,18 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/webankfintech/dockin-opserver/internal/api"
-	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/audit"
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
 	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/client"
 	"github.com/webankfintech/dockin-opserver/internal/config"
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
 	"github.com/webankfintech/dockin-opserver/internal/remote"
 	"github.com/webankfintech/dockin-opserver/internal/utils/ip"
 	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
@@ -71,6 +74,13 @@ func (s *Ssh) HandleV2(writer http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	cp, err := api.AuthorizeCommands(req, opsOpts, policy.ActionSSH, s.RedisClient, traceId)
+	if err != nil {
+		log.Logger.Warnf("ssh request is not authorized, as=%v, traceId=%s", err, traceId)
+		writer.Write([]byte("ssh request is not authorized, as:" + err.Error() + traceId))
+		return
+	}
+
 	conn, err := upgrader.Upgrade(writer, req, nil)
 	if err != nil {
 		log.Logger.Warnf("failed to update the connection, err:%v, traceId=%s", err, traceId)
@@ -113,10 +123,37 @@ func (s *Ssh) HandleV2(writer http.ResponseWriter, req *http.Request) {
 		remote.HandleWSError(conn, err)
 		return
 	}
-	s.welcome(execParam.UserName, execParam.PodName, execParam.Rule, conn)
-	session.AddFilter(s.createCommandFilter())
+	live, err := api.RegisterSession(s.Cm.Sessions, opsOpts, policy.ActionSSH, reqIp, traceId)
+	if err != nil {
+		log.Logger.Warnf("ssh session is rejected, err:%v, traceId=%s", err, traceId)
+		remote.HandleWSError(conn, err)
+		return
+	}
+	defer live.Close()
+	s.welcome(execParam.UserName, execParam.PodName, execParam.Rule, execParam.User, cp, conn)
+	cp.AuditSession(audit.EventSessionStart)
+	defer cp.AuditSession(audit.EventSessionEnd)
+	rec := api.StartRecording(s.RedisClient, opsOpts, policy.ActionSSH, reqIp, traceId)
+	defer rec.Close()
+	session.SetRecorder(rec)
+	session.SetAuditor(cp)
+	session.Track(live)
+	session.SetFilterMode(cp.FilterMode, cp.RecordViolation)
+	session.SetGrant(cp.Grant)
+	for _, f := range cp.Filters(session.WorkingDir) {
+		session.AddFilter(f)
+	}
 	defer session.Close()
 
+	cancelSubscribe := s.Cm.Notifier.Subscribe(func(topic string) {
+		if topic == notify.TopicWhitelist {
+			return
+		}
+		cp.Reload(topic)
+		session.ReloadFilters()
+	})
+	defer cancelSubscribe()
+
 	session.Start(cancelCtx, traceId)
 	if err := session.Executor.Shell(cancelCtx, execParam, session.InterStream); err != nil {
 		log.Logger.Warnf("run shell err:%v, traceId=%s", err, traceId)
@@ -128,7 +165,8 @@ func (s *Ssh) HandleV2(writer http.ResponseWriter, req *http.Request) {
 	log.Logger.Infof("exit the shell with remote, traceId=%s", traceId)
 }
 
-func (s *Ssh) welcome(userName, podName, rule string, conn *websocket.Conn) {
+func (s *Ssh) welcome(userName, podName, rule, runAs string, cp *api.CommandPolicy, conn *websocket.Conn) {
+	mode, cmds, decision := cp.FilterMode(), cp.Commands(), cp.Decision()
 	conn.WriteMessage(websocket.TextMessage, []byte("\r\n\r\n"))
 	if remote.Banner != "" {
 		conn.WriteMessage(websocket.TextMessage, []byte(remote.Banner))
@@ -140,22 +178,48 @@ func (s *Ssh) welcome(userName, podName, rule string, conn *websocket.Conn) {
 	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
 	conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current login podName: %s, rule: %s.", podName, rule)))
 	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
+	if elevation := cp.Elevation(); elevation != "" {
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current run as user: %s, %s.", runAs, elevation)))
+	} else {
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current run as user: %s, reconnect with -s root --reason <reason> to run as root.", runAs)))
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
 	conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("If there are any problems in use this tools, please feel free to contact dockin-helper for help.")))
 	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
 
-	var cmdList []string
+	if mode != policy.FilterModeEnforce {
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current command filter mode:%s, commands are not denied.", mode)))
+		conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
+	}
+
+	var limit []string
+	if !decision.AllCommands {
+		limit = decision.Commands
+	}
 	if config.OpsConfig.CmdFilterType == remote.BlacklistMode {
 		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current command filter type:%s", remote.BlacklistMode)))
 		conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current forbinden command sets: %s", strings.Join(cmds.DenySets, ", "))))
+		conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
 		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current forbinden command list as follow:")))
 		conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
+		writeCommandList(conn, cmds.Deny)
+		if limit == nil {
+			return
+		}
+		cmds = &policy.SessionCommands{Allow: limit, Deny: cmds.Deny}
 	} else {
 		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current command filter type:%s", remote.WhitelistMode)))
 		conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
-		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current support command list as follow:")))
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current command sets: %s", strings.Join(cmds.AllowSets, ", "))))
 		conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
-		cmdList = s.GetCommandList()
 	}
+	conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Current support command list as follow:")))
+	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
+	writeCommandList(conn, cmds.Allowed(limit))
+}
+
+func writeCommandList(conn *websocket.Conn, cmdList []string) {
 	tmpBuf := &bytes.Buffer{}
 	tw := tabwriter.NewWriter(tmpBuf, 8, 0, 1, ' ', tabwriter.StripEscape)
 	for idx, cmd := range cmdList {
@@ -170,31 +234,3 @@ func (s *Ssh) welcome(userName, podName, rule string, conn *websocket.Conn) {
 	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
 	conn.WriteMessage(websocket.TextMessage, []byte("\r\n"))
 }
-
-func (s *Ssh) createCommandFilter() remote.Filter {
-	cmdFilterType := config.OpsConfig.CmdFilterType
-	if cmdFilterType == remote.BlacklistMode {
-		return remote.NewBlacklistFilter(func() []string {
-			return nil
-		})
-	}
-	return remote.NewWhitelistFilter(func() []string {
-		return s.GetCommandList()
-	})
-}
-
-func (s *Ssh) GetCommandList() []string {
-	log.Logger.Infof("start to GetCommandList")
-	raw, err := s.RedisClient.SMembers(keys.GetRawCmdRedisKey())
-	if err != nil {
-		log.Logger.Warnf(err.Error())
-	}
-
-	common, err := s.RedisClient.SMembers(keys.GetCommonCmdRedisKey())
-	if err != nil {
-		log.Logger.Warnf(err.Error())
-	}
-	raw = append(raw, common...)
-	log.Logger.Infof("end to GetCommandList")
-	return raw
-}
@@ -18,8 +18,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/common"
 	"github.com/webankfintech/dockin-opserver/internal/config"
 	"github.com/webankfintech/dockin-opserver/internal/dockin"
@@ -27,6 +27,7 @@ import (
 
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/model"
+	"github.com/webankfintech/dockin-opserver/internal/token"
 	"github.com/webankfintech/dockin-opserver/internal/utils/base"
 
 	jsoniter "github.com/json-iterator/go"
@@ -34,52 +35,47 @@ import (
 )
 
 var (
-	errNewAes       = fmt.Errorf("internal exception decrypt, check key")
-	errDecrypt      = fmt.Errorf("invalid request, access token is illegal as decrypt failed")
-	errUnmarshal    = fmt.Errorf("invalid request, access token is illegal as unmarshal failed")
+	errTokenEmpty   = fmt.Errorf("token is empty, please check login status")
+	errTokenIllegal = fmt.Errorf("invalid request, access token is illegal")
 	errTokenExpired = fmt.Errorf("access token is expired, try to relogin")
+	errTokenCheck   = fmt.Errorf("failed to check access token, try again later")
 )
 
-func ValidateAccessToken(token, traceId string) error {
-	ac, err := ParseAccessToken(token, traceId)
+func ValidateAccessToken(accessToken, traceId string, rc *redis.RedisClient) (*model.UserIdentity, error) {
+	ac, err := ParseAccessToken(accessToken, traceId)
 	if err != nil {
-		log.Logger.Warnf(err.Error())
-		return err
+		return nil, err
 	}
 
-	if int64(time.Now().Sub(ac.CreateTime).Seconds()) > ac.Expire {
-		log.Logger.Warnf("access token is expired, create at %v, token=%s,traceId=%s", ac.CreateTime, token, traceId)
-		return errTokenExpired
+	if ac.Expired() {
+		log.Logger.Warnf("access token is expired, userName=%s, create at %v,traceId=%s", ac.UserName, ac.CreateTime, traceId)
+		return nil, errTokenExpired
 	}
-	return nil
-}
 
-func ParseAccessToken(token, traceId string) (*model.UserIdentity, error) {
-	log.Logger.Infof("start to ParseAccessToken,token=%s,traceId=%s", token, traceId)
-	if token == "" {
-		log.Logger.Infof("token is empty,traceId=%s", traceId)
-		return nil, fmt.Errorf("token is empty, please check login status")
-	}
-	aes, err := aes.NewAes(common.ResKey)
-	if err != nil {
-		log.Logger.Warnf("new aes %s, err %v, token=%s,traceId=%s", errNewAes.Error(), err, token, traceId)
-		return nil, errNewAes
+	if err := token.CheckRevoked(rc, ac.TokenId, ac.UserName, ac.CreateTime); err != nil {
+		log.Logger.Warnf("access token rejected, userName=%s, tokenId=%s, err=%s,traceId=%s",
+			ac.UserName, ac.TokenId, err.Error(), traceId)
+		if err == token.ErrRevoked {
+			return nil, err
+		}
+		return nil, errTokenCheck
 	}
+	return ac, nil
+}
 
-	accD, err := aes.AesDecrypt(token)
-	if err != nil {
-		log.Logger.Warnf("invalid request, access token is illegal as decrypt failed, token=%s, err=%v,traceId=%s", token, err, traceId)
-		return nil, errDecrypt
+func ParseAccessToken(accessToken, traceId string) (*model.UserIdentity, error) {
+	if accessToken == "" {
+		log.Logger.Infof("token is empty,traceId=%s", traceId)
+		return nil, errTokenEmpty
 	}
 
-	log.Logger.Infof("get access token result str %s,traceId=%s", accD, traceId)
 	ac := &model.UserIdentity{}
-	if err = jsoniter.UnmarshalFromString(accD, ac); err != nil {
-		log.Logger.Warnf("invalid request, access token is illegal as unmarshal failed, err=%v, token=%s,traceId=%s", err, token, traceId)
-		return nil, errUnmarshal
+	if err := token.Verify(accessToken, ac); err != nil {
+		log.Logger.Warnf("invalid request, verify access token failed, err=%s,traceId=%s", err.Error(), traceId)
+		return nil, errTokenIllegal
 	}
 
-	log.Logger.Infof("end to ParseAccessToken,token=%s,traceId=%s", token, traceId)
+	log.Logger.Infof("parse access token success, userName=%s, tokenId=%s,traceId=%s", ac.UserName, ac.TokenId, traceId)
 	return ac, nil
 }
 
@@ -156,6 +152,7 @@ func SetPodOption(o *model.OpsOption) error {
 		clusterId     string
 		containerName string
 		hostIP        string
+		subSystem     string
 	)
 	input := o.Name
 	if base.IsIp(input) {
@@ -170,6 +167,7 @@ func SetPodOption(o *model.OpsOption) error {
 		clusterId = rmData.Data.ClusterID
 		containerName = rmData.Data.PodName
 		hostIP = rmData.Data.HostIP
+		subSystem = rmData.Data.SubSystem
 	} else if base.IsPodSet(input) {
 		log.Logger.Infof("IsPodSet get podInfo input=%s", input)
 		rmData, err := dockin.GetPodInfoByPodSetId(input)
@@ -182,6 +180,7 @@ func SetPodOption(o *model.OpsOption) error {
 		clusterId = rmData.ClusterID
 		containerName = rmData.PodName
 		hostIP = rmData.HostIP
+		subSystem = rmData.SubSystem
 	} else {
 		log.Logger.Infof("else get podInfo input=%s", input)
 		rmPodName := input
@@ -195,6 +194,7 @@ func SetPodOption(o *model.OpsOption) error {
 		hostIP = rmData.Data.HostIP
 		podName = o.Name
 		podIp = rmData.Data.PodIP
+		subSystem = rmData.Data.SubSystem
 	}
 
 	o.ClusterId = clusterId
@@ -202,6 +202,7 @@ func SetPodOption(o *model.OpsOption) error {
 	o.Name = podName
 	o.HostIP = hostIP
 	o.PodIp = podIp
+	o.SubSystem = subSystem
 
 	log.Logger.Infof("validate pod option result, podName=%s, containerName=%s, clusterId=%s, hostIP = %s",
 		podName, containerName, clusterId, hostIP)
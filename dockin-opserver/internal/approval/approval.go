@@ -0,0 +1,271 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package approval
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+
+	defaultMaxDuration    = time.Hour
+	defaultPendingTimeout = 30 * time.Minute
+	// finished requests are kept for a day, so that they can still be listed
+	keepFinished = 24 * time.Hour
+)
+
+var (
+	ErrNotFound   = errors.New("approval request is not found")
+	ErrNotPending = errors.New("approval request is not pending")
+	ErrSelf       = errors.New("approval request can not be decided by its requester")
+)
+
+// Request asks a second user to allow commands matching Pattern, or the
+// elevation to RunAs, on a pod for Duration seconds, the grant starts once it
+// is approved.
+type Request struct {
+	Id        string    `json:"id"`
+	User      string    `json:"user"`
+	Rule      string    `json:"rule"`
+	Subsystem string    `json:"subsystem"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Pattern   string    `json:"pattern"`
+	RunAs     string    `json:"runAs,omitempty"`
+	Reason    string    `json:"reason"`
+	Duration  int64     `json:"duration"`
+	Status    string    `json:"status"`
+	Approver  string    `json:"approver,omitempty"`
+	Created   time.Time `json:"created"`
+	Decided   time.Time `json:"decided,omitempty"`
+	Expires   time.Time `json:"expires"`
+}
+
+func (r *Request) Validate() error {
+	if r.User == "" || r.Pod == "" {
+		return fmt.Errorf("user or pod is empty")
+	}
+	if strings.TrimSpace(r.Pattern) == "" && r.RunAs == "" {
+		return fmt.Errorf("command pattern and run as user are empty")
+	}
+	if r.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if max := maxDuration(); time.Duration(r.Duration)*time.Second > max {
+		return fmt.Errorf("duration must not exceed %s", max)
+	}
+	return nil
+}
+
+// State returns the status of the request at now, a pending request or an
+// approved grant is expired once Expires passed.
+func (r *Request) State(now time.Time) string {
+	if (r.Status == StatusPending || r.Status == StatusApproved) && !now.Before(r.Expires) {
+		return StatusExpired
+	}
+	return r.Status
+}
+
+// Grants tells whether the approved request allows the command line of the
+// user on the pod at now.
+func (r *Request) Grants(user, rule, pod, cmdLine string, now time.Time) bool {
+	return r.State(now) == StatusApproved && r.User == user && r.Rule == rule && r.Pod == pod &&
+		strings.TrimSpace(r.Pattern) != "" && Match(r.Pattern, cmdLine)
+}
+
+// Elevates tells whether the approved request allows the user to run as
+// runAs on the pod at now.
+func (r *Request) Elevates(user, rule, pod, runAs string, now time.Time) bool {
+	return r.State(now) == StatusApproved && r.User == user && r.Rule == rule && r.Pod == pod &&
+		r.RunAs != "" && r.RunAs == runAs
+}
+
+// Match matches the whole command line against the pattern, where * matches
+// any text, including spaces and slashes, but no shell control characters, so
+// that a pattern can not be extended with more commands.
+func Match(pattern, cmdLine string) bool {
+	parts := strings.Split(strings.TrimSpace(pattern), "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	expr := "^" + strings.Join(parts, "[^;&|<>$`()\\n\\r]*") + "$"
+	ok, _ := regexp.MatchString(expr, strings.TrimSpace(cmdLine))
+	return ok
+}
+
+func maxDuration() time.Duration {
+	if d := config.OpsConfig.Approval.MaxDuration; d > 0 {
+		return time.Duration(d) * time.Second
+	}
+	return defaultMaxDuration
+}
+
+func pendingTimeout() time.Duration {
+	if d := config.OpsConfig.Approval.PendingTimeout; d > 0 {
+		return time.Duration(d) * time.Second
+	}
+	return defaultPendingTimeout
+}
+
+// Store keeps the approval requests in a redis hash by id.
+type Store struct {
+	redisClient *redis.RedisClient
+}
+
+func NewStore(rc *redis.RedisClient) *Store {
+	return &Store{redisClient: rc}
+}
+
+// Create validates the request and saves it as pending.
+func (s *Store) Create(r *Request) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	r.Id = trace.TraceID()
+	r.Status = StatusPending
+	r.Created = time.Now()
+	r.Expires = r.Created.Add(pendingTimeout())
+	return s.save(r)
+}
+
+func (s *Store) Get(id string) (*Request, error) {
+	v, err := s.redisClient.HGet(keys.ApprovalKey(), id)
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &Request{}
+	if err := jsoniter.UnmarshalFromString(v.(string), r); err != nil {
+		return nil, errors.Errorf("invalid approval request %s, err=%s", id, err.Error())
+	}
+	return r, nil
+}
+
+// List returns the requests, latest first. Requests finished for more than
+// a day are removed.
+func (s *Store) List() ([]*Request, error) {
+	data, err := s.redisClient.HGetAll(keys.ApprovalKey())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := make([]*Request, 0, len(data))
+	for id, v := range data {
+		r := &Request{}
+		if err := jsoniter.UnmarshalFromString(v, r); err != nil {
+			log.Logger.Warnf("invalid approval request %s, err=%s", id, err.Error())
+			continue
+		}
+		if r.finished(now) {
+			if err := s.redisClient.HDel(keys.ApprovalKey(), id); err != nil {
+				log.Logger.Warnf("failed to remove approval request %s, err=%s", id, err.Error())
+			}
+			continue
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// Decide approves or rejects the pending request as approver, an approved
+// grant lasts for the duration of the request from now.
+func (s *Store) Decide(id, approver string, approve bool) (*Request, error) {
+	r, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if r.State(now) != StatusPending {
+		return r, ErrNotPending
+	}
+	if r.User == approver {
+		return r, ErrSelf
+	}
+	r.Approver, r.Decided = approver, now
+	r.Status = StatusRejected
+	if approve {
+		r.Status = StatusApproved
+		r.Expires = now.Add(time.Duration(r.Duration) * time.Second)
+	}
+	return r, s.save(r)
+}
+
+// Grant returns the approved request which allows the command line of the
+// user on the pod, or nil if there is none.
+func (s *Store) Grant(user, rule, pod, cmdLine string) (*Request, error) {
+	list, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, r := range list {
+		if r.Grants(user, rule, pod, cmdLine, now) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// Elevation returns the approved request which allows the user to run as
+// runAs on the pod, or nil if there is none.
+func (s *Store) Elevation(user, rule, pod, runAs string) (*Request, error) {
+	list, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, r := range list {
+		if r.Elevates(user, rule, pod, runAs, now) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Store) save(r *Request) error {
+	data, err := jsoniter.MarshalToString(r)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.HSet(keys.ApprovalKey(), r.Id, data)
+}
+
+func (r *Request) finished(now time.Time) bool {
+	end := r.Expires
+	if r.Status == StatusRejected {
+		end = r.Decided
+	}
+	return now.Sub(end) > keepFinished
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package approval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	assert.True(t, Match("rm -rf /data/logs/*", "rm -rf /data/logs/app/2021"))
+	assert.True(t, Match(" systemctl restart nginx ", "systemctl restart nginx"))
+	assert.True(t, Match("kill *", "kill -9 1"))
+	assert.False(t, Match("kill *", "pkill -9 java"))
+	assert.False(t, Match("rm -rf /data/logs/*", "rm -rf /data/logs/x; rm -rf /"))
+	assert.False(t, Match("cat *", "cat $(rm -rf /)"))
+	assert.False(t, Match("cat *", "cat x | sh"))
+	assert.False(t, Match("ls [a]", "ls a"))
+}
+
+func TestRequestState(t *testing.T) {
+	now := time.Now()
+	r := &Request{Id: "a1", User: "alice", Rule: "default", Pod: "web-0", Pattern: "reboot*",
+		Duration: 600, Status: StatusPending, Expires: now.Add(time.Minute)}
+	assert.Equal(t, StatusPending, r.State(now))
+	assert.False(t, r.Grants("alice", "default", "web-0", "reboot", now))
+	assert.Equal(t, StatusExpired, r.State(now.Add(time.Minute)))
+
+	r.Status, r.Approver, r.Expires = StatusApproved, "bob", now.Add(10*time.Minute)
+	assert.True(t, r.Grants("alice", "default", "web-0", "reboot -f", now))
+	assert.False(t, r.Grants("carol", "default", "web-0", "reboot", now))
+	assert.False(t, r.Grants("alice", "default", "web-1", "reboot", now))
+	assert.False(t, r.Grants("alice", "prod", "web-0", "reboot", now))
+	assert.False(t, r.Grants("alice", "default", "web-0", "shutdown", now))
+	assert.False(t, r.Grants("alice", "default", "web-0", "reboot", now.Add(10*time.Minute)))
+	assert.False(t, r.finished(now.Add(10*time.Minute)))
+	assert.True(t, r.finished(now.Add(10*time.Minute+keepFinished+time.Second)))
+
+	r.Status = StatusRejected
+	assert.False(t, r.Grants("alice", "default", "web-0", "reboot", now))
+}
+
+func TestRequestElevates(t *testing.T) {
+	now := time.Now()
+	r := &Request{Id: "a2", User: "alice", Rule: "default", Pod: "web-0", RunAs: "root",
+		Duration: 600, Status: StatusApproved, Approver: "bob", Expires: now.Add(10 * time.Minute)}
+	assert.True(t, r.Elevates("alice", "default", "web-0", "root", now))
+	assert.False(t, r.Elevates("alice", "default", "web-0", "nobody", now))
+	assert.False(t, r.Elevates("carol", "default", "web-0", "root", now))
+	assert.False(t, r.Elevates("alice", "default", "web-1", "root", now))
+	assert.False(t, r.Elevates("alice", "default", "web-0", "root", now.Add(10*time.Minute)))
+	assert.False(t, r.Grants("alice", "default", "web-0", "", now))
+
+	r.Pattern, r.RunAs = "reboot", ""
+	assert.False(t, r.Elevates("alice", "default", "web-0", "root", now))
+}
+
+func TestRequestValidate(t *testing.T) {
+	r := &Request{User: "alice", Pod: "web-0", Pattern: "reboot", Duration: 600}
+	assert.NoError(t, r.Validate())
+	r = &Request{User: "alice", Pod: "web-0", RunAs: "root", Duration: 600}
+	assert.NoError(t, r.Validate())
+	for _, invalid := range []*Request{
+		{Pod: "web-0", Pattern: "reboot", Duration: 600},
+		{User: "alice", Pod: "web-0", Pattern: " ", Duration: 600},
+		{User: "alice", Pod: "web-0", Pattern: "reboot"},
+		{User: "alice", Pod: "web-0", Pattern: "reboot", Duration: 100 * 3600},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const defaultAdminMaxEvents = 10000
+
+type AdminEvent struct {
+	Time     time.Time `json:"time"`
+	TraceId  string    `json:"traceId"`
+	Actor    string    `json:"actor"`
+	SourceIP string    `json:"sourceIp"`
+	Action   string    `json:"action"`
+	Target   string    `json:"target"`
+	Before   string    `json:"before"`
+	After    string    `json:"after"`
+	Result   string    `json:"result"`
+}
+
+type AdminTrail struct {
+	redisClient *redis.RedisClient
+	maxEvents   int64
+}
+
+func NewAdminTrail(r *redis.RedisClient) *AdminTrail {
+	max := config.OpsConfig.Audit.AdminMaxEvents
+	if max <= 0 {
+		max = defaultAdminMaxEvents
+	}
+	return &AdminTrail{redisClient: r, maxEvents: max}
+}
+
+// Record writes the event to app.log and the audit chain first, so it is
+// never lost when redis is unavailable.
+func (t *AdminTrail) Record(e *AdminEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := jsoniter.MarshalToString(e)
+	if err != nil {
+		return err
+	}
+	log.Logger.Infof("admin audit %s", data)
+	Forward(KindAdmin, e)
+
+	if err := t.redisClient.LPush(keys.AdminAuditKey(), data); err != nil {
+		log.Logger.Warnf("failed to save admin audit event, traceId=%s, err=%s", e.TraceId, err.Error())
+		return err
+	}
+	if err := t.redisClient.LTrim(keys.AdminAuditKey(), 0, t.maxEvents-1); err != nil {
+		log.Logger.Warnf("failed to trim admin audit events, err=%s", err.Error())
+	}
+	return nil
+}
+
+// List returns the latest events first.
+func (t *AdminTrail) List(offset, limit int64) ([]*AdminEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	data, err := t.redisClient.LRange(keys.AdminAuditKey(), offset, offset+limit-1)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*AdminEvent, 0, len(data))
+	for _, d := range data {
+		e := &AdminEvent{}
+		if err := jsoniter.UnmarshalFromString(d, e); err != nil {
+			log.Logger.Warnf("invalid admin audit event %s", d)
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
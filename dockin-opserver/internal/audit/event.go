@@ -0,0 +1,261 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/utils/trace"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	EventSessionStart = "session-start"
+	EventSessionEnd   = "session-end"
+	EventCommand      = "command"
+	// EventCommandExit records the exit code of an allowed command, Ref is
+	// the id of its command event.
+	EventCommandExit = "command-exit"
+	// EventElevate records a request to run as root.
+	EventElevate = "elevate"
+
+	VerdictAllowed = "allowed"
+	VerdictDenied  = "denied"
+	// VerdictAudited is set on commands that would be denied, but run in the
+	// audit filter mode.
+	VerdictAudited = "audited"
+	// VerdictGranted is set on denied commands an approved grant allows.
+	VerdictGranted = "granted"
+
+	defaultRetention  = 90 * 24 * 3600
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+	queryBatch        = 500
+)
+
+// indexFields are the fields events are indexed by, in the order a query
+// picks the index to scan.
+var indexFields = []string{"user", "pod", "subsystem", "verdict"}
+
+// Event is one audited session or command. Session events share the traceId
+// of the request which opened the session with the commands run in it.
+type Event struct {
+	Id        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	TraceId   string    `json:"traceId"`
+	Action    string    `json:"action"`
+	User      string    `json:"user"`
+	SourceIP  string    `json:"sourceIp"`
+	Cluster   string    `json:"cluster"`
+	Rule      string    `json:"rule"`
+	Subsystem string    `json:"subsystem"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	RunAs     string    `json:"runAs,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	Cwd       string    `json:"cwd,omitempty"`
+	Verdict   string    `json:"verdict"`
+	Reason    string    `json:"reason,omitempty"`
+	ExitCode  *int      `json:"exitCode,omitempty"`
+	Ref       string    `json:"ref,omitempty"`
+}
+
+func (e *Event) field(name string) string {
+	switch name {
+	case "user":
+		return e.User
+	case "pod":
+		return e.Pod
+	case "subsystem":
+		return e.Subsystem
+	case "verdict":
+		return e.Verdict
+	}
+	return ""
+}
+
+// Query selects events, empty fields and zero times match everything.
+type Query struct {
+	User      string
+	Pod       string
+	Subsystem string
+	Verdict   string
+	Type      string
+	Since     time.Time
+	Until     time.Time
+	Offset    int64
+	Limit     int64
+}
+
+func (q *Query) field(name string) string {
+	switch name {
+	case "user":
+		return q.User
+	case "pod":
+		return q.Pod
+	case "subsystem":
+		return q.Subsystem
+	case "verdict":
+		return q.Verdict
+	}
+	return ""
+}
+
+func (q *Query) Match(e *Event) bool {
+	for _, f := range indexFields {
+		if v := q.field(f); v != "" && v != e.field(f) {
+			return false
+		}
+	}
+	if q.Type != "" && q.Type != e.Type {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	return q.Until.IsZero() || !e.Time.After(q.Until)
+}
+
+// Store keeps each event in redis until the retention passes, and indexes
+// it by time in a sorted set of all events and one per indexed field.
+type Store struct {
+	redisClient *redis.RedisClient
+	retention   time.Duration
+}
+
+func NewStore(r *redis.RedisClient) *Store {
+	retention := config.OpsConfig.Audit.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	return &Store{redisClient: r, retention: time.Duration(retention) * time.Second}
+}
+
+func (s *Store) Record(e *Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Id == "" {
+		e.Id = trace.TraceID()
+	}
+	data, err := jsoniter.MarshalToString(e)
+	if err != nil {
+		return err
+	}
+	Forward(KindEvent, e)
+	if err := s.redisClient.Set(keys.AuditEventKey(e.Id), data, s.retention); err != nil {
+		log.Logger.Warnf("failed to save audit event %s, traceId=%s, err=%s", data, e.TraceId, err.Error())
+		return err
+	}
+
+	score := float64(e.Time.UnixNano() / int64(time.Millisecond))
+	expired := strconv.FormatInt(time.Now().Add(-s.retention).UnixNano()/int64(time.Millisecond), 10)
+	indexes := []string{keys.AuditIndexKey("", "")}
+	for _, f := range indexFields {
+		if v := e.field(f); v != "" {
+			indexes = append(indexes, keys.AuditIndexKey(f, v))
+		}
+	}
+	for _, index := range indexes {
+		if err := s.redisClient.ZAdd(index, score, e.Id); err != nil {
+			log.Logger.Warnf("failed to index audit event %s in %s, traceId=%s, err=%s", e.Id, index, e.TraceId, err.Error())
+			return err
+		}
+		s.redisClient.ZRemRangeByScore(index, "-inf", "("+expired)
+		s.redisClient.Expire(index, s.retention)
+	}
+	return nil
+}
+
+// Query returns the matching events, latest first. It scans the index of the
+// first field set in the query, and filters the rest of the fields.
+func (s *Store) Query(q *Query) ([]*Event, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	index := keys.AuditIndexKey("", "")
+	for _, f := range indexFields {
+		if v := q.field(f); v != "" {
+			index = keys.AuditIndexKey(f, v)
+			break
+		}
+	}
+	min, max := "-inf", "+inf"
+	if !q.Since.IsZero() {
+		min = strconv.FormatInt(q.Since.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if !q.Until.IsZero() {
+		max = strconv.FormatInt(q.Until.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	var (
+		events  = make([]*Event, 0)
+		skipped int64
+	)
+	for start := int64(0); ; start += queryBatch {
+		ids, err := s.redisClient.ZRevRangeByScore(index, max, min, start, queryBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return events, nil
+		}
+		eventKeys := make([]string, 0, len(ids))
+		for _, id := range ids {
+			eventKeys = append(eventKeys, keys.AuditEventKey(id))
+		}
+		data, err := s.redisClient.MGet(eventKeys...)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range data {
+			str, ok := d.(string)
+			if !ok {
+				continue
+			}
+			e := &Event{}
+			if err := jsoniter.UnmarshalFromString(str, e); err != nil {
+				log.Logger.Warnf("invalid audit event %s", str)
+				continue
+			}
+			if !q.Match(e) {
+				continue
+			}
+			if skipped < q.Offset {
+				skipped++
+				continue
+			}
+			events = append(events, e)
+			if int64(len(events)) == limit {
+				return events, nil
+			}
+		}
+		if len(ids) < queryBatch {
+			return events, nil
+		}
+	}
+}
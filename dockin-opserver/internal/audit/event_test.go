@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryMatch(t *testing.T) {
+	now := time.Now()
+	e := &Event{Time: now, Type: EventCommand, User: "alice", Pod: "web-0", Subsystem: "dockin", Verdict: VerdictDenied}
+
+	assert.True(t, (&Query{}).Match(e))
+	assert.True(t, (&Query{User: "alice", Verdict: VerdictDenied, Since: now.Add(-time.Minute), Until: now}).Match(e))
+	assert.False(t, (&Query{User: "bob"}).Match(e))
+	assert.False(t, (&Query{Pod: "web-0", Subsystem: "other"}).Match(e))
+	assert.False(t, (&Query{Type: EventSessionStart}).Match(e))
+	assert.False(t, (&Query{Since: now.Add(time.Second)}).Match(e))
+	assert.False(t, (&Query{Until: now.Add(-time.Second)}).Match(e))
+}
+
+func TestStore(t *testing.T) {
+	rc, err := redis.NewRedisClient()
+	if err != nil {
+		t.Skipf("redis is not configured, err=%s", err.Error())
+	}
+	if err := rc.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+	s := NewStore(rc)
+	user := "dockin_audit_test_" + time.Now().Format("150405.000000")
+	start := time.Now().Add(-time.Hour)
+	for i, verdict := range []string{VerdictAllowed, VerdictDenied, VerdictAllowed} {
+		assert.NoError(t, s.Record(&Event{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			Type:    EventCommand,
+			User:    user,
+			Pod:     "web-0",
+			Command: string(rune('a' + i)),
+			Verdict: verdict,
+		}))
+	}
+
+	events, err := s.Query(&Query{User: user})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, "c", events[0].Command)
+	}
+
+	events, err = s.Query(&Query{User: user, Verdict: VerdictDenied})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "b", events[0].Command)
+	}
+
+	events, err = s.Query(&Query{User: user, Until: start.Add(time.Minute), Offset: 1, Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "a", events[0].Command)
+	}
+}
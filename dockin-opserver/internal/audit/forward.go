@@ -0,0 +1,351 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	KindEvent      = "event"
+	KindAdmin      = "admin"
+	KindCheckpoint = "checkpoint"
+
+	defaultQueueDir           = "audit-queue"
+	defaultMaxQueueSize       = 100
+	defaultCheckpointInterval = 300
+	chainStateFile            = "chain.state"
+	sendBatch                 = 100
+	minRetry                  = time.Second
+	maxRetry                  = time.Minute
+)
+
+// Entry is one link of the audit chain. Hash is the sha256 of the entry
+// marshalled without the hash, so it covers the hash of the previous entry.
+type Entry struct {
+	Node string              `json:"node"`
+	Seq  uint64              `json:"seq"`
+	Time string              `json:"time"`
+	Kind string              `json:"kind"`
+	Data jsoniter.RawMessage `json:"data"`
+	Prev string              `json:"prev"`
+	Hash string              `json:"hash,omitempty"`
+}
+
+func (e *Entry) digest() string {
+	h := e.Hash
+	e.Hash = ""
+	data, _ := jsoniter.Marshal(e)
+	e.Hash = h
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Checkpoint signs the chain of the node up to the entry Seq, whose hash is
+// Hash. It is the data of the entry following that one.
+type Checkpoint struct {
+	Seq       uint64 `json:"seq"`
+	Hash      string `json:"hash"`
+	KeyId     string `json:"keyId"`
+	Signature string `json:"signature"`
+}
+
+func signCheckpoint(secret, node string, seq uint64, hash string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s|%d|%s", node, seq, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type chainState struct {
+	Seq        uint64 `json:"seq"`
+	Hash       string `json:"hash"`
+	Checkpoint uint64 `json:"checkpoint"`
+}
+
+type sinkWorker struct {
+	name   string
+	sink   Sink
+	queue  *diskQueue
+	notify chan struct{}
+}
+
+// Forwarder chains the audit events of this instance and delivers the chain
+// to every sink through the sink's queue on disk, so events are kept while a
+// sink is unavailable and across restarts.
+type Forwarder struct {
+	lock      sync.Mutex
+	node      string
+	dir       string
+	state     chainState
+	keyId     string
+	secret    string
+	workers   []*sinkWorker
+	stopper   chan struct{}
+	checkTick time.Duration
+}
+
+var (
+	forwarder     *Forwarder
+	forwarderOnce sync.Once
+)
+
+// StartForwarder starts to forward audit events when audit.forward is
+// enabled, undelivered events of the last run are sent first.
+func StartForwarder() {
+	forwarderOnce.Do(func() {
+		conf := config.OpsConfig.Audit.Forward
+		if !conf.Enabled {
+			return
+		}
+		sinks := make([]*SinkConfig, 0, len(conf.Sinks))
+		for _, s := range conf.Sinks {
+			c := SinkConfig(s)
+			sinks = append(sinks, &c)
+		}
+		node, _ := os.Hostname()
+		f, err := NewForwarder(node, conf.QueueDir, conf.MaxQueueSize*1024*1024, sinks)
+		if err != nil {
+			log.Logger.Errorf("failed to start to forward audit events, err=%s", err.Error())
+			return
+		}
+		interval := conf.CheckpointInterval
+		if interval <= 0 {
+			interval = defaultCheckpointInterval
+		}
+		f.SetCheckpointKey(conf.CheckpointKey.Id, conf.CheckpointKey.Secret, time.Duration(interval)*time.Second)
+		f.Start()
+		forwarder = f
+	})
+}
+
+// Forward adds the record to the audit chain, it is a no-op unless the
+// forwarder is started.
+func Forward(kind string, v interface{}) {
+	if forwarder == nil {
+		return
+	}
+	if err := forwarder.Forward(kind, v); err != nil {
+		log.Logger.Warnf("failed to forward audit %s, err=%s", kind, err.Error())
+	}
+}
+
+func NewForwarder(node, dir string, maxQueueSize int64, sinks []*SinkConfig) (*Forwarder, error) {
+	if dir == "" {
+		dir = defaultQueueDir
+	}
+	if maxQueueSize <= 0 {
+		maxQueueSize = defaultMaxQueueSize * 1024 * 1024
+	}
+	if node == "" {
+		node = "-"
+	}
+	f := &Forwarder{node: node, dir: dir, stopper: make(chan struct{})}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, chainStateFile)); err == nil {
+		if err := jsoniter.Unmarshal(data, &f.state); err != nil {
+			return nil, errors.Errorf("invalid audit chain state %s, err=%s", string(data), err.Error())
+		}
+	}
+
+	for _, c := range sinks {
+		if c.Name == "" {
+			c.Name = c.Type
+		}
+		sink, err := NewSink(c)
+		if err != nil {
+			return nil, err
+		}
+		q, err := openQueue(dir, c.Name, maxQueueSize)
+		if err != nil {
+			return nil, err
+		}
+		f.workers = append(f.workers, &sinkWorker{name: c.Name, sink: sink, queue: q, notify: make(chan struct{}, 1)})
+	}
+	return f, nil
+}
+
+// SetCheckpointKey signs a checkpoint of the chain every interval, no
+// checkpoint is written without a secret.
+func (f *Forwarder) SetCheckpointKey(id, secret string, interval time.Duration) {
+	f.keyId, f.secret, f.checkTick = id, secret, interval
+	if secret == "" {
+		log.Logger.Warnf("audit.forward.checkpoint-key is not set, audit checkpoints are not signed")
+	}
+}
+
+func (f *Forwarder) Start() {
+	for _, w := range f.workers {
+		go f.deliver(w)
+	}
+	if f.secret != "" && f.checkTick > 0 {
+		go f.checkpointLoop()
+	}
+}
+
+func (f *Forwarder) Stop() {
+	close(f.stopper)
+}
+
+func (f *Forwarder) Forward(kind string, v interface{}) error {
+	data, err := jsoniter.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.append(kind, data)
+}
+
+// Checkpoint signs the chain written so far, unless it is signed already.
+func (f *Forwarder) Checkpoint() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.secret == "" || f.state.Seq == f.state.Checkpoint {
+		return nil
+	}
+	data, err := jsoniter.Marshal(&Checkpoint{
+		Seq:       f.state.Seq,
+		Hash:      f.state.Hash,
+		KeyId:     f.keyId,
+		Signature: signCheckpoint(f.secret, f.node, f.state.Seq, f.state.Hash),
+	})
+	if err != nil {
+		return err
+	}
+	if err := f.append(KindCheckpoint, data); err != nil {
+		return err
+	}
+	f.state.Checkpoint = f.state.Seq
+	return f.saveState()
+}
+
+// append links the data to the chain and queues it for every sink, a sink
+// whose queue is full misses the entry, which shows as a gap in its chain.
+func (f *Forwarder) append(kind string, data []byte) error {
+	e := &Entry{
+		Node: f.node,
+		Seq:  f.state.Seq + 1,
+		Time: time.Now().Format(time.RFC3339Nano),
+		Kind: kind,
+		Data: data,
+		Prev: f.state.Hash,
+	}
+	e.Hash = e.digest()
+	line, err := jsoniter.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f.state.Seq, f.state.Hash = e.Seq, e.Hash
+	if err := f.saveState(); err != nil {
+		log.Logger.Warnf("failed to save audit chain state, err=%s", err.Error())
+	}
+
+	var failed []string
+	for _, w := range f.workers {
+		if err := w.queue.Append(line); err != nil {
+			log.Logger.Errorf("failed to queue audit entry %d for sink %s, err=%s", e.Seq, w.name, err.Error())
+			failed = append(failed, w.name)
+			continue
+		}
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("audit entry %d is not queued for sinks %v", e.Seq, failed)
+	}
+	return nil
+}
+
+func (f *Forwarder) saveState() error {
+	data, err := jsoniter.Marshal(&f.state)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(f.dir, chainStateFile)
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// deliver sends the queue of the sink in batches, and retries a failed batch
+// with an exponential backoff.
+func (f *Forwarder) deliver(w *sinkWorker) {
+	retry := minRetry
+	for {
+		lines, next, err := w.queue.Peek(sendBatch)
+		if err != nil {
+			log.Logger.Warnf("failed to read audit queue of sink %s, err=%s", w.name, err.Error())
+		}
+		if len(lines) == 0 {
+			select {
+			case <-f.stopper:
+				return
+			case <-w.notify:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if err := w.sink.Send(lines); err != nil {
+			log.Logger.Warnf("failed to send %d audit entries to sink %s, retry in %s, err=%s", len(lines), w.name, retry, err.Error())
+			select {
+			case <-f.stopper:
+				return
+			case <-time.After(retry):
+			}
+			if retry *= 2; retry > maxRetry {
+				retry = maxRetry
+			}
+			continue
+		}
+		retry = minRetry
+		if err := w.queue.Commit(next); err != nil {
+			log.Logger.Warnf("failed to commit audit queue of sink %s, err=%s", w.name, err.Error())
+		}
+	}
+}
+
+func (f *Forwarder) checkpointLoop() {
+	ticker := time.NewTicker(f.checkTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stopper:
+			return
+		case <-ticker.C:
+			if err := f.Checkpoint(); err != nil {
+				log.Logger.Warnf("failed to write audit checkpoint, err=%s", err.Error())
+			}
+		}
+	}
+}
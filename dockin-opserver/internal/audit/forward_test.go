@@ -0,0 +1,265 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flakySink struct {
+	lock     sync.Mutex
+	failures int
+	lines    []string
+}
+
+func (s *flakySink) Send(lines [][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("unavailable")
+	}
+	for _, l := range lines {
+		s.lines = append(s.lines, string(l))
+	}
+	return nil
+}
+
+func (s *flakySink) Lines() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.lines...)
+}
+
+func newTestForwarder(t *testing.T, dir string) *Forwarder {
+	f, err := NewForwarder("node-1", dir, 0, []*SinkConfig{{Type: SinkJSONL, Path: filepath.Join(dir, "audit.jsonl")}})
+	assert.NoError(t, err)
+	f.SetCheckpointKey("k1", "secret", 0)
+	return f
+}
+
+func verifyLines(t *testing.T, lines []string) *VerifyResult {
+	result, err := Verify(strings.NewReader(strings.Join(lines, "\n")+"\n"), map[string]string{"k1": "secret"})
+	assert.NoError(t, err)
+	return result
+}
+
+func TestForwardChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := newTestForwarder(t, dir)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, f.Forward(KindEvent, &Event{Type: EventCommand, User: "alice", Command: strconv.Itoa(i)}))
+	}
+	assert.NoError(t, f.Checkpoint())
+	assert.NoError(t, f.Checkpoint())
+
+	// the chain continues after a restart
+	f = newTestForwarder(t, dir)
+	assert.NoError(t, f.Forward(KindAdmin, &AdminEvent{Actor: "admin", Action: "saveRole"}))
+	f.Start()
+	defer f.Stop()
+
+	path := filepath.Join(dir, "audit.jsonl")
+	assert.Eventually(t, func() bool {
+		data, _ := ioutil.ReadFile(path)
+		return bytes.Count(data, []byte("\n")) == 5
+	}, 5*time.Second, 20*time.Millisecond)
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	result := verifyLines(t, lines)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, 5, result.Entries)
+	assert.Equal(t, 1, result.Checkpoints)
+	assert.Equal(t, []string{"entries 5 to 5 of node node-1 are not covered by a checkpoint"}, result.Notes)
+
+	edited := append([]string{}, lines...)
+	edited[1] = strings.Replace(edited[1], `"command":"1"`, `"command":"x"`, 1)
+	assert.Len(t, verifyLines(t, edited).Problems, 1)
+
+	removed := append(append([]string{}, lines[:1]...), lines[2:]...)
+	assert.Equal(t, []string{"line 2: entries 2 to 2 of node node-1 are missing"}, verifyLines(t, removed).Problems)
+
+	duplicated := append(append([]string{}, lines[:3]...), lines[1:]...)
+	result = verifyLines(t, duplicated)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, 2, result.Duplicates)
+
+	result, err = Verify(strings.NewReader(strings.Join(lines, "\n")), map[string]string{"k1": "other"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 4: signature of checkpoint 4 of node node-1 is invalid"}, result.Problems)
+
+	syslog := "<110>1 2021-06-01T00:00:00Z host dockin-opserver 1 event - " + lines[0]
+	assert.Empty(t, verifyLines(t, []string{syslog, lines[1]}).Problems)
+}
+
+func TestForwardRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f, err := NewForwarder("node-1", dir, 0, nil)
+	assert.NoError(t, err)
+	sink := &flakySink{failures: 1}
+	q, err := openQueue(dir, "flaky", 0)
+	assert.NoError(t, err)
+	f.workers = append(f.workers, &sinkWorker{name: "flaky", sink: sink, queue: q, notify: make(chan struct{}, 1)})
+	f.Start()
+	defer f.Stop()
+
+	assert.NoError(t, f.Forward(KindEvent, &Event{User: "alice"}))
+	assert.NoError(t, f.Forward(KindEvent, &Event{User: "bob"}))
+	assert.Eventually(t, func() bool {
+		return len(sink.Lines()) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, int64(0), q.Len())
+	assert.Empty(t, verifyLines(t, sink.Lines()).Problems)
+}
+
+func TestDiskQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := openQueue(dir, "q", 10)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Append([]byte("a")))
+	assert.NoError(t, q.Append([]byte("bb")))
+	assert.Equal(t, ErrQueueFull, q.Append([]byte("cccccc")))
+
+	lines, next, err := q.Peek(1)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a")}, lines)
+	assert.NoError(t, q.Commit(next))
+
+	q, err = openQueue(dir, "q", 10)
+	assert.NoError(t, err)
+	lines, next, err = q.Peek(10)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("bb")}, lines)
+	assert.NoError(t, q.Commit(next))
+	fi, err := os.Stat(filepath.Join(dir, "q.queue"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), fi.Size())
+}
+
+func TestDiskQueueCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := openQueue(dir, "q", 0)
+	assert.NoError(t, err)
+	q.compactAt = 4
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, q.Append([]byte(line)))
+	}
+	// the delivered head is smaller than the lines left
+	_, next, err := q.Peek(2)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Commit(next))
+	fi, err := os.Stat(filepath.Join(dir, "q.queue"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), fi.Size())
+
+	_, next, err = q.Peek(1)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Commit(next))
+	fi, err = os.Stat(filepath.Join(dir, "q.queue"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), fi.Size())
+	assert.NoError(t, q.Append([]byte("f")))
+
+	q, err = openQueue(dir, "q", 0)
+	assert.NoError(t, err)
+	lines, _, err := q.Peek(10)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("d"), []byte("e"), []byte("f")}, lines)
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var n int
+		fmt.Fscanf(r, "%d ", &n)
+		msg := make([]byte, n)
+		r.Read(msg)
+		received <- string(msg)
+	}()
+
+	s, err := NewSink(&SinkConfig{Name: "siem", Type: SinkSyslog, Address: ln.Addr().String()})
+	assert.NoError(t, err)
+	line := `{"node":"n","seq":1,"time":"2021-06-01T00:00:00Z","kind":"checkpoint","data":{},"prev":"","hash":"h"}`
+	assert.NoError(t, s.Send([][]byte{[]byte(line)}))
+	select {
+	case msg := <-received:
+		assert.True(t, strings.HasPrefix(msg, "<109>1 2021-06-01T00:00:00Z "), msg)
+		assert.True(t, strings.HasSuffix(msg, " checkpoint - "+line), msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no syslog message received")
+	}
+
+	_, err = NewSink(&SinkConfig{Name: "siem", Type: SinkSyslog, Address: "x:1", Network: "udp"})
+	assert.Error(t, err)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		body   string
+		status = http.StatusServiceUnavailable
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = r.Header.Get("Authorization") + " " + string(data)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s, err := NewSink(&SinkConfig{Type: SinkWebhook, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t"}})
+	assert.NoError(t, err)
+	lines := [][]byte{[]byte(`{"seq":1}`), []byte(`{"seq":2}`)}
+	assert.Error(t, s.Send(lines))
+	status = http.StatusOK
+	assert.NoError(t, s.Send(lines))
+	assert.Equal(t, `Bearer t [{"seq":1},{"seq":2}]`, body)
+}
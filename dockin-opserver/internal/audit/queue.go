@@ -0,0 +1,185 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compactSize is how large the delivered head of a queue file grows before
+// it is removed while lines are still pending.
+const compactSize = 4 << 20
+
+var ErrQueueFull = fmt.Errorf("audit queue is full")
+
+// diskQueue keeps the lines not yet delivered to a sink in <name>.queue, and
+// the offset of the first undelivered line in <name>.offset. The file is
+// truncated once every line is delivered, and the delivered head is removed
+// once it is larger than compactAt and the lines left, so that a sink which
+// never catches up does not grow the file without bound.
+type diskQueue struct {
+	lock       sync.Mutex
+	path       string
+	offsetPath string
+	offset     int64
+	size       int64
+	maxSize    int64
+	compactAt  int64
+}
+
+func openQueue(dir, name string, maxSize int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	q := &diskQueue{
+		path:       filepath.Join(dir, name+".queue"),
+		offsetPath: filepath.Join(dir, name+".offset"),
+		maxSize:    maxSize,
+		compactAt:  compactSize,
+	}
+	if fi, err := os.Stat(q.path); err == nil {
+		q.size = fi.Size()
+	}
+	if data, err := ioutil.ReadFile(q.offsetPath); err == nil {
+		q.offset, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if q.offset > q.size {
+		q.offset = q.size
+	}
+	return q, nil
+}
+
+func (q *diskQueue) Append(line []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.maxSize > 0 && q.size-q.offset+int64(len(line))+1 > q.maxSize {
+		return ErrQueueFull
+	}
+	f, err := os.OpenFile(q.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := f.Write(append(line, '\n'))
+	q.size += int64(n)
+	return err
+}
+
+// Peek returns up to max lines from the head of the queue, and the offset to
+// commit once they are delivered.
+func (q *diskQueue) Peek(max int) ([][]byte, int64, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.offset >= q.size {
+		return nil, q.offset, nil
+	}
+	f, err := os.Open(q.path)
+	if err != nil {
+		return nil, q.offset, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(q.offset, io.SeekStart); err != nil {
+		return nil, q.offset, err
+	}
+
+	var (
+		lines [][]byte
+		next  = q.offset
+		r     = bufio.NewReader(io.LimitReader(f, q.size-q.offset))
+	)
+	for len(lines) < max {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		next += int64(len(line))
+		if line = line[:len(line)-1]; len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, next, nil
+}
+
+func (q *diskQueue) Commit(next int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.offset = next
+	if q.offset >= q.size {
+		if err := os.Truncate(q.path, 0); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		q.offset, q.size = 0, 0
+	} else if q.offset >= q.compactAt && q.offset >= q.size-q.offset {
+		return q.compact()
+	}
+	return q.writeOffset(q.offset)
+}
+
+// compact copies the undelivered lines to a new queue file. The offset is
+// reset before the file is replaced, a crash in between sends the delivered
+// lines again, which the receiver tells apart as duplicates.
+func (q *diskQueue) compact() error {
+	src, err := os.Open(q.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := src.Seek(q.offset, io.SeekStart); err != nil {
+		return err
+	}
+	tmpPath := q.path + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(dst, io.LimitReader(src, q.size-q.offset))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = q.writeOffset(0)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, q.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		// keep the offset of the old file
+		if werr := q.writeOffset(q.offset); werr != nil {
+			return werr
+		}
+		return err
+	}
+	q.offset, q.size = 0, n
+	return nil
+}
+
+func (q *diskQueue) writeOffset(offset int64) error {
+	return ioutil.WriteFile(q.offsetPath, []byte(strconv.FormatInt(offset, 10)), 0600)
+}
+
+func (q *diskQueue) Len() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.size - q.offset
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	SinkJSONL   = "jsonl"
+	SinkSyslog  = "syslog"
+	SinkWebhook = "webhook"
+
+	defaultSinkTimeout    = 10 * time.Second
+	defaultSyslogFacility = 13 // log audit
+	syslogAppName         = "dockin-opserver"
+)
+
+// SinkConfig has the fields of an entry of audit.forward.sinks.
+type SinkConfig struct {
+	Name               string            `yaml:"name"`
+	Type               string            `yaml:"type"`
+	Path               string            `yaml:"path"`
+	Network            string            `yaml:"network"`
+	Address            string            `yaml:"address"`
+	CAFile             string            `yaml:"ca-file"`
+	CertFile           string            `yaml:"cert-file"`
+	KeyFile            string            `yaml:"key-file"`
+	InsecureSkipVerify bool              `yaml:"insecure-skip-verify"`
+	Facility           int               `yaml:"facility"`
+	URL                string            `yaml:"url"`
+	Headers            map[string]string `yaml:"headers"`
+	Timeout            int64             `yaml:"timeout"`
+}
+
+// Sink delivers chained entries, one JSON line each. Send either delivers
+// every line or returns an error, the lines are sent again later.
+type Sink interface {
+	Send(lines [][]byte) error
+}
+
+func NewSink(c *SinkConfig) (Sink, error) {
+	timeout := defaultSinkTimeout
+	if c.Timeout > 0 {
+		timeout = time.Duration(c.Timeout) * time.Second
+	}
+	switch c.Type {
+	case SinkJSONL:
+		if c.Path == "" {
+			return nil, errors.Errorf("path of audit sink %s is empty", c.Name)
+		}
+		return &jsonlSink{path: c.Path}, nil
+	case SinkSyslog:
+		return newSyslogSink(c, timeout)
+	case SinkWebhook:
+		if c.URL == "" {
+			return nil, errors.Errorf("url of audit sink %s is empty", c.Name)
+		}
+		return &webhookSink{url: c.URL, headers: c.Headers, client: &http.Client{Timeout: timeout}}, nil
+	}
+	return nil, errors.Errorf("unknown type %s of audit sink %s", c.Type, c.Name)
+}
+
+// jsonlSink appends the lines to a local file.
+type jsonlSink struct {
+	path string
+}
+
+func (s *jsonlSink) Send(lines [][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// syslogSink sends RFC 5424 messages over tcp or tls, framed by octet
+// counting as in RFC 6587. The connection is opened again after an error.
+type syslogSink struct {
+	network  string
+	address  string
+	tls      *tls.Config
+	facility int
+	timeout  time.Duration
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogSink(c *SinkConfig, timeout time.Duration) (*syslogSink, error) {
+	s := &syslogSink{
+		network:  c.Network,
+		address:  c.Address,
+		facility: c.Facility,
+		timeout:  timeout,
+	}
+	if s.address == "" {
+		return nil, errors.Errorf("address of audit sink %s is empty", c.Name)
+	}
+	if s.facility <= 0 {
+		s.facility = defaultSyslogFacility
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+	switch s.network {
+	case "", "tcp":
+		s.network = "tcp"
+	case "tls":
+		s.tls = &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+		if c.CAFile != "" {
+			ca, err := ioutil.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			s.tls.RootCAs = x509.NewCertPool()
+			if !s.tls.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, errors.Errorf("no certificate found in %s", c.CAFile)
+			}
+		}
+		if c.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			s.tls.Certificates = []tls.Certificate{cert}
+		}
+	default:
+		return nil, errors.Errorf("unknown network %s of audit sink %s, use tcp or tls", c.Network, c.Name)
+	}
+	return s, nil
+}
+
+func (s *syslogSink) Send(lines [][]byte) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: s.timeout}
+		var err error
+		if s.tls != nil {
+			s.conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tls)
+		} else {
+			s.conn, err = dialer.Dial("tcp", s.address)
+		}
+		if err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		msg := s.format(line)
+		fmt.Fprintf(buf, "%d %s", len(msg), msg)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *syslogSink) format(line []byte) string {
+	e := &Entry{}
+	jsoniter.Unmarshal(line, e)
+	severity := 6
+	if e.Kind == KindCheckpoint {
+		severity = 5
+	}
+	ts := e.Time
+	if ts == "" {
+		ts = time.Now().Format(time.RFC3339Nano)
+	}
+	msgId := e.Kind
+	if msgId == "" {
+		msgId = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.facility*8+severity, ts, s.hostname, syslogAppName, os.Getpid(), msgId, line)
+}
+
+// webhookSink posts the lines as a JSON array.
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *webhookSink) Send(lines [][]byte) error {
+	body := append([]byte{'['}, bytes.Join(lines, []byte{','})...)
+	body = append(body, ']')
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("webhook %s returned %s", s.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"sort"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// duplicateWindow is how many recent hashes of a node are kept to tell a
+// batch delivered twice from an edited entry.
+const duplicateWindow = 1024
+
+// VerifyResult lists what is wrong with a chain. Notes are not problems, such
+// as entries which are not covered by a checkpoint yet.
+type VerifyResult struct {
+	Entries     int
+	Checkpoints int
+	Duplicates  int
+	Problems    []string
+	Notes       []string
+}
+
+type nodeChain struct {
+	seq        uint64
+	hash       string
+	checkpoint uint64
+	recent     map[uint64]string
+}
+
+// Verify checks the chains in r, one entry per line. Lines forwarded through
+// syslog are accepted too, the entry starts at the first '{'. secrets maps
+// the key ids of checkpoints to their secrets, checkpoints are not checked
+// when it is empty.
+func Verify(r io.Reader, secrets map[string]string) (*VerifyResult, error) {
+	var (
+		result = &VerifyResult{}
+		chains = map[string]*nodeChain{}
+		lineNo int
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		start := bytes.IndexByte(line, '{')
+		e := &Entry{}
+		if start < 0 || jsoniter.Unmarshal(line[start:], e) != nil || e.Hash == "" {
+			result.Problems = append(result.Problems, fmt.Sprintf("line %d is not an audit entry", lineNo))
+			continue
+		}
+		result.Entries++
+		// a modified entry still links the chain by the hash it carries, so
+		// the entries after it are checked as usual
+		if e.digest() != e.Hash {
+			result.Problems = append(result.Problems, fmt.Sprintf("line %d: entry %d of node %s is modified", lineNo, e.Seq, e.Node))
+		}
+
+		c, ok := chains[e.Node]
+		if !ok {
+			c = &nodeChain{recent: map[uint64]string{}}
+			chains[e.Node] = c
+			if e.Seq != 1 {
+				result.Notes = append(result.Notes, fmt.Sprintf("chain of node %s starts at entry %d", e.Node, e.Seq))
+			}
+		} else {
+			switch {
+			case e.Seq <= c.seq:
+				if c.recent[e.Seq] == e.Hash {
+					result.Duplicates++
+					continue
+				}
+				result.Problems = append(result.Problems, fmt.Sprintf("line %d: entry %d of node %s does not match the entry seen before", lineNo, e.Seq, e.Node))
+				continue
+			case e.Seq > c.seq+1:
+				result.Problems = append(result.Problems, fmt.Sprintf("line %d: entries %d to %d of node %s are missing", lineNo, c.seq+1, e.Seq-1, e.Node))
+			case e.Prev != c.hash:
+				result.Problems = append(result.Problems, fmt.Sprintf("line %d: entry %d of node %s does not follow entry %d", lineNo, e.Seq, e.Node, c.seq))
+			}
+		}
+
+		if e.Kind == KindCheckpoint {
+			result.Checkpoints++
+			if problem := verifyCheckpoint(e, secrets); problem != "" {
+				result.Problems = append(result.Problems, fmt.Sprintf("line %d: %s", lineNo, problem))
+			} else {
+				c.checkpoint = e.Seq
+			}
+		}
+		c.seq, c.hash = e.Seq, e.Hash
+		c.recent[e.Seq] = e.Hash
+		delete(c.recent, e.Seq-duplicateWindow)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0, len(chains))
+	for node := range chains {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if c := chains[node]; c.seq > c.checkpoint {
+			result.Notes = append(result.Notes, fmt.Sprintf("entries %d to %d of node %s are not covered by a checkpoint",
+				c.checkpoint+1, c.seq, node))
+		}
+	}
+	return result, nil
+}
+
+func verifyCheckpoint(e *Entry, secrets map[string]string) string {
+	cp := &Checkpoint{}
+	if err := jsoniter.Unmarshal(e.Data, cp); err != nil {
+		return fmt.Sprintf("checkpoint %d of node %s is invalid", e.Seq, e.Node)
+	}
+	if cp.Seq != e.Seq-1 || cp.Hash != e.Prev {
+		return fmt.Sprintf("checkpoint %d of node %s does not sign the entry before it", e.Seq, e.Node)
+	}
+	if len(secrets) == 0 {
+		return ""
+	}
+	secret, ok := secrets[cp.KeyId]
+	if !ok {
+		return fmt.Sprintf("checkpoint %d of node %s is signed by unknown key %s", e.Seq, e.Node, cp.KeyId)
+	}
+	if !hmac.Equal([]byte(cp.Signature), []byte(signCheckpoint(secret, e.Node, cp.Seq, cp.Hash))) {
+		return fmt.Sprintf("signature of checkpoint %d of node %s is invalid", e.Seq, e.Node)
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+)
+
+const ldapTimeout = 5 * time.Second
+
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	GroupBaseDN        string
+	GroupFilter        string
+	GroupAttribute     string
+}
+
+type LDAPProvider struct {
+	cfg *LDAPConfig
+}
+
+func NewLDAPProvider(cfg *LDAPConfig) *LDAPProvider {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = "(member=%s)"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "cn"
+	}
+	return &LDAPProvider{cfg: cfg}
+}
+
+func (p *LDAPProvider) Name() string {
+	return LDAPProviderName
+}
+
+func (p *LDAPProvider) Authenticate(ctx context.Context, userName, password string) (*Identity, error) {
+	// an empty password is an unauthenticated bind, which most servers accept
+	if userName == "" || password == "" {
+		return nil, ErrAuthFailed
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := p.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	sr, err := conn.Search(ldap.NewSearchRequest(p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(ldapTimeout.Seconds()), false, fmt.Sprintf(p.cfg.UserFilter, ldap.EscapeFilter(userName)),
+		[]string{"dn"}, nil))
+	if err != nil {
+		return nil, errors.Errorf("search ldap user failed, err=%s", err.Error())
+	}
+	if len(sr.Entries) != 1 {
+		return nil, ErrAuthFailed
+	}
+	userDN := sr.Entries[0].DN
+
+	if err := conn.Bind(userDN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrAuthFailed
+		}
+		return nil, errors.Errorf("bind ldap user failed, err=%s", err.Error())
+	}
+
+	id := &Identity{UserName: userName}
+	if p.cfg.GroupBaseDN == "" {
+		return id, nil
+	}
+
+	if err := p.bindService(conn); err != nil {
+		return nil, err
+	}
+	gr, err := conn.Search(ldap.NewSearchRequest(p.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, int(ldapTimeout.Seconds()), false, fmt.Sprintf(p.cfg.GroupFilter, ldap.EscapeFilter(userDN)),
+		[]string{p.cfg.GroupAttribute}, nil))
+	if err != nil {
+		return nil, errors.Errorf("search ldap groups failed, err=%s", err.Error())
+	}
+	for _, e := range gr.Entries {
+		id.Groups = append(id.Groups, e.GetAttributeValues(p.cfg.GroupAttribute)...)
+	}
+	return id, nil
+}
+
+func (p *LDAPProvider) dial() (*ldap.Conn, error) {
+	tc := &tls.Config{InsecureSkipVerify: p.cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(p.cfg.URL, ldap.DialWithTLSConfig(tc))
+	if err != nil {
+		return nil, errors.Errorf("dial ldap %s failed, err=%s", p.cfg.URL, err.Error())
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if p.cfg.StartTLS {
+		if err := conn.StartTLS(tc); err != nil {
+			conn.Close()
+			return nil, errors.Errorf("start tls with ldap failed, err=%s", err.Error())
+		}
+	}
+	return conn, nil
+}
+
+func (p *LDAPProvider) bindService(conn *ldap.Conn) error {
+	if p.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+		return errors.Errorf("bind ldap service account failed, err=%s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	serviceDN = "cn=svc,dc=example,dc=com"
+	aliceDN   = "uid=alice,ou=people,dc=example,dc=com"
+)
+
+// fakeLDAP answers simple bind and search requests, searches are matched on
+// the exact filter string.
+type fakeLDAP struct {
+	ln        net.Listener
+	passwords map[string]string
+	entries   map[string][]*ldap.Entry
+}
+
+func newFakeLDAP(t *testing.T) *fakeLDAP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeLDAP{
+		ln: ln,
+		passwords: map[string]string{
+			serviceDN: "svc-pass",
+			aliceDN:   "alice-pass",
+		},
+		entries: map[string][]*ldap.Entry{
+			"(uid=alice)": {ldap.NewEntry(aliceDN, nil)},
+			"(member=" + aliceDN + ")": {
+				ldap.NewEntry("cn=dev,ou=groups,dc=example,dc=com", map[string][]string{"cn": {"dev"}}),
+				ldap.NewEntry("cn=sre,ou=groups,dc=example,dc=com", map[string][]string{"cn": {"sre"}}),
+			},
+		},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeLDAP) URL() string {
+	return "ldap://" + f.ln.Addr().String()
+}
+
+func (f *fakeLDAP) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			pass := op.Children[2].Data.String()
+			code := ldap.LDAPResultSuccess
+			if pw, exist := f.passwords[dn]; !exist || pw != pass {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			conn.Write(ldapResult(id, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for _, e := range f.entries[filter] {
+				conn.Write(ldapEntry(id, e).Bytes())
+			}
+			conn.Write(ldapResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func ldapEnvelope(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	p.AppendChild(op)
+	return p
+}
+
+func ldapResult(id int64, tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(tag), nil, "")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return ldapEnvelope(id, op)
+}
+
+func ldapEntry(id int64, e *ldap.Entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, ""))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for _, a := range e.Attributes {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a.Name, ""))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range a.Values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return ldapEnvelope(id, op)
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	f := newFakeLDAP(t)
+	defer f.ln.Close()
+
+	p := NewLDAPProvider(&LDAPConfig{
+		URL:          f.URL(),
+		BindDN:       serviceDN,
+		BindPassword: "svc-pass",
+		BaseDN:       "ou=people,dc=example,dc=com",
+		GroupBaseDN:  "ou=groups,dc=example,dc=com",
+	})
+
+	id, err := p.Authenticate(context.Background(), "alice", "alice-pass")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", id.UserName)
+	assert.Equal(t, []string{"dev", "sre"}, id.Groups)
+
+	_, err = p.Authenticate(context.Background(), "alice", "wrong")
+	assert.Equal(t, ErrAuthFailed, err)
+
+	_, err = p.Authenticate(context.Background(), "bob", "alice-pass")
+	assert.Equal(t, ErrAuthFailed, err)
+
+	_, err = p.Authenticate(context.Background(), "alice", "")
+	assert.Equal(t, ErrAuthFailed, err)
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+var (
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("slow down")
+)
+
+type OIDCConfig struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+}
+
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type OIDCProvider struct {
+	cfg        *OIDCConfig
+	httpClient *http.Client
+
+	mu             sync.Mutex
+	provider       *oidc.Provider
+	verifier       *oidc.IDTokenVerifier
+	oauth2Config   *oauth2.Config
+	deviceEndpoint string
+}
+
+func NewOIDCProvider(cfg *OIDCConfig) *OIDCProvider {
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "profile"}
+	}
+	return &OIDCProvider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return OIDCProviderName
+}
+
+func (p *OIDCProvider) Authenticate(ctx context.Context, userName, password string) (*Identity, error) {
+	return nil, ErrNotSupported
+}
+
+// discover loads the issuer metadata on first use, so opserver still starts
+// while the issuer is unreachable.
+func (p *OIDCProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return nil
+	}
+
+	ctx = oidc.ClientContext(ctx, p.httpClient)
+	provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return errors.Errorf("discover oidc issuer %s failed, err=%s", p.cfg.Issuer, err.Error())
+	}
+	var meta struct {
+		DeviceEndpoint string `json:"device_authorization_endpoint"`
+	}
+	provider.Claims(&meta)
+
+	p.provider = provider
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	p.oauth2Config = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+	p.deviceEndpoint = meta.DeviceEndpoint
+	return nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	return p.oauth2Config.AuthCodeURL(state), nil
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	tok, err := p.oauth2Config.Exchange(oidc.ClientContext(ctx, p.httpClient), code)
+	if err != nil {
+		return nil, errors.Errorf("exchange oidc code failed, err=%s", err.Error())
+	}
+	raw, _ := tok.Extra("id_token").(string)
+	return p.identityFromIDToken(ctx, raw)
+}
+
+func (p *OIDCProvider) StartDevice(ctx context.Context) (*DeviceAuthorization, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	if p.deviceEndpoint == "" {
+		return nil, fmt.Errorf("oidc issuer does not support device authorization")
+	}
+
+	form := url.Values{}
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	body, status, err := p.postForm(ctx, p.deviceEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, errors.Errorf("oidc device authorization failed, status=%d, body=%s", status, string(body))
+	}
+
+	da := &DeviceAuthorization{}
+	if err := jsoniter.Unmarshal(body, da); err != nil {
+		return nil, errors.Errorf("invalid oidc device authorization response, err=%s", err.Error())
+	}
+	if da.Interval == 0 {
+		da.Interval = 5
+	}
+	return da, nil
+}
+
+// PollDevice asks the issuer once whether the device code has been approved,
+// the caller is responsible for waiting the interval between polls.
+func (p *OIDCProvider) PollDevice(ctx context.Context, deviceCode string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", deviceCodeGrantType)
+	form.Set("device_code", deviceCode)
+	form.Set("client_id", p.cfg.ClientID)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	body, _, err := p.postForm(ctx, p.oauth2Config.Endpoint.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := jsoniter.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Errorf("invalid oidc token response, err=%s", err.Error())
+	}
+	switch resp.Error {
+	case "":
+	case "authorization_pending":
+		return nil, ErrAuthorizationPending
+	case "slow_down":
+		return nil, ErrSlowDown
+	default:
+		return nil, errors.Errorf("oidc device login failed, %s", resp.Error)
+	}
+	return p.identityFromIDToken(ctx, resp.IDToken)
+}
+
+func (p *OIDCProvider) identityFromIDToken(ctx context.Context, raw string) (*Identity, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("no id_token in oidc token response")
+	}
+	idToken, err := p.verifier.Verify(oidc.ClientContext(ctx, p.httpClient), raw)
+	if err != nil {
+		return nil, errors.Errorf("verify oidc id_token failed, err=%s", err.Error())
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, errors.Errorf("parse oidc claims failed, err=%s", err.Error())
+	}
+	userName, _ := claims[p.cfg.UsernameClaim].(string)
+	if userName == "" {
+		return nil, errors.Errorf("oidc claim %s is empty", p.cfg.UsernameClaim)
+	}
+
+	id := &Identity{UserName: userName, Provider: OIDCProviderName}
+	switch groups := claims[p.cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	case string:
+		id.Groups = append(id.Groups, groups)
+	}
+	return id, nil
+}
+
+func (p *OIDCProvider) postForm(ctx context.Context, endpoint string, form url.Values) ([]byte, int, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, errors.Errorf("request oidc endpoint %s failed, err=%s", endpoint, err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
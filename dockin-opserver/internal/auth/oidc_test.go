@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const testClientID = "opserver"
+
+// fakeIssuer is a minimal oidc issuer with discovery, jwks, token and device
+// authorization endpoints.
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                f.URL,
+			"authorization_endpoint":                f.URL + "/auth",
+			"token_endpoint":                        f.URL + "/token",
+			"jwks_uri":                              f.URL + "/keys",
+			"device_authorization_endpoint":         f.URL + "/device",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "k1", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"device_code":      "approved",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": f.URL + "/activate",
+			"expires_in":       600,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			if r.Form.Get("code") != "good" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+		case deviceCodeGrantType:
+			if r.Form.Get("device_code") != "approved" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+				return
+			}
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     f.idToken(t, "alice", []string{"dev"}),
+		})
+	})
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+func (f *fakeIssuer) idToken(t *testing.T, userName string, groups []string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: f.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	raw, err := jwt.Signed(signer).Claims(map[string]interface{}{
+		"iss":                f.URL,
+		"aud":                testClientID,
+		"sub":                "sub-" + userName,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"preferred_username": userName,
+		"groups":             groups,
+	}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	data, _ := json.Marshal(v)
+	w.Write(data)
+}
+
+func newTestOIDCProvider(issuer string) *OIDCProvider {
+	return NewOIDCProvider(&OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/v1/dockin/opserver/ctrl/oidc/callback",
+	})
+}
+
+func TestOIDCAuthorizationCode(t *testing.T) {
+	f := newFakeIssuer(t)
+	defer f.Close()
+	p := newTestOIDCProvider(f.URL)
+	ctx := context.Background()
+
+	u, err := p.AuthCodeURL(ctx, "state-1")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(u, f.URL+"/auth?"))
+	assert.Contains(t, u, "state=state-1")
+
+	id, err := p.Exchange(ctx, "good")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", id.UserName)
+	assert.Equal(t, []string{"dev"}, id.Groups)
+	assert.Equal(t, OIDCProviderName, id.Provider)
+
+	_, err = p.Exchange(ctx, "bad")
+	assert.NotNil(t, err)
+
+	_, err = p.Authenticate(ctx, "alice", "pass")
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+func TestOIDCDeviceFlow(t *testing.T) {
+	f := newFakeIssuer(t)
+	defer f.Close()
+	p := newTestOIDCProvider(f.URL)
+	ctx := context.Background()
+
+	da, err := p.StartDevice(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "ABCD-EFGH", da.UserCode)
+	assert.Equal(t, 5, da.Interval)
+
+	_, err = p.PollDevice(ctx, "waiting")
+	assert.Equal(t, ErrAuthorizationPending, err)
+
+	id, err := p.PollDevice(ctx, da.DeviceCode)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", id.UserName)
+}
+
+func TestOIDCRejectsForeignToken(t *testing.T) {
+	f := newFakeIssuer(t)
+	defer f.Close()
+	other := newFakeIssuer(t)
+	defer other.Close()
+	p := newTestOIDCProvider(f.URL)
+
+	assert.Nil(t, p.discover(context.Background()))
+	_, err := p.identityFromIDToken(context.Background(), other.idToken(t, "mallory", nil))
+	assert.NotNil(t, err)
+}
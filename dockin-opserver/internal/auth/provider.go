@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/webankfintech/dockin-opserver/internal/account"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	StaticProviderName = "static"
+	LDAPProviderName   = "ldap"
+	OIDCProviderName   = "oidc"
+)
+
+var (
+	ErrAuthFailed      = errors.New("user name or password is invalid")
+	ErrNotSupported    = errors.New("password login is not supported by this provider")
+	ErrUnknownProvider = errors.New("unknown auth provider")
+)
+
+type Identity struct {
+	UserName string
+	Groups   []string
+	Roles    []string
+	Provider string
+}
+
+type AuthProvider interface {
+	Name() string
+	Authenticate(ctx context.Context, userName, password string) (*Identity, error)
+}
+
+type Manager struct {
+	providers   []AuthProvider
+	oidc        *OIDCProvider
+	roleMapping map[string][]string
+}
+
+func NewManager(store *account.Store) *Manager {
+	cfg := config.OpsConfig.Auth
+	m := &Manager{roleMapping: make(map[string][]string)}
+	for _, rm := range cfg.RoleMapping {
+		m.roleMapping[rm.Group] = append(m.roleMapping[rm.Group], rm.Roles...)
+	}
+
+	names := cfg.Providers
+	if len(names) == 0 {
+		names = []string{StaticProviderName}
+	}
+	for _, name := range names {
+		switch name {
+		case StaticProviderName:
+			m.providers = append(m.providers, NewStaticProvider(store))
+		case LDAPProviderName:
+			m.providers = append(m.providers, NewLDAPProvider(&LDAPConfig{
+				URL:                cfg.LDAP.URL,
+				StartTLS:           cfg.LDAP.StartTLS,
+				InsecureSkipVerify: cfg.LDAP.InsecureSkipVerify,
+				BindDN:             cfg.LDAP.BindDN,
+				BindPassword:       cfg.LDAP.BindPassword,
+				BaseDN:             cfg.LDAP.BaseDN,
+				UserFilter:         cfg.LDAP.UserFilter,
+				GroupBaseDN:        cfg.LDAP.GroupBaseDN,
+				GroupFilter:        cfg.LDAP.GroupFilter,
+				GroupAttribute:     cfg.LDAP.GroupAttribute,
+			}))
+		case OIDCProviderName:
+			m.oidc = NewOIDCProvider(&OIDCConfig{
+				Issuer:        cfg.OIDC.Issuer,
+				ClientID:      cfg.OIDC.ClientID,
+				ClientSecret:  cfg.OIDC.ClientSecret,
+				RedirectURL:   cfg.OIDC.RedirectURL,
+				Scopes:        cfg.OIDC.Scopes,
+				UsernameClaim: cfg.OIDC.UsernameClaim,
+				GroupsClaim:   cfg.OIDC.GroupsClaim,
+			})
+			m.providers = append(m.providers, m.oidc)
+		default:
+			log.Logger.Warnf("unknown auth provider %s in application.yaml, ignored", name)
+		}
+	}
+	return m
+}
+
+func NewManagerWithProviders(roleMapping map[string][]string, providers ...AuthProvider) *Manager {
+	m := &Manager{providers: providers, roleMapping: roleMapping}
+	for _, p := range providers {
+		if o, ok := p.(*OIDCProvider); ok {
+			m.oidc = o
+		}
+	}
+	return m
+}
+
+// Authenticate checks the password with the named provider, or with every
+// password provider in the configured order when no provider is named.
+func (m *Manager) Authenticate(ctx context.Context, provider, userName, password string) (*Identity, error) {
+	if userName == "" || password == "" {
+		return nil, ErrAuthFailed
+	}
+
+	for _, p := range m.providers {
+		if provider != "" && p.Name() != provider {
+			continue
+		}
+		id, err := p.Authenticate(ctx, userName, password)
+		if err == nil {
+			id.Provider = p.Name()
+			m.MapRoles(id)
+			return id, nil
+		}
+		if err != ErrNotSupported && err != ErrAuthFailed {
+			log.Logger.Warnf("auth provider %s failed, userName=%s, err=%s", p.Name(), userName, err.Error())
+		}
+		if provider != "" {
+			return nil, ErrAuthFailed
+		}
+	}
+
+	if provider != "" {
+		return nil, ErrUnknownProvider
+	}
+	return nil, ErrAuthFailed
+}
+
+func (m *Manager) OIDC() (*OIDCProvider, error) {
+	if m.oidc == nil {
+		return nil, fmt.Errorf("oidc provider is not enabled")
+	}
+	return m.oidc, nil
+}
+
+func (m *Manager) MapRoles(id *Identity) {
+	seen := make(map[string]bool)
+	for _, r := range id.Roles {
+		seen[r] = true
+	}
+	for _, g := range id.Groups {
+		for _, r := range m.roleMapping[g] {
+			if !seen[r] {
+				seen[r] = true
+				id.Roles = append(id.Roles, r)
+			}
+		}
+	}
+}
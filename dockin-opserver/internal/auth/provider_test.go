@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProvider struct {
+	name     string
+	password string
+	groups   []string
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Authenticate(ctx context.Context, userName, password string) (*Identity, error) {
+	if password != f.password {
+		return nil, ErrAuthFailed
+	}
+	return &Identity{UserName: userName, Groups: f.groups}, nil
+}
+
+func TestManagerAuthenticate(t *testing.T) {
+	m := NewManagerWithProviders(map[string][]string{"sre": {"ops", "dev"}, "dev": {"dev"}},
+		&fakeProvider{name: StaticProviderName, password: "static-pass"},
+		&fakeProvider{name: LDAPProviderName, password: "ldap-pass", groups: []string{"sre", "dev"}},
+	)
+	ctx := context.Background()
+
+	id, err := m.Authenticate(ctx, "", "alice", "ldap-pass")
+	assert.Nil(t, err)
+	assert.Equal(t, LDAPProviderName, id.Provider)
+	assert.Equal(t, []string{"ops", "dev"}, id.Roles)
+
+	id, err = m.Authenticate(ctx, "", "alice", "static-pass")
+	assert.Nil(t, err)
+	assert.Equal(t, StaticProviderName, id.Provider)
+	assert.Empty(t, id.Roles)
+
+	_, err = m.Authenticate(ctx, StaticProviderName, "alice", "ldap-pass")
+	assert.Equal(t, ErrAuthFailed, err)
+
+	_, err = m.Authenticate(ctx, "kerberos", "alice", "ldap-pass")
+	assert.Equal(t, ErrUnknownProvider, err)
+
+	_, err = m.Authenticate(ctx, "", "alice", "")
+	assert.Equal(t, ErrAuthFailed, err)
+
+	_, err = m.OIDC()
+	assert.NotNil(t, err)
+}
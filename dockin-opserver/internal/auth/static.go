@@ -0,0 +1,43 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package auth
+
+import (
+	"context"
+
+	"github.com/webankfintech/dockin-opserver/internal/account"
+)
+
+type StaticProvider struct {
+	store *account.Store
+}
+
+func NewStaticProvider(store *account.Store) *StaticProvider {
+	return &StaticProvider{store: store}
+}
+
+func (s *StaticProvider) Name() string {
+	return StaticProviderName
+}
+
+func (s *StaticProvider) Authenticate(ctx context.Context, userName, password string) (*Identity, error) {
+	if err := s.store.Validate(userName, password); err != nil {
+		if err == account.ErrAuthFailed {
+			return nil, ErrAuthFailed
+		}
+		return nil, err
+	}
+	return &Identity{UserName: userName}, nil
+}
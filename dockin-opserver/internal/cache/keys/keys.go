@@ -25,8 +25,18 @@ const (
 	versionKey        = "version"
 	ruleRedisKey      = "rule_access"
 	accountKey        = "user"
+	migratedKey       = "user_migrated"
 	rawCmdRedisKey    = "raw_cmd"
 	commonCmdRedisKey = "common_cmd"
+	blacklistCmdKey   = "blacklist_cmd"
+	policyRoleKey     = "policy_role"
+	policyBindingKey  = "policy_binding"
+	policyCmdRuleKey  = "policy_cmd_rule"
+	policyCmdSetKey   = "policy_cmd_set"
+	filterModeKey     = "filter_mode"
+	filterReportKey   = "filter_report"
+	adminAuditKey     = "audit_admin"
+	recordingIndexKey = "recording_index"
 )
 
 func PodWideAllNamespaceSlotKey(clusterID, rule string) string {
@@ -81,6 +91,63 @@ func AccessTokenKey(userName string) string {
 	return fmt.Sprintf("%s:token_%s", _subsystem, userName)
 }
 
+func RevokedTokenKey(tokenId string) string {
+	return fmt.Sprintf("%s:token_revoked_%s", _subsystem, tokenId)
+}
+
+func UserTokenNotBeforeKey(userName string) string {
+	return fmt.Sprintf("%s:token_nbf_%s", _subsystem, userName)
+}
+
+func OIDCStateKey(state string) string {
+	return fmt.Sprintf("%s:oidc_state_%s", _subsystem, state)
+}
+
+func RecordingKey(id string) string {
+	return fmt.Sprintf("%s:recording_%s", _subsystem, id)
+}
+
+func RecordingMetaKey(id string) string {
+	return fmt.Sprintf("%s:recording_meta_%s", _subsystem, id)
+}
+
+func AuditEventKey(id string) string {
+	return fmt.Sprintf("%s:audit_event_%s", _subsystem, id)
+}
+
+// AuditIndexKey returns the index of all audit events, or of the events
+// whose field has the value.
+func AuditIndexKey(field, value string) string {
+	if field == "" {
+		return fmt.Sprintf("%s:audit_index", _subsystem)
+	}
+	return fmt.Sprintf("%s:audit_index_%s_%s", _subsystem, field, value)
+}
+
+// LiveSessionsKey returns the hash of the running sessions of every
+// instance, keyed by the session id.
+func LiveSessionsKey() string {
+	return fmt.Sprintf("%s:live_sessions", _subsystem)
+}
+
+// LiveSessionSlotsKey returns the sorted set of the running sessions of the
+// user or the pod, scored by the time they were last refreshed.
+func LiveSessionSlotsKey(field, value string) string {
+	return fmt.Sprintf("%s:live_session_slots_%s_%s", _subsystem, field, value)
+}
+
+func LiveSessionControlChannel() string {
+	return fmt.Sprintf("%s:live_session_control", _subsystem)
+}
+
+func LiveSessionOutputChannel(id string) string {
+	return fmt.Sprintf("%s:live_session_output_%s", _subsystem, id)
+}
+
+func PolicyChangeChannel() string {
+	return fmt.Sprintf("%s:policy_change", _subsystem)
+}
+
 func GetRedisWhiteKeyByRule(rule string) string {
 	return fmt.Sprintf("%s_whitelist", rule)
 
@@ -98,6 +165,12 @@ func GetAccountKey() string {
 	return accountKey
 }
 
+// GetMigratedAccountKey returns the set of the accounts in application.yaml
+// which have been migrated to the store.
+func GetMigratedAccountKey() string {
+	return migratedKey
+}
+
 func GetUserNameKey(userName string) string {
 	return fmt.Sprintf("%s_user", userName)
 
@@ -110,3 +183,44 @@ func GetRawCmdRedisKey() string {
 func GetCommonCmdRedisKey() string {
 	return commonCmdRedisKey
 }
+
+func GetBlacklistCmdRedisKey() string {
+	return blacklistCmdKey
+}
+
+func PolicyRoleKey() string {
+	return policyRoleKey
+}
+
+func PolicyBindingKey() string {
+	return policyBindingKey
+}
+
+func PolicyCommandRuleKey() string {
+	return policyCmdRuleKey
+}
+
+func PolicyCommandSetKey() string {
+	return policyCmdSetKey
+}
+
+func FilterModeKey() string {
+	return filterModeKey
+}
+
+func FilterReportKey() string {
+	return filterReportKey
+}
+
+func AdminAuditKey() string {
+	return adminAuditKey
+}
+
+func RecordingIndexKey() string {
+	return recordingIndexKey
+}
+
+// ApprovalKey returns the hash of the approval requests by id.
+func ApprovalKey() string {
+	return fmt.Sprintf("%s:approval", _subsystem)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package notify
+
+import (
+	"sync"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+)
+
+// Topics published when the related data changes in redis.
+const (
+	TopicCommands     = "commands"
+	TopicCommandRules = "command_rules"
+	TopicPolicy       = "policy"
+	TopicFilterMode   = "filter_mode"
+	TopicWhitelist    = "whitelist"
+)
+
+type Listener func(topic string)
+
+// Notifier spreads change notifications to every opserver instance through
+// redis pub/sub.
+type Notifier struct {
+	redisClient *redis.RedisClient
+	lock        sync.RWMutex
+	nextId      int
+	listeners   map[int]Listener
+}
+
+func NewNotifier(rc *redis.RedisClient) *Notifier {
+	return &Notifier{
+		redisClient: rc,
+		listeners:   make(map[int]Listener),
+	}
+}
+
+func (n *Notifier) Publish(topic string) {
+	if err := n.redisClient.Publish(keys.PolicyChangeChannel(), topic); err != nil {
+		log.Logger.Warnf("failed to publish change of %s, err=%s", topic, err.Error())
+	}
+}
+
+// Subscribe registers l for every topic until the returned cancel is called.
+func (n *Notifier) Subscribe(l Listener) (cancel func()) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	id := n.nextId
+	n.nextId++
+	n.listeners[id] = l
+	return func() {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		delete(n.listeners, id)
+	}
+}
+
+func (n *Notifier) Start(stop chan struct{}) {
+	ps := n.redisClient.Subscribe(keys.PolicyChangeChannel())
+	go func() {
+		defer ps.Close()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-stop:
+				log.Logger.Infof("exit policy change subscriber")
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				log.Logger.Infof("receive policy change of %s", msg.Payload)
+				n.dispatch(msg.Payload)
+			}
+		}
+	}()
+}
+
+func (n *Notifier) dispatch(topic string) {
+	n.lock.RLock()
+	listeners := make([]Listener, 0, len(n.listeners))
+	for _, l := range n.listeners {
+		listeners = append(listeners, l)
+	}
+	n.lock.RUnlock()
+
+	for _, l := range listeners {
+		l(topic)
+	}
+}
@@ -12,15 +12,23 @@
  * the License.
  */
 
-package ctrl
+package notify
 
 import (
-	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
-func TestSha(t *testing.T) {
-	user := ""
-	passwd := ""
-	fmt.Println(sha256_v1(user, passwd))
+func TestSubscribe(t *testing.T) {
+	n := NewNotifier(nil)
+	var got []string
+	cancel := n.Subscribe(func(topic string) {
+		got = append(got, topic)
+	})
+
+	n.dispatch(TopicCommands)
+	cancel()
+	n.dispatch(TopicWhitelist)
+	assert.Equal(t, []string{TopicCommands}, got)
 }
@@ -27,6 +27,8 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+const Nil = redis.Nil
+
 type RedisClient struct {
 	Client *redis.Client
 }
@@ -74,6 +76,11 @@ func (r *RedisClient) Get(key string) (interface{}, error) {
 	return val, err
 }
 
+func (r *RedisClient) Exists(key string) (bool, error) {
+	val, err := r.Client.Exists(key).Result()
+	return val > 0, err
+}
+
 func (r *RedisClient) HSet(key, field string, value interface{}) error {
 	_, err := r.Client.HSet(key, field, value).Result()
 	return err
@@ -99,6 +106,10 @@ func (r *RedisClient) HDel(key string, fields ...string) error {
 	return err
 }
 
+func (r *RedisClient) HIncrBy(key, field string, incr int64) (int64, error) {
+	return r.Client.HIncrBy(key, field, incr).Result()
+}
+
 func (r *RedisClient) PipelineSet(data map[string]string, expiration time.Duration) error {
 	return nil
 }
@@ -251,6 +262,64 @@ func (r *RedisClient) SMembers(key string) ([]string, error) {
 	return data, nil
 }
 
+func (r *RedisClient) LPush(key string, values ...interface{}) error {
+	_, err := r.Client.LPush(key, values...).Result()
+	return err
+}
+
+func (r *RedisClient) RPush(key string, values ...interface{}) error {
+	_, err := r.Client.RPush(key, values...).Result()
+	return err
+}
+
+func (r *RedisClient) Expire(key string, expiration time.Duration) error {
+	return r.Client.Expire(key, expiration).Err()
+}
+
+func (r *RedisClient) LTrim(key string, start, stop int64) error {
+	_, err := r.Client.LTrim(key, start, stop).Result()
+	return err
+}
+
+func (r *RedisClient) LRange(key string, start, stop int64) ([]string, error) {
+	return r.Client.LRange(key, start, stop).Result()
+}
+
+func (r *RedisClient) MGet(keys ...string) ([]interface{}, error) {
+	return r.Client.MGet(keys...).Result()
+}
+
+func (r *RedisClient) ZAdd(key string, score float64, member string) error {
+	return r.Client.ZAdd(key, &redis.Z{Score: score, Member: member}).Err()
+}
+
+func (r *RedisClient) ZRem(key string, members ...interface{}) error {
+	return r.Client.ZRem(key, members...).Err()
+}
+
+// Eval runs the lua script atomically in redis.
+func (r *RedisClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return r.Client.Eval(script, keys, args...).Result()
+}
+
+// ZRevRangeByScore returns the members scored between min and max, highest
+// first, min and max may be -inf and +inf.
+func (r *RedisClient) ZRevRangeByScore(key, max, min string, offset, count int64) ([]string, error) {
+	return r.Client.ZRevRangeByScore(key, &redis.ZRangeBy{Max: max, Min: min, Offset: offset, Count: count}).Result()
+}
+
+func (r *RedisClient) ZRemRangeByScore(key, min, max string) error {
+	return r.Client.ZRemRangeByScore(key, min, max).Err()
+}
+
+func (r *RedisClient) Publish(channel string, message interface{}) error {
+	return r.Client.Publish(channel, message).Err()
+}
+
+func (r *RedisClient) Subscribe(channels ...string) *redis.PubSub {
+	return r.Client.Subscribe(channels...)
+}
+
 func (r *RedisClient) Close() {
 	r.Client.Close()
 }
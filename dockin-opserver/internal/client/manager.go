@@ -24,9 +24,11 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
 	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/common"
 	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/sessions"
 	"github.com/webankfintech/dockin-opserver/internal/utils/cmap"
 
 	"github.com/pkg/errors"
@@ -46,6 +48,8 @@ type Manager struct {
 	redisClient *redis.RedisClient
 
 	whitelist *whitelist
+	Notifier  *notify.Notifier
+	Sessions  *sessions.Registry
 }
 
 func NewManager(rc *redis.RedisClient) *Manager {
@@ -56,6 +60,8 @@ func NewManager(rc *redis.RedisClient) *Manager {
 		ListenStopper:         make(chan struct{}),
 		redisClient:           rc,
 		whitelist:             newWhitelist(rc),
+		Notifier:              notify.NewNotifier(rc),
+		Sessions:              sessions.NewRegistry(rc),
 	}
 }
 
@@ -90,7 +96,9 @@ func (m *Manager) Initialize() {
 		})
 	}
 
-	if err := m.whitelist.initialize(m.ListenStopper); err != nil {
+	m.Notifier.Start(m.ListenStopper)
+	m.Sessions.Start(m.ListenStopper)
+	if err := m.whitelist.initialize(m.ListenStopper, m.Notifier); err != nil {
 		log.Logger.Panicf("initialize whitelist failed as %s", err.Error())
 	}
 	appendK8sConfig(clusterPath)
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/notify"
 	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
 	"github.com/webankfintech/dockin-opserver/internal/config"
 
@@ -42,7 +43,7 @@ func newWhitelist(rc *redis.RedisClient) *whitelist {
 	return w
 }
 
-func (w *whitelist) initialize(ch chan struct{}) error {
+func (w *whitelist) initialize(ch chan struct{}, n *notify.Notifier) error {
 	data := w.LoadFromCache()
 	log.Logger.Infof("load whitelist from redis data %s", data)
 
@@ -52,6 +53,13 @@ func (w *whitelist) initialize(ch chan struct{}) error {
 	}
 	w.lastModifyTime = time.Now().Format("2006:01:02 03:04:05")
 	w.addFRedisListener(ch)
+	if n != nil {
+		n.Subscribe(func(topic string) {
+			if topic == notify.TopicWhitelist {
+				w.updateWhitelist()
+			}
+		})
+	}
 	return nil
 }
 
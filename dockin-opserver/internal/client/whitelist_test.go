@@ -39,7 +39,7 @@ func TestWhitelist(t *testing.T) {
 	t.Run("test init", func(t *testing.T) {
 		w := newWhitelist(rc)
 		ch := make(chan struct{})
-		err := w.initialize(ch)
+		err := w.initialize(ch, nil)
 		assert.NoError(t, err)
 		w.printWhitelist()
 	})
@@ -55,7 +55,7 @@ func TestWhitelist(t *testing.T) {
 	t.Run("test update", func(t *testing.T) {
 		w := newWhitelist(rc)
 		ch := make(chan struct{})
-		err := w.initialize(ch)
+		err := w.initialize(ch, nil)
 		assert.NoError(t, err)
 		w.printWhitelist()
 
@@ -77,7 +77,7 @@ func TestWhitelist(t *testing.T) {
 	t.Run("test Allow", func(t *testing.T) {
 		w := newWhitelist(rc)
 		ch := make(chan struct{})
-		err := w.initialize(ch)
+		err := w.initialize(ch, nil)
 		assert.NoError(t, err)
 		w.printWhitelist()
 		assert.Error(t, w.allow("cls-1", "error"))
@@ -31,12 +31,18 @@ type ProxyConfig struct {
 	BatchTimeout        int64  `yaml:"batch-timeout"`
 	HttpPort            int32  `yaml:"http-port"`
 	CmdFilterType       string `yaml:"cmd-filter-type"`
+	CmdFilterMode       string `yaml:"cmd-filter-mode"`
+	ShellHook           bool   `yaml:"shell-hook"`
 	WhileListUpdateTime int64  `yaml:"while-list-update-time"`
 	Limits              struct {
 		ExecForbidden []string `yaml:"exec-forbidden"`
 		ViFileMaxSize int64    `yaml:"vi-file-max-size"`
 		K8SQOS        int32    `yaml:"k8s-qos"`
 		K8SBurst      int32    `yaml:"k8s-burst"`
+		LargeFile     struct {
+			Commands    []string `yaml:"commands"`
+			MemoryRatio float64  `yaml:"memory-ratio"`
+		} `yaml:"large-file"`
 	} `yaml:"limits"`
 	OpAgentPort int32 `yaml:"opagent-port"`
 	RedisConfig struct {
@@ -55,6 +61,122 @@ type ProxyConfig struct {
 	Debug struct {
 		Image string `yaml:"image"`
 	} `yaml:"debug"`
+	Auth struct {
+		Providers   []string `yaml:"providers"`
+		Admins      []string `yaml:"admins"`
+		RoleMapping []struct {
+			Group string   `yaml:"group"`
+			Roles []string `yaml:"roles"`
+		} `yaml:"role-mapping"`
+		LDAP struct {
+			URL                string `yaml:"url"`
+			StartTLS           bool   `yaml:"start-tls"`
+			InsecureSkipVerify bool   `yaml:"insecure-skip-verify"`
+			BindDN             string `yaml:"bind-dn"`
+			BindPassword       string `yaml:"bind-password"`
+			BaseDN             string `yaml:"base-dn"`
+			UserFilter         string `yaml:"user-filter"`
+			GroupBaseDN        string `yaml:"group-base-dn"`
+			GroupFilter        string `yaml:"group-filter"`
+			GroupAttribute     string `yaml:"group-attribute"`
+		} `yaml:"ldap"`
+		OIDC struct {
+			Issuer        string   `yaml:"issuer"`
+			ClientID      string   `yaml:"client-id"`
+			ClientSecret  string   `yaml:"client-secret"`
+			RedirectURL   string   `yaml:"redirect-url"`
+			Scopes        []string `yaml:"scopes"`
+			UsernameClaim string   `yaml:"username-claim"`
+			GroupsClaim   string   `yaml:"groups-claim"`
+		} `yaml:"oidc"`
+	} `yaml:"auth"`
+	Policy struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"policy"`
+	Audit struct {
+		AdminMaxEvents int64 `yaml:"admin-max-events"`
+		Retention      int64 `yaml:"retention"`
+		Forward        struct {
+			Enabled            bool   `yaml:"enabled"`
+			QueueDir           string `yaml:"queue-dir"`
+			MaxQueueSize       int64  `yaml:"max-queue-size"`
+			CheckpointInterval int64  `yaml:"checkpoint-interval"`
+			CheckpointKey      struct {
+				Id     string `yaml:"id"`
+				Secret string `yaml:"secret"`
+			} `yaml:"checkpoint-key"`
+			Sinks []struct {
+				Name               string            `yaml:"name"`
+				Type               string            `yaml:"type"`
+				Path               string            `yaml:"path"`
+				Network            string            `yaml:"network"`
+				Address            string            `yaml:"address"`
+				CAFile             string            `yaml:"ca-file"`
+				CertFile           string            `yaml:"cert-file"`
+				KeyFile            string            `yaml:"key-file"`
+				InsecureSkipVerify bool              `yaml:"insecure-skip-verify"`
+				Facility           int               `yaml:"facility"`
+				URL                string            `yaml:"url"`
+				Headers            map[string]string `yaml:"headers"`
+				Timeout            int64             `yaml:"timeout"`
+			} `yaml:"sinks"`
+		} `yaml:"forward"`
+	} `yaml:"audit"`
+	Record struct {
+		Enabled    bool   `yaml:"enabled"`
+		Backend    string `yaml:"backend"`
+		Dir        string `yaml:"dir"`
+		Input      bool   `yaml:"input"`
+		Expire     int64  `yaml:"expire"`
+		MaxEntries int64  `yaml:"max-entries"`
+	} `yaml:"record"`
+	Session struct {
+		SessionLimits `yaml:",inline"`
+		Rules         map[string]SessionLimits `yaml:"rules"`
+	} `yaml:"session"`
+	Approval struct {
+		MaxDuration    int64 `yaml:"max-duration"`
+		PendingTimeout int64 `yaml:"pending-timeout"`
+	} `yaml:"approval"`
+	Prompt struct {
+		Patterns []string            `yaml:"patterns"`
+		Rules    map[string][]string `yaml:"rules"`
+	} `yaml:"prompt"`
+	Redact struct {
+		Enabled   bool   `yaml:"enabled"`
+		Mask      string `yaml:"mask"`
+		Detectors []struct {
+			Name    string `yaml:"name"`
+			Pattern string `yaml:"pattern"`
+		} `yaml:"detectors"`
+		Entropy struct {
+			MinLength int     `yaml:"min-length"`
+			Threshold float64 `yaml:"threshold"`
+		} `yaml:"entropy"`
+		Default    []string            `yaml:"default"`
+		Rules      map[string][]string `yaml:"rules"`
+		Namespaces map[string][]string `yaml:"namespaces"`
+	} `yaml:"redact"`
+	Token struct {
+		Expire int64 `yaml:"expire"`
+		Keys   []struct {
+			Id        string `yaml:"id"`
+			Secret    string `yaml:"secret"`
+			NotBefore string `yaml:"not-before"`
+			NotAfter  string `yaml:"not-after"`
+		} `yaml:"keys"`
+	} `yaml:"token"`
+}
+
+// SessionLimits limits the lifetime and the number of interactive sessions,
+// times are in seconds and zero disables a limit. The user is warned the
+// Warning seconds before an idle or too long session is closed.
+type SessionLimits struct {
+	IdleTimeout int64 `yaml:"idle-timeout"`
+	Warning     int64 `yaml:"warning"`
+	MaxDuration int64 `yaml:"max-duration"`
+	MaxPerUser  int   `yaml:"max-per-user"`
+	MaxPerPod   int   `yaml:"max-per-pod"`
 }
 
 var (
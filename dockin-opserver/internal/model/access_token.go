@@ -15,63 +15,58 @@
 package model
 
 import (
-	"fmt"
 	"time"
 
-	"github.com/webankfintech/dockin-opserver/internal/common"
 	"github.com/webankfintech/dockin-opserver/internal/log"
-	"github.com/webankfintech/dockin-opserver/internal/utils/aes"
+	"github.com/webankfintech/dockin-opserver/internal/token"
 
-	jsoniter "github.com/json-iterator/go"
-	"github.com/pkg/errors"
+	"github.com/google/uuid"
 )
 
+const OpagentUserName = "dockin-opagent"
+
 type UserIdentity struct {
 	UserName    string    `json:"userName"`
-	Password    string    `json:"password"`
 	Rule        string    `json:"rule"`
+	Groups      []string  `json:"groups,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	TokenId     string    `json:"tokenId"`
 	Expire      int64     `json:"expire"`
 	CreateTime  time.Time `json:"createTime"`
-	AccessToken string    `json:"accessToken"`
+	AccessToken string    `json:"accessToken,omitempty"`
 }
 
-var (
-	expireAt int64 = 3600
-)
-
-func NewUserIdentity(userName, password, rule string) *UserIdentity {
+func NewUserIdentity(userName, rule string) *UserIdentity {
 	return &UserIdentity{
 		UserName:   userName,
-		Password:   password,
 		Rule:       rule,
-		Expire:     expireAt,
+		TokenId:    uuid.New().String(),
+		Expire:     token.Expire(),
 		CreateTime: time.Now(),
 	}
 }
 
+func (at *UserIdentity) ExpireAt() time.Time {
+	return at.CreateTime.Add(time.Duration(at.Expire) * time.Second)
+}
+
+func (at *UserIdentity) Expired() bool {
+	return !time.Now().Before(at.ExpireAt())
+}
+
 func (at *UserIdentity) ToString() (string, error) {
-	aes, err := aes.NewAes(common.ResKey)
+	claims := *at
+	claims.AccessToken = ""
+	str, err := token.Sign(&claims)
 	if err != nil {
-		err = errors.Errorf("create encrypt failed, err=%s", err.Error())
-		log.Logger.Warnf(err.Error())
+		log.Logger.Warnf("failed to sign access token, userName=%s, err=%s", at.UserName, err.Error())
 		return "", err
 	}
-
-	str, err := jsoniter.MarshalToString(at)
-	if err != nil {
-		log.Logger.Warnf("failed to unmarshal access token to string, %v, %v", at, err)
-		return "", fmt.Errorf("failed to unmarshal access token to string")
-	}
-	return aes.AesEncrypt(str)
+	return str, nil
 }
 
 func OpagentAccessToken() string {
-	expire := 3600
-	ui := &UserIdentity{
-		UserName:   "dockin-opagent",
-		Expire:     int64(expire),
-		CreateTime: time.Now(),
-	}
+	ui := NewUserIdentity(OpagentUserName, "")
 	token, _ := ui.ToString()
 	return token
 }
@@ -18,33 +18,27 @@ import (
 	"testing"
 	"time"
 
-	"github.com/webankfintech/dockin-opserver/internal/common"
-	"github.com/webankfintech/dockin-opserver/internal/utils/aes"
+	"github.com/webankfintech/dockin-opserver/internal/token"
 
-	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewAccessToken(t *testing.T) {
-	ac := UserIdentity{
-		UserName:   "bruceliu",
-		Password:   "123456",
-		Expire:     3600,
-		Rule:       "default",
-		CreateTime: time.Now(),
-	}
+	kr, err := token.NewKeyring([]*token.Key{{Id: "k1", Secret: "secret-1"}})
+	assert.NoError(t, err)
+	token.SetKeyring(kr)
+
+	ac := NewUserIdentity("bruceliu", "default")
 	acs, err := ac.ToString()
 	assert.NoError(t, err)
 	t.Log(acs)
-	t.Log(ac)
 
-	aes, err := aes.NewAes(common.ResKey)
-	assert.NoError(t, err)
-	dout, err := aes.AesDecrypt(acs)
-	assert.NoError(t, err)
 	nac := &UserIdentity{}
-	err = jsoniter.UnmarshalFromString(dout, nac)
-	assert.NoError(t, err)
+	assert.NoError(t, token.Verify(acs, nac))
 	assert.Equal(t, ac.UserName, nac.UserName)
-	t.Logf("%v", nac)
+	assert.Equal(t, ac.TokenId, nac.TokenId)
+	assert.False(t, nac.Expired())
+
+	nac.CreateTime = time.Now().Add(-time.Duration(nac.Expire+1) * time.Second)
+	assert.True(t, nac.Expired())
 }
@@ -14,12 +14,18 @@
 
 package model
 
+import "github.com/webankfintech/dockin-opserver/internal/audit"
+
 type ControlDTO struct {
-	WhiteList  []WhiteList
-	RawCommand []CMD
-	Command    []CMD
-	Account    []Account
-	Version    string
+	UserName         string
+	WhiteList        []WhiteList
+	RawCommand       []CMD
+	Command          []CMD
+	BlacklistCommand []CMD
+	Account          []Account
+	Version          string
+	Audit            []*audit.AdminEvent
+	ResKey           string
 }
 
 type Account struct {
@@ -21,6 +21,7 @@ import (
 var (
 	Success      = 0
 	Failed       = -1
+	Pending      = 1
 	WcsAesHeader = "access-token"
 )
 
@@ -70,12 +71,14 @@ type OpsOption struct {
 	Operator  string
 	ClusterId string
 	HostIP    string
+	SubSystem string
 
 	UserName    string
 	Password    string
 	AccessToken string
 	Env         []string
 	User        string
+	Reason      string
 	WorkDir     string
 	Image       string
 }
@@ -35,3 +35,31 @@ type AgentResult struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 }
+
+// ExecResult is the result of a command run by common-exec. ExitCode is -1
+// if the command did not run to its end.
+type ExecResult struct {
+	ExitCode  int    `json:"exitCode"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Duration  int64  `json:"duration"` // in milliseconds
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	TraceId   string `json:"traceId"`
+}
+
+const (
+	FrameStdout = "stdout"
+	FrameStderr = "stderr"
+	FrameExit   = "exit"
+	FrameError  = "error"
+)
+
+// ExecFrame is one line of the streamed result of command-exec: the output
+// of the command as it arrives, then its exit, or an error if it did not run
+// to its end.
+type ExecFrame struct {
+	Type   string      `json:"type"`
+	Data   string      `json:"data,omitempty"`
+	Result *ExecResult `json:"result,omitempty"`
+}
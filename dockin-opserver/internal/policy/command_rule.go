@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CommandRule denies a command when every condition it declares holds:
+// one of Flags is given, one of the arguments matches Args, or one of the
+// path arguments is outside all OutsidePaths.
+type CommandRule struct {
+	Name         string   `json:"name"`
+	Command      string   `json:"command"`
+	Flags        []string `json:"flags"`
+	ValueFlags   []string `json:"valueFlags"`
+	Args         []string `json:"args"`
+	OutsidePaths []string `json:"outsidePaths"`
+	Reason       string   `json:"reason"`
+}
+
+func (r *CommandRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is empty")
+	}
+	if r.Command == "" {
+		return errors.Errorf("command of rule %s is empty", r.Name)
+	}
+	for _, p := range append([]string{r.Command}, r.Args...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Errorf("invalid pattern %s in rule %s", p, r.Name)
+		}
+	}
+	for _, f := range append(r.Flags, r.ValueFlags...) {
+		if !strings.HasPrefix(f, "-") {
+			return errors.Errorf("flag %s in rule %s should start with -", f, r.Name)
+		}
+	}
+	for _, p := range r.OutsidePaths {
+		if !strings.HasPrefix(p, "/") {
+			return errors.Errorf("path %s in rule %s should be absolute", p, r.Name)
+		}
+	}
+	return nil
+}
+
+// Check returns the denial reason, or an empty string when the rule does not
+// apply. args are the words after the command name, relative paths are
+// resolved against cwd, which is empty when it is unknown.
+func (r *CommandRule) Check(cmd string, args []string, cwd string) string {
+	if ok, _ := path.Match(r.Command, cmd); !ok {
+		return ""
+	}
+
+	flags, operands := r.splitArgs(args)
+	if len(r.Flags) > 0 && !r.hasFlag(flags) {
+		return ""
+	}
+	if len(r.Args) > 0 && !anyMatch(r.Args, operands) {
+		return ""
+	}
+	if len(r.OutsidePaths) > 0 && r.outside(operands, cwd) == "" {
+		return ""
+	}
+
+	if r.Reason != "" {
+		return r.Reason
+	}
+	if len(r.OutsidePaths) > 0 {
+		return fmt.Sprintf("%s on %s is denied by rule %s", cmd, r.outside(operands, cwd), r.Name)
+	}
+	return fmt.Sprintf("%s %s is denied by rule %s", cmd, strings.Join(args, " "), r.Name)
+}
+
+func (r *CommandRule) splitArgs(args []string) (flags, operands []string) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return flags, append(operands, args[i+1:]...)
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			operands = append(operands, a)
+			continue
+		}
+
+		if idx := strings.Index(a, "="); strings.HasPrefix(a, "--") && idx > 0 {
+			a = a[:idx]
+		}
+		flags = append(flags, a)
+		if containsString(r.ValueFlags, a) && i+1 < len(args) {
+			i++
+		}
+	}
+	return flags, operands
+}
+
+// hasFlag also matches bundled short flags, so -r matches -rf.
+func (r *CommandRule) hasFlag(flags []string) bool {
+	for _, f := range flags {
+		if containsString(r.Flags, f) {
+			return true
+		}
+		if strings.HasPrefix(f, "--") {
+			continue
+		}
+		for _, c := range f[1:] {
+			if containsString(r.Flags, "-"+string(c)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// outside returns the first operand which is not under any of OutsidePaths.
+// Operands which only the shell can resolve, starting with ~ or holding a
+// variable, a command substitution or a brace expansion, are outside, and so
+// are relative operands when cwd is unknown.
+func (r *CommandRule) outside(operands []string, cwd string) string {
+	for _, o := range operands {
+		if strings.HasPrefix(o, "~") || strings.ContainsAny(o, "$`{") {
+			return o
+		}
+		p := o
+		if !strings.HasPrefix(p, "/") {
+			if cwd == "" {
+				return o
+			}
+			p = path.Join(cwd, p)
+		}
+		p = path.Clean(p)
+
+		under := false
+		for _, base := range r.OutsidePaths {
+			base = path.Clean(base)
+			if p == base || strings.HasPrefix(p, strings.TrimSuffix(base, "/")+"/") {
+				under = true
+				break
+			}
+		}
+		if !under {
+			return o
+		}
+	}
+	return ""
+}
+
+func anyMatch(patterns, values []string) bool {
+	for _, v := range values {
+		if matchAny(patterns, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
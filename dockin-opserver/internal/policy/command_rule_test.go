@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandRuleCheck(t *testing.T) {
+	rules := []*CommandRule{
+		{Name: "rm-recursive", Command: "rm", Flags: []string{"-r", "-R", "--recursive"},
+			OutsidePaths: []string{"/data/app/tmp"}},
+		{Name: "kill-init", Command: "kill", Flags: []string{"-9", "-KILL", "-SIGKILL"}, Args: []string{"1"},
+			Reason: "killing pid 1 restarts the container"},
+		{Name: "tail-logs", Command: "tail", ValueFlags: []string{"-n", "-c"}, OutsidePaths: []string{"/data/logs"}},
+	}
+
+	cases := []struct {
+		cmd    string
+		args   []string
+		cwd    string
+		denied bool
+	}{
+		{"rm", []string{"/data/app/conf.yaml"}, "/data", false},
+		{"rm", []string{"-rf", "/data/app/tmp/cache"}, "/data", false},
+		{"rm", []string{"-r", "cache"}, "/data/app/tmp", false},
+		{"rm", []string{"-rf", "/data/app"}, "/data", true},
+		{"rm", []string{"-fR", "tmp/../../conf"}, "/data/app", true},
+		{"rm", []string{"--recursive", "/data/app/tmp/a", "/etc"}, "/", true},
+		{"rm", []string{"-f", "--", "-r"}, "/data", false},
+		{"kill", []string{"-9", "1234"}, "/", false},
+		{"kill", []string{"1"}, "/", false},
+		{"kill", []string{"-9", "1"}, "/", true},
+		{"kill", []string{"-SIGKILL", "1"}, "/", true},
+		{"tail", []string{"-f", "/data/logs/app.log"}, "/", false},
+		{"tail", []string{"-n", "100", "app.log"}, "/data/logs", false},
+		{"tail", []string{"-n", "100", "/etc/passwd"}, "/data/logs", true},
+		{"tail", []string{"../../etc/shadow"}, "/data/logs", true},
+		{"tail", []string{"/data/logs2/app.log"}, "/", true},
+		{"ls", []string{"-rf", "/"}, "/", false},
+		{"rm", []string{"-r", "cache"}, "", true},
+		{"rm", []string{"-r", "~/cache"}, "/data/app/tmp", true},
+		{"rm", []string{"-r", "$HOME"}, "/data/app/tmp", true},
+		{"rm", []string{"-r", "\"$X\""}, "/data/app/tmp", true},
+		{"rm", []string{"-r", "$(pwd)"}, "/data/app/tmp", true},
+		{"rm", []string{"-r", "/data/app/tmp/{x,../../conf}"}, "/", true},
+	}
+
+	for _, c := range cases {
+		var reason string
+		for _, r := range rules {
+			if reason = r.Check(c.cmd, c.args, c.cwd); reason != "" {
+				break
+			}
+		}
+		assert.Equal(t, c.denied, reason != "", "%s %v in %s: %s", c.cmd, c.args, c.cwd, reason)
+	}
+
+	assert.Equal(t, "killing pid 1 restarts the container", rules[1].Check("kill", []string{"-9", "1"}, "/"))
+	assert.Contains(t, rules[0].Check("rm", []string{"-rf", "/data"}, "/"), "/data")
+}
+
+func TestCommandRuleValidate(t *testing.T) {
+	assert.Error(t, (&CommandRule{Command: "rm"}).Validate())
+	assert.Error(t, (&CommandRule{Name: "r"}).Validate())
+	assert.Error(t, (&CommandRule{Name: "r", Command: "rm", Flags: []string{"r"}}).Validate())
+	assert.Error(t, (&CommandRule{Name: "r", Command: "rm", OutsidePaths: []string{"tmp"}}).Validate())
+	assert.Error(t, (&CommandRule{Name: "r", Command: "[rm"}).Validate())
+	assert.NoError(t, (&CommandRule{Name: "r", Command: "rm", Flags: []string{"-r"}, OutsidePaths: []string{"/tmp"}}).Validate())
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandSet is a named list of commands. Roles reference allow sets through
+// Role.CommandSets, cluster rules and users get sets attached directly.
+type CommandSet struct {
+	Name     string   `json:"name"`
+	Commands []string `json:"commands"`
+	Deny     bool     `json:"deny"`
+	Rules    []string `json:"rules"`
+	Users    []string `json:"users"`
+}
+
+// SessionCommands is the set of commands in effect for a rule and user.
+type SessionCommands struct {
+	AllowSets []string
+	Allow     []string
+	DenySets  []string
+	Deny      []string
+}
+
+func (cs *CommandSet) Validate() error {
+	if cs.Name == "" {
+		return fmt.Errorf("command set name is empty")
+	}
+	if isBuiltinCommandSet(cs.Name) || cs.Name == Wildcard {
+		return fmt.Errorf("command set name %s is reserved", cs.Name)
+	}
+	for _, c := range cs.Commands {
+		if strings.TrimSpace(c) == "" || strings.ContainsAny(c, " \t/") {
+			return fmt.Errorf("invalid command [%s] in command set %s", c, cs.Name)
+		}
+	}
+	return nil
+}
+
+func (cs *CommandSet) attached(rule, user string) bool {
+	for _, r := range cs.Rules {
+		if r == Wildcard || r == rule {
+			return true
+		}
+	}
+	for _, u := range cs.Users {
+		if u == Wildcard || u == user {
+			return true
+		}
+	}
+	return false
+}
+
+func isBuiltinCommandSet(name string) bool {
+	return name == RawCommandSet || name == CommonCommandSet || name == BlacklistCommandSet
+}
+
+// sessionCommands merges the sets attached to rule or user. Without any
+// attached allow set the builtin raw and common sets apply, the builtin
+// blacklist always applies.
+func sessionCommands(sets []*CommandSet, builtin map[string][]string, rule, user string) *SessionCommands {
+	sc := &SessionCommands{}
+	for _, cs := range sets {
+		if !cs.attached(rule, user) {
+			continue
+		}
+		if cs.Deny {
+			sc.DenySets = append(sc.DenySets, cs.Name)
+			sc.Deny = append(sc.Deny, cs.Commands...)
+		} else {
+			sc.AllowSets = append(sc.AllowSets, cs.Name)
+			sc.Allow = append(sc.Allow, cs.Commands...)
+		}
+	}
+	if len(sc.AllowSets) == 0 {
+		sc.AllowSets = []string{RawCommandSet, CommonCommandSet}
+		sc.Allow = append(append(sc.Allow, builtin[RawCommandSet]...), builtin[CommonCommandSet]...)
+	}
+	sc.DenySets = append(sc.DenySets, BlacklistCommandSet)
+	sc.Deny = append(sc.Deny, builtin[BlacklistCommandSet]...)
+
+	sc.Allow = uniqueSorted(sc.Allow)
+	sc.Deny = uniqueSorted(sc.Deny)
+	return sc
+}
+
+// Allowed returns the allowed commands which are not denied, limited to
+// limit unless it is nil.
+func (sc *SessionCommands) Allowed(limit []string) []string {
+	var cmds []string
+	for _, c := range sc.Allow {
+		if containsFold(sc.Deny, c) {
+			continue
+		}
+		if limit != nil && !containsFold(limit, c) {
+			continue
+		}
+		cmds = append(cmds, c)
+	}
+	return cmds
+}
+
+func uniqueSorted(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	var out []string
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionCommands(t *testing.T) {
+	builtin := map[string][]string{
+		RawCommandSet:       {"vi", "top"},
+		CommonCommandSet:    {"ls", "cat"},
+		BlacklistCommandSet: {"reboot"},
+	}
+	sets := []*CommandSet{
+		{Name: "ft-ops", Commands: []string{"ls", "kill"}, Rules: []string{"ft"}},
+		{Name: "alice-debug", Commands: []string{"strace"}, Users: []string{"alice"}},
+		{Name: "no-kill", Commands: []string{"kill"}, Deny: true, Rules: []string{"*"}},
+	}
+
+	t.Run("builtin sets without attached allow set", func(t *testing.T) {
+		sc := sessionCommands(sets, builtin, "test", "bob")
+		assert.Equal(t, []string{RawCommandSet, CommonCommandSet}, sc.AllowSets)
+		assert.Equal(t, []string{"cat", "ls", "top", "vi"}, sc.Allow)
+		assert.Equal(t, []string{"no-kill", BlacklistCommandSet}, sc.DenySets)
+		assert.Equal(t, []string{"kill", "reboot"}, sc.Deny)
+	})
+
+	t.Run("sets attached to rule and user", func(t *testing.T) {
+		sc := sessionCommands(sets, builtin, "ft", "alice")
+		assert.Equal(t, []string{"ft-ops", "alice-debug"}, sc.AllowSets)
+		assert.Equal(t, []string{"kill", "ls", "strace"}, sc.Allow)
+		assert.Equal(t, []string{"ls", "strace"}, sc.Allowed(nil))
+		assert.Equal(t, []string{"ls"}, sc.Allowed([]string{"ls", "cat"}))
+	})
+}
+
+func TestCommandSetValidate(t *testing.T) {
+	assert.NoError(t, (&CommandSet{Name: "dev", Commands: []string{"ls"}}).Validate())
+	assert.Error(t, (&CommandSet{Name: RawCommandSet}).Validate())
+	assert.Error(t, (&CommandSet{Name: "dev", Commands: []string{"rm -rf"}}).Validate())
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+
+	"github.com/webankfintech/dockin-opserver/internal/log"
+)
+
+type Source interface {
+	ListRoles() (map[string]*Role, error)
+	ListBindings() ([]*Binding, error)
+	CommandSet(name string) ([]string, error)
+}
+
+type Engine struct {
+	source Source
+}
+
+func NewEngine(source Source) *Engine {
+	return &Engine{source: source}
+}
+
+func (e *Engine) Evaluate(req *Request) *Decision {
+	bindings, err := e.source.ListBindings()
+	if err != nil {
+		log.Logger.Warnf("failed to load policy bindings, err=%s", err.Error())
+		return deny("policy is unavailable")
+	}
+	roles, err := e.source.ListRoles()
+	if err != nil {
+		log.Logger.Warnf("failed to load policy roles, err=%s", err.Error())
+		return deny("policy is unavailable")
+	}
+
+	var candidates []string
+	for _, b := range bindings {
+		if b.bound(req.User, req.Groups) {
+			candidates = append(candidates, b.Role)
+		}
+	}
+	candidates = append(candidates, req.Roles...)
+
+	reason := fmt.Sprintf("no role is bound to user %s", req.User)
+	for _, name := range candidates {
+		role, exist := roles[name]
+		if !exist {
+			log.Logger.Warnf("user %s refers to unknown role %s", req.User, name)
+			continue
+		}
+		if r := role.match(req); r != "" {
+			reason = r
+			continue
+		}
+
+		d, err := e.decideCommands(role, req.Commands)
+		if err != nil {
+			log.Logger.Warnf("failed to load command sets of role %s, err=%s", role.Name, err.Error())
+			return deny("policy is unavailable")
+		}
+		if d.Allowed {
+			return d
+		}
+		reason = d.Reason
+	}
+	return deny(reason)
+}
+
+func (e *Engine) decideCommands(role *Role, commands []string) (*Decision, error) {
+	d := &Decision{Allowed: true, Role: role.Name}
+	for _, name := range role.CommandSets {
+		if name == Wildcard {
+			d.AllCommands = true
+			d.Commands = nil
+			return d, nil
+		}
+		cmds, err := e.source.CommandSet(name)
+		if err != nil {
+			return nil, err
+		}
+		d.Commands = append(d.Commands, cmds...)
+	}
+
+	for _, c := range commands {
+		if !containsFold(d.Commands, c) {
+			return deny(fmt.Sprintf("role %s does not allow command %s", role.Name, c)), nil
+		}
+	}
+	return d, nil
+}
+
+func deny(reason string) *Decision {
+	return &Decision{Reason: reason}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	roles       map[string]*Role
+	bindings    []*Binding
+	commandSets map[string][]string
+	err         error
+}
+
+func (f *fakeSource) ListRoles() (map[string]*Role, error) {
+	return f.roles, f.err
+}
+
+func (f *fakeSource) ListBindings() ([]*Binding, error) {
+	return f.bindings, f.err
+}
+
+func (f *fakeSource) CommandSet(name string) ([]string, error) {
+	cmds, exist := f.commandSets[name]
+	if !exist {
+		return nil, fmt.Errorf("unknown command set %s", name)
+	}
+	return cmds, nil
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		roles: map[string]*Role{
+			"dev": {
+				Name:        "dev",
+				Subsystems:  []string{"dockin-*"},
+				Namespaces:  []string{"dev"},
+				Pods:        []string{"web-*"},
+				RunAsUsers:  []string{"app"},
+				CommandSets: []string{"common"},
+			},
+			"ops": {
+				Name:        "ops",
+				Subsystems:  []string{"*"},
+				Namespaces:  []string{"*"},
+				Pods:        []string{"*"},
+				RunAsUsers:  []string{"*"},
+				CommandSets: []string{"*"},
+				Actions:     []string{ActionSSH, ActionGet},
+			},
+		},
+		bindings: []*Binding{
+			{Name: "alice-dev", Role: "dev", Users: []string{"alice"}},
+			{Name: "sre-ops", Role: "ops", Groups: []string{"sre"}},
+		},
+		commandSets: map[string][]string{
+			"common": {"ls", "cat", "grep"},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	e := NewEngine(newFakeSource())
+
+	t.Run("allowed by user binding", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "alice", Action: ActionExec, Subsystem: "dockin-qq",
+			Namespace: "dev", Pod: "web-1", RunAs: "app", Commands: []string{"ls", "GREP"}})
+		assert.True(t, d.Allowed, d.Reason)
+		assert.Equal(t, "dev", d.Role)
+		assert.False(t, d.AllCommands)
+		assert.Contains(t, d.Commands, "cat")
+	})
+
+	t.Run("command not in command set", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "alice", Action: ActionExec, Namespace: "dev",
+			Pod: "web-1", RunAs: "app", Commands: []string{"rm"}})
+		assert.False(t, d.Allowed)
+		assert.Contains(t, d.Reason, "command rm")
+	})
+
+	t.Run("scope mismatch", func(t *testing.T) {
+		for _, req := range []*Request{
+			{User: "alice", Subsystem: "other", Namespace: "dev", Pod: "web-1", RunAs: "app"},
+			{User: "alice", Namespace: "prod", Pod: "web-1", RunAs: "app"},
+			{User: "alice", Namespace: "dev", Pod: "db-1", RunAs: "app"},
+			{User: "alice", Namespace: "dev", Pod: "web-1", RunAs: "root"},
+		} {
+			d := e.Evaluate(req)
+			assert.False(t, d.Allowed)
+			assert.Error(t, d.Error())
+		}
+	})
+
+	t.Run("allowed by group binding", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "bob", Groups: []string{"sre"}, Action: ActionSSH,
+			Namespace: "prod", Pod: "db-1", RunAs: "root"})
+		assert.True(t, d.Allowed, d.Reason)
+		assert.True(t, d.AllCommands)
+	})
+
+	t.Run("action not allowed", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "bob", Groups: []string{"sre"}, Action: ActionExec,
+			Namespace: "prod", Pod: "db-1", RunAs: "root"})
+		assert.False(t, d.Allowed)
+	})
+
+	t.Run("approve is listed explicitly", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "alice", Action: ActionApprove, Namespace: "dev", Pod: "web-1"})
+		assert.False(t, d.Allowed)
+		assert.Contains(t, d.Reason, "does not allow action approve")
+	})
+
+	t.Run("allowed by mapped role", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "carol", Roles: []string{"ops"}, Action: ActionGet,
+			Namespace: "prod"})
+		assert.True(t, d.Allowed, d.Reason)
+		assert.Equal(t, "ops", d.Role)
+	})
+
+	t.Run("no binding", func(t *testing.T) {
+		d := e.Evaluate(&Request{User: "mallory", Action: ActionGet, Namespace: "dev"})
+		assert.False(t, d.Allowed)
+		assert.Contains(t, d.Reason, "no role")
+	})
+}
+
+func TestEvaluateFailClosed(t *testing.T) {
+	src := newFakeSource()
+	src.err = fmt.Errorf("redis is down")
+	d := NewEngine(src).Evaluate(&Request{User: "alice", Namespace: "dev"})
+	assert.False(t, d.Allowed)
+
+	src = newFakeSource()
+	src.roles["dev"].CommandSets = []string{"missing"}
+	d = NewEngine(src).Evaluate(&Request{User: "alice", Namespace: "dev", Pod: "web-1", RunAs: "app"})
+	assert.False(t, d.Allowed)
+}
+
+func TestIsRoot(t *testing.T) {
+	for _, u := range []string{"root", "0", "root:root", "0:0", " root", "00", "0000", "00:0", "+0", "4294967296"} {
+		assert.True(t, IsRoot(u), u)
+	}
+	for _, u := range []string{"", "app", "rootless", "1000", "app:root", "0a", "100"} {
+		assert.False(t, IsRoot(u), u)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	FilterModeEnforce = "enforce"
+	FilterModeAudit   = "audit"
+	FilterModeOff     = "off"
+)
+
+// Violation counts the commands a filter in audit mode would have denied.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Command string `json:"command"`
+	Reason  string `json:"reason"`
+	Count   int64  `json:"count"`
+}
+
+func ValidateFilterMode(mode string) error {
+	switch mode {
+	case FilterModeEnforce, FilterModeAudit, FilterModeOff:
+		return nil
+	}
+	return fmt.Errorf("invalid filter mode %s", mode)
+}
+
+// DefaultFilterMode is the mode of rules without their own mode.
+func DefaultFilterMode() string {
+	if ValidateFilterMode(config.OpsConfig.CmdFilterMode) != nil {
+		return FilterModeEnforce
+	}
+	return config.OpsConfig.CmdFilterMode
+}
+
+// FilterMode returns the mode of the rule, enforce is returned together with
+// any error so callers fail closed.
+func (s *Store) FilterMode(rule string) (string, error) {
+	v, err := s.redisClient.HGet(keys.FilterModeKey(), rule)
+	if err == redis.Nil {
+		return DefaultFilterMode(), nil
+	} else if err != nil {
+		return FilterModeEnforce, err
+	}
+	mode := v.(string)
+	if err := ValidateFilterMode(mode); err != nil {
+		return FilterModeEnforce, err
+	}
+	return mode, nil
+}
+
+func (s *Store) ListFilterModes() (map[string]string, error) {
+	return s.redisClient.HGetAll(keys.FilterModeKey())
+}
+
+// SetFilterMode sets the mode of the rule, an empty mode restores the
+// default mode.
+func (s *Store) SetFilterMode(rule, mode string) error {
+	if mode == "" {
+		return s.redisClient.HDel(keys.FilterModeKey(), rule)
+	}
+	if err := ValidateFilterMode(mode); err != nil {
+		return err
+	}
+	return s.redisClient.HSet(keys.FilterModeKey(), rule, mode)
+}
+
+func (s *Store) RecordViolation(rule, cmd, reason string) error {
+	field, _ := jsoniter.MarshalToString([]string{rule, cmd, reason})
+	_, err := s.redisClient.HIncrBy(keys.FilterReportKey(), field, 1)
+	return err
+}
+
+// FilterReport returns the recorded violations of the rule, or of every rule
+// if rule is empty, the most frequent first.
+func (s *Store) FilterReport(rule string) ([]*Violation, error) {
+	data, err := s.redisClient.HGetAll(keys.FilterReportKey())
+	if err != nil {
+		return nil, err
+	}
+	return filterReport(data, rule), nil
+}
+
+// ResetFilterReport removes the recorded violations of the rule, or of every
+// rule if rule is empty.
+func (s *Store) ResetFilterReport(rule string) error {
+	if rule == "" {
+		return s.redisClient.Del(keys.FilterReportKey())
+	}
+	data, err := s.redisClient.HGetAll(keys.FilterReportKey())
+	if err != nil {
+		return err
+	}
+	var fields []string
+	for field := range data {
+		if v := parseViolation(field); v != nil && v.Rule == rule {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.redisClient.HDel(keys.FilterReportKey(), fields...)
+}
+
+func filterReport(data map[string]string, rule string) []*Violation {
+	report := make([]*Violation, 0, len(data))
+	for field, count := range data {
+		v := parseViolation(field)
+		if v == nil || (rule != "" && v.Rule != rule) {
+			continue
+		}
+		fmt.Sscan(count, &v.Count)
+		report = append(report, v)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Count != report[j].Count {
+			return report[i].Count > report[j].Count
+		}
+		return report[i].Command < report[j].Command
+	})
+	return report
+}
+
+func parseViolation(field string) *Violation {
+	var parts []string
+	if err := jsoniter.UnmarshalFromString(field, &parts); err != nil || len(parts) != 3 {
+		return nil
+	}
+	return &Violation{Rule: parts[0], Command: parts[1], Reason: parts[2]}
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterReport(t *testing.T) {
+	data := map[string]string{
+		`["ft","rm","not in the list"]`:   "3",
+		`["ft","kill","not in the list"]`: "7",
+		`["test","rm","not in the list"]`: "1",
+		`invalid`:                         "9",
+	}
+
+	report := filterReport(data, "ft")
+	assert.Len(t, report, 2)
+	assert.Equal(t, &Violation{Rule: "ft", Command: "kill", Reason: "not in the list", Count: 7}, report[0])
+	assert.Equal(t, "rm", report[1].Command)
+
+	assert.Len(t, filterReport(data, ""), 3)
+}
+
+func TestValidateFilterMode(t *testing.T) {
+	for _, mode := range []string{FilterModeEnforce, FilterModeAudit, FilterModeOff} {
+		assert.NoError(t, ValidateFilterMode(mode))
+	}
+	assert.Error(t, ValidateFilterMode("dry-run"))
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	Wildcard = "*"
+
+	ActionSSH      = "ssh"
+	ActionInteract = "interact-exec"
+	ActionExec     = "common-exec"
+	ActionGet      = "get"
+	// ActionApprove decides approval requests of other users, roles allow it
+	// only if they list it.
+	ActionApprove = "approve"
+
+	DefaultRunAsUser = "app"
+	// RootUser is only run as after an elevation, which needs a reason and
+	// a role or an approval allowing it.
+	RootUser = "root"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Subsystems  []string `json:"subsystems"`
+	Namespaces  []string `json:"namespaces"`
+	Pods        []string `json:"pods"`
+	RunAsUsers  []string `json:"runAsUsers"`
+	CommandSets []string `json:"commandSets"`
+	Actions     []string `json:"actions"`
+}
+
+type Binding struct {
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+}
+
+type Request struct {
+	User      string
+	Groups    []string
+	Roles     []string
+	Action    string
+	Subsystem string
+	Namespace string
+	Pod       string
+	RunAs     string
+	Commands  []string
+}
+
+type Decision struct {
+	Allowed     bool
+	Reason      string
+	Role        string
+	Commands    []string
+	AllCommands bool
+}
+
+func (d *Decision) Error() error {
+	if d.Allowed {
+		return nil
+	}
+	return fmt.Errorf("permission denied, %s", d.Reason)
+}
+
+func (r *Role) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("role name is empty")
+	}
+	for _, patterns := range [][]string{r.Subsystems, r.Namespaces, r.Pods, r.RunAsUsers} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern [%s] in role %s", p, r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Binding) Validate() error {
+	if b.Name == "" || b.Role == "" {
+		return fmt.Errorf("binding name or role is empty")
+	}
+	if len(b.Users) == 0 && len(b.Groups) == 0 {
+		return fmt.Errorf("binding %s has no user or group", b.Name)
+	}
+	return nil
+}
+
+func (b *Binding) bound(user string, groups []string) bool {
+	for _, u := range b.Users {
+		if u == Wildcard || u == user {
+			return true
+		}
+	}
+	for _, g := range b.Groups {
+		for _, group := range groups {
+			if g == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match checks every scoped attribute of the request against the role, an
+// empty attribute in the request means it is not part of this action.
+func (r *Role) match(req *Request) string {
+	if (len(r.Actions) > 0 || req.Action == ActionApprove) && !matchAny(r.Actions, req.Action) {
+		return fmt.Sprintf("role %s does not allow action %s", r.Name, req.Action)
+	}
+	if req.Subsystem != "" && !matchAny(r.Subsystems, req.Subsystem) {
+		return fmt.Sprintf("role %s does not allow subsystem %s", r.Name, req.Subsystem)
+	}
+	if req.Namespace != "" && !matchAny(r.Namespaces, req.Namespace) {
+		return fmt.Sprintf("role %s does not allow namespace %s", r.Name, req.Namespace)
+	}
+	if req.Pod != "" && !matchAny(r.Pods, req.Pod) {
+		return fmt.Sprintf("role %s does not allow pod %s", r.Name, req.Pod)
+	}
+	if req.RunAs != "" && !matchAny(r.RunAsUsers, req.RunAs) {
+		return fmt.Sprintf("role %s does not allow run as user %s", r.Name, req.RunAs)
+	}
+	return ""
+}
+
+// IsRoot tells whether the run as user of a request, a name or uid with an
+// optional group, is root. Any spelling of a uid which is 0 as a 32 bit uid,
+// such as 00 or +0, is root.
+func IsRoot(user string) bool {
+	name := strings.SplitN(strings.TrimSpace(user), ":", 2)[0]
+	if uid, err := strconv.ParseInt(name, 10, 64); err == nil {
+		return uint32(uid) == 0
+	}
+	return name == RootUser
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if p == Wildcard {
+			return true
+		}
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(strings.TrimSpace(s), l) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package policy
+
+import (
+	"sort"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	RawCommandSet       = "raw"
+	CommonCommandSet    = "common"
+	BlacklistCommandSet = "blacklist"
+)
+
+type Store struct {
+	redisClient *redis.RedisClient
+}
+
+func NewStore(r *redis.RedisClient) *Store {
+	return &Store{redisClient: r}
+}
+
+func (s *Store) ListRoles() (map[string]*Role, error) {
+	data, err := s.redisClient.HGetAll(keys.PolicyRoleKey())
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string]*Role, len(data))
+	for name, v := range data {
+		role := &Role{}
+		if err := jsoniter.UnmarshalFromString(v, role); err != nil {
+			return nil, errors.Errorf("invalid role %s, err=%s", name, err.Error())
+		}
+		roles[name] = role
+	}
+	return roles, nil
+}
+
+func (s *Store) SaveRole(role *Role) error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	for _, name := range role.CommandSets {
+		if name == Wildcard {
+			continue
+		}
+		if _, err := s.CommandSet(name); err != nil {
+			return err
+		}
+	}
+	data, _ := jsoniter.MarshalToString(role)
+	return s.redisClient.HSet(keys.PolicyRoleKey(), role.Name, data)
+}
+
+func (s *Store) DeleteRole(name string) error {
+	bindings, err := s.ListBindings()
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		if b.Role == name {
+			return errors.Errorf("role %s is still used by binding %s", name, b.Name)
+		}
+	}
+	return s.redisClient.HDel(keys.PolicyRoleKey(), name)
+}
+
+func (s *Store) RoleSnapshot(name string) string {
+	return s.snapshot(keys.PolicyRoleKey(), name)
+}
+
+func (s *Store) BindingSnapshot(name string) string {
+	return s.snapshot(keys.PolicyBindingKey(), name)
+}
+
+func (s *Store) CommandRuleSnapshot(name string) string {
+	return s.snapshot(keys.PolicyCommandRuleKey(), name)
+}
+
+func (s *Store) CommandSetSnapshot(name string) string {
+	return s.snapshot(keys.PolicyCommandSetKey(), name)
+}
+
+func (s *Store) snapshot(key, name string) string {
+	v, err := s.redisClient.HGet(key, name)
+	if err != nil {
+		return ""
+	}
+	return v.(string)
+}
+
+func (s *Store) ListBindings() ([]*Binding, error) {
+	data, err := s.redisClient.HGetAll(keys.PolicyBindingKey())
+	if err != nil {
+		return nil, err
+	}
+	var bindings []*Binding
+	for name, v := range data {
+		b := &Binding{}
+		if err := jsoniter.UnmarshalFromString(v, b); err != nil {
+			return nil, errors.Errorf("invalid binding %s, err=%s", name, err.Error())
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+func (s *Store) SaveBinding(b *Binding) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	exist, err := s.redisClient.HExist(keys.PolicyRoleKey(), b.Role)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return errors.Errorf("role %s does not exist", b.Role)
+	}
+	data, _ := jsoniter.MarshalToString(b)
+	return s.redisClient.HSet(keys.PolicyBindingKey(), b.Name, data)
+}
+
+func (s *Store) DeleteBinding(name string) error {
+	return s.redisClient.HDel(keys.PolicyBindingKey(), name)
+}
+
+func (s *Store) ListCommandRules() ([]*CommandRule, error) {
+	data, err := s.redisClient.HGetAll(keys.PolicyCommandRuleKey())
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*CommandRule, 0, len(data))
+	for name, v := range data {
+		r := &CommandRule{}
+		if err := jsoniter.UnmarshalFromString(v, r); err != nil {
+			return nil, errors.Errorf("invalid command rule %s, err=%s", name, err.Error())
+		}
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules, nil
+}
+
+func (s *Store) SaveCommandRule(r *CommandRule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	data, _ := jsoniter.MarshalToString(r)
+	return s.redisClient.HSet(keys.PolicyCommandRuleKey(), r.Name, data)
+}
+
+func (s *Store) DeleteCommandRule(name string) error {
+	return s.redisClient.HDel(keys.PolicyCommandRuleKey(), name)
+}
+
+// CommandSet returns the commands of an allow set for the policy engine.
+func (s *Store) CommandSet(name string) ([]string, error) {
+	switch name {
+	case RawCommandSet:
+		return s.redisClient.SMembers(keys.GetRawCmdRedisKey())
+	case CommonCommandSet:
+		return s.redisClient.SMembers(keys.GetCommonCmdRedisKey())
+	case BlacklistCommandSet:
+		return nil, errors.Errorf("command set %s can not be used by roles", name)
+	}
+
+	v, err := s.redisClient.HGet(keys.PolicyCommandSetKey(), name)
+	if err == redis.Nil {
+		return nil, errors.Errorf("unknown command set %s", name)
+	} else if err != nil {
+		return nil, err
+	}
+	cs := &CommandSet{}
+	if err := jsoniter.UnmarshalFromString(v.(string), cs); err != nil {
+		return nil, errors.Errorf("invalid command set %s, err=%s", name, err.Error())
+	}
+	if cs.Deny {
+		return nil, errors.Errorf("command set %s can not be used by roles", name)
+	}
+	return cs.Commands, nil
+}
+
+func (s *Store) ListCommandSets() ([]*CommandSet, error) {
+	data, err := s.redisClient.HGetAll(keys.PolicyCommandSetKey())
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]*CommandSet, 0, len(data))
+	for name, v := range data {
+		cs := &CommandSet{}
+		if err := jsoniter.UnmarshalFromString(v, cs); err != nil {
+			return nil, errors.Errorf("invalid command set %s, err=%s", name, err.Error())
+		}
+		sets = append(sets, cs)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+	return sets, nil
+}
+
+func (s *Store) SaveCommandSet(cs *CommandSet) error {
+	if err := cs.Validate(); err != nil {
+		return err
+	}
+	if cs.Deny {
+		if err := s.checkCommandSetUnused(cs.Name); err != nil {
+			return err
+		}
+	}
+	data, _ := jsoniter.MarshalToString(cs)
+	return s.redisClient.HSet(keys.PolicyCommandSetKey(), cs.Name, data)
+}
+
+func (s *Store) DeleteCommandSet(name string) error {
+	if err := s.checkCommandSetUnused(name); err != nil {
+		return err
+	}
+	return s.redisClient.HDel(keys.PolicyCommandSetKey(), name)
+}
+
+func (s *Store) checkCommandSetUnused(name string) error {
+	roles, err := s.ListRoles()
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		for _, set := range role.CommandSets {
+			if set == name {
+				return errors.Errorf("command set %s is still used by role %s", name, role.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// SessionCommands returns the commands allowed and denied for a session of
+// user under the cluster rule.
+func (s *Store) SessionCommands(rule, user string) (*SessionCommands, error) {
+	sets, err := s.ListCommandSets()
+	if err != nil {
+		return nil, err
+	}
+	builtin := make(map[string][]string, 3)
+	for name, key := range map[string]string{
+		RawCommandSet:       keys.GetRawCmdRedisKey(),
+		CommonCommandSet:    keys.GetCommonCmdRedisKey(),
+		BlacklistCommandSet: keys.GetBlacklistCmdRedisKey(),
+	} {
+		if builtin[name], err = s.redisClient.SMembers(key); err != nil {
+			return nil, err
+		}
+	}
+	return sessionCommands(sets, builtin, rule, user), nil
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package record
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	castSuffix = ".cast"
+	metaSuffix = ".json"
+)
+
+// DiskStore keeps each recording in <id>.cast, with its meta in <id>.json.
+type DiskStore struct {
+	dir        string
+	expire     time.Duration
+	maxEntries int64
+}
+
+func NewDiskStore(dir string, expire time.Duration, maxEntries int64) *DiskStore {
+	return &DiskStore{dir: dir, expire: expire, maxEntries: maxEntries}
+}
+
+func (ds *DiskStore) Create(meta *Meta) error {
+	if err := os.MkdirAll(ds.dir, 0700); err != nil {
+		return err
+	}
+	ds.prune()
+	if err := ioutil.WriteFile(ds.path(meta.Id, castSuffix), nil, 0600); err != nil {
+		return err
+	}
+	return ds.Finish(meta)
+}
+
+func (ds *DiskStore) Append(id string, data []byte) error {
+	f, err := os.OpenFile(ds.path(id, castSuffix), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (ds *DiskStore) Finish(meta *Meta) error {
+	data, err := jsoniter.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ds.path(meta.Id, metaSuffix), data, 0600)
+}
+
+func (ds *DiskStore) Get(id string) (*Meta, error) {
+	data, err := ioutil.ReadFile(ds.path(id, metaSuffix))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta := &Meta{}
+	if err := jsoniter.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func (ds *DiskStore) Read(id string) ([]byte, error) {
+	data, err := ioutil.ReadFile(ds.path(id, castSuffix))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// List returns the latest matching recordings first.
+func (ds *DiskStore) List(q *Query) ([]*Meta, error) {
+	all, err := ds.all()
+	if err != nil {
+		return nil, err
+	}
+	metas := make([]*Meta, 0, len(all))
+	for _, meta := range all {
+		if q.match(meta) {
+			metas = append(metas, meta)
+		}
+	}
+	if q.Offset >= int64(len(metas)) {
+		return []*Meta{}, nil
+	}
+	metas = metas[q.Offset:]
+	if q.Limit > 0 && q.Limit < int64(len(metas)) {
+		metas = metas[:q.Limit]
+	}
+	return metas, nil
+}
+
+func (ds *DiskStore) all() ([]*Meta, error) {
+	files, err := ioutil.ReadDir(ds.dir)
+	if os.IsNotExist(err) {
+		return []*Meta{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	metas := make([]*Meta, 0, len(files))
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), metaSuffix) {
+			continue
+		}
+		meta, err := ds.Get(strings.TrimSuffix(f.Name(), metaSuffix))
+		if err != nil {
+			log.Logger.Warnf("invalid recording meta %s, err=%s", f.Name(), err.Error())
+			continue
+		}
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].Start.After(metas[j].Start)
+	})
+	return metas, nil
+}
+
+// prune removes the recordings which expired or are beyond max-entries.
+func (ds *DiskStore) prune() {
+	metas, err := ds.all()
+	if err != nil {
+		log.Logger.Warnf("failed to list recordings, err=%s", err.Error())
+		return
+	}
+	for i, meta := range metas {
+		if (ds.maxEntries > 0 && int64(i) >= ds.maxEntries-1) ||
+			(ds.expire > 0 && time.Since(meta.Start) > ds.expire) {
+			os.Remove(ds.path(meta.Id, castSuffix))
+			os.Remove(ds.path(meta.Id, metaSuffix))
+		}
+	}
+}
+
+func (ds *DiskStore) path(id, suffix string) string {
+	return filepath.Join(ds.dir, id+suffix)
+}
@@ -0,0 +1,320 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+// Package record records interactive sessions in the asciinema v2 cast
+// format, https://docs.asciinema.org/manual/asciicast/v2/.
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+
+	BackendDisk  = "disk"
+	BackendRedis = "redis"
+
+	defaultWidth  = 80
+	defaultHeight = 24
+	defaultDir    = "recordings"
+
+	flushInterval = time.Second
+	flushSize     = 32 * 1024
+)
+
+var (
+	ErrNotFound = fmt.Errorf("recording not found")
+
+	validId = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Meta describes a recorded session, Id is the traceId of the request which
+// opened it.
+type Meta struct {
+	Id        string    `json:"id"`
+	User      string    `json:"user"`
+	Pod       string    `json:"pod"`
+	Namespace string    `json:"namespace"`
+	Container string    `json:"container"`
+	Rule      string    `json:"rule"`
+	Action    string    `json:"action"`
+	ClientIP  string    `json:"clientIp"`
+	Command   string    `json:"command,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end,omitempty"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+}
+
+type header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Command   string `json:"command,omitempty"`
+	Title     string `json:"title"`
+	Env       env    `json:"env"`
+}
+
+type env struct {
+	Term  string `json:"TERM"`
+	Shell string `json:"SHELL"`
+}
+
+// Store keeps the recordings, the cast is appended while the session runs.
+type Store interface {
+	Create(meta *Meta) error
+	Append(id string, data []byte) error
+	Finish(meta *Meta) error
+	Get(id string) (*Meta, error)
+	Read(id string) ([]byte, error)
+	List(q *Query) ([]*Meta, error)
+}
+
+// Query selects recordings of a user or pod, an empty field matches any.
+// Offset and Limit apply to the matching recordings.
+type Query struct {
+	User   string
+	Pod    string
+	Offset int64
+	Limit  int64
+}
+
+func (q *Query) match(meta *Meta) bool {
+	return (q.User == "" || meta.User == q.User) && (q.Pod == "" || meta.Pod == q.Pod)
+}
+
+// NewStore returns the store record.backend selects.
+func NewStore(rc *redis.RedisClient) Store {
+	conf := config.OpsConfig.Record
+	if conf.Backend == BackendRedis {
+		return NewRedisStore(rc, expiration(), conf.MaxEntries)
+	}
+	dir := conf.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	return NewDiskStore(dir, expiration(), conf.MaxEntries)
+}
+
+func ValidId(id string) bool {
+	return validId.MatchString(id)
+}
+
+// Recorder writes the events of one session to the store. A nil Recorder
+// records nothing, so callers need not check whether recording is enabled.
+type Recorder struct {
+	lock    sync.Mutex
+	store   Store
+	meta    *Meta
+	started bool
+	closed  bool
+	buf     bytes.Buffer
+	pending map[string][]byte
+	input   *redact.Stream
+	done    chan struct{}
+}
+
+// Start starts to record the session, it returns nil when recording is
+// disabled or the recording can not be created.
+func Start(store Store, meta *Meta) *Recorder {
+	if !config.OpsConfig.Record.Enabled || store == nil {
+		return nil
+	}
+	meta.Start = time.Now()
+	meta.Width, meta.Height = defaultWidth, defaultHeight
+	if err := store.Create(meta); err != nil {
+		log.Logger.Warnf("failed to create recording, err=%s, traceId=%s", err.Error(), meta.Id)
+		return nil
+	}
+	r := &Recorder{
+		store:   store,
+		meta:    meta,
+		pending: map[string][]byte{},
+		input:   redact.ForRule(meta.Rule, meta.Namespace).NewStream(),
+		done:    make(chan struct{}),
+	}
+	go r.flushLoop()
+	log.Logger.Infof("start to record session of %s on %s, traceId=%s", meta.User, meta.Pod, meta.Id)
+	return r
+}
+
+func (r *Recorder) Output(data []byte) {
+	r.event(EventOutput, data)
+}
+
+// Input records the input of the user unless record.input is off. The input
+// is recorded a line at a time, after redaction.
+func (r *Recorder) Input(data []byte) {
+	if r == nil || !config.OpsConfig.Record.Input {
+		return
+	}
+	r.lock.Lock()
+	data = r.input.Bytes(data)
+	r.lock.Unlock()
+	r.event(EventInput, data)
+}
+
+// Resize records the new terminal size, the size before the first event is
+// the size of the header.
+func (r *Recorder) Resize(cols, rows int) {
+	if r == nil || cols <= 0 || rows <= 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.started {
+		r.meta.Width, r.meta.Height = cols, rows
+		return
+	}
+	r.write(EventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (r *Recorder) event(kind string, data []byte) {
+	if r == nil || len(data) == 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return
+	}
+	// a character may be split between writes, keep the incomplete bytes
+	// for the next event so that the cast stays valid UTF-8
+	data = append(r.pending[kind], data...)
+	n := incompleteTail(data)
+	r.pending[kind] = append([]byte(nil), data[len(data)-n:]...)
+	if len(data) > n {
+		r.write(kind, string(data[:len(data)-n]))
+	}
+}
+
+func (r *Recorder) write(kind, data string) {
+	if !r.started {
+		r.started = true
+		r.writeHeader()
+	}
+	elapsed := time.Since(r.meta.Start).Seconds()
+	event, _ := jsoniter.MarshalToString([]interface{}{jsoniter.RawMessage(strconv.FormatFloat(elapsed, 'f', 6, 64)), kind, data})
+	r.buf.WriteString(event)
+	r.buf.WriteByte('\n')
+	if r.buf.Len() >= flushSize {
+		r.flush()
+	}
+}
+
+func (r *Recorder) writeHeader() {
+	h := &header{
+		Version:   2,
+		Width:     r.meta.Width,
+		Height:    r.meta.Height,
+		Timestamp: r.meta.Start.Unix(),
+		Command:   r.meta.Command,
+		Title:     fmt.Sprintf("%s@%s", r.meta.User, r.meta.Pod),
+		Env:       env{Term: "xterm", Shell: "/bin/bash"},
+	}
+	data, _ := jsoniter.Marshal(h)
+	r.buf.Write(data)
+	r.buf.WriteByte('\n')
+}
+
+func (r *Recorder) flush() {
+	if r.buf.Len() == 0 {
+		return
+	}
+	if err := r.store.Append(r.meta.Id, r.buf.Bytes()); err != nil {
+		log.Logger.Warnf("failed to save recording, err=%s, traceId=%s", err.Error(), r.meta.Id)
+	}
+	r.buf.Reset()
+}
+
+func (r *Recorder) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.lock.Lock()
+			r.flush()
+			r.lock.Unlock()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Close saves the rest of the events and the end of the session.
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	close(r.done)
+	if !r.started {
+		r.started = true
+		r.writeHeader()
+	}
+	if data := r.input.Flush(); data != "" {
+		r.write(EventInput, data)
+	}
+	r.flush()
+	r.meta.End = time.Now()
+	if err := r.store.Finish(r.meta); err != nil {
+		log.Logger.Warnf("failed to finish recording, err=%s, traceId=%s", err.Error(), r.meta.Id)
+	}
+	log.Logger.Infof("finish recording session of %s on %s, traceId=%s", r.meta.User, r.meta.Pod, r.meta.Id)
+}
+
+// incompleteTail returns the length of the incomplete UTF-8 sequence at the
+// end of data.
+func incompleteTail(data []byte) int {
+	for n := 1; n < utf8.UTFMax && n <= len(data); n++ {
+		b := data[len(data)-n]
+		if b < 0x80 {
+			return 0
+		}
+		if utf8.RuneStart(b) {
+			if utf8.FullRune(data[len(data)-n:]) {
+				return 0
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func expiration() time.Duration {
+	return time.Duration(config.OpsConfig.Record.Expire) * time.Second
+}
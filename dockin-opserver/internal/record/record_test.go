@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	conf, redactConf := config.OpsConfig.Record, config.OpsConfig.Redact
+	defer func() { config.OpsConfig.Record, config.OpsConfig.Redact = conf, redactConf }()
+	config.OpsConfig.Redact.Enabled = true
+
+	store := NewDiskStore(dir, 0, 0)
+	config.OpsConfig.Record.Enabled = false
+	assert.Nil(t, Start(store, &Meta{Id: "off"}))
+
+	config.OpsConfig.Record.Enabled = true
+	config.OpsConfig.Record.Input = true
+	r := Start(store, &Meta{Id: "abc", User: "alice", Pod: "pod-0", Action: "ssh"})
+	r.Resize(120, 40)
+	r.Output([]byte("[app@pod-0 ~]$ "))
+	r.Input([]byte("mysql --password=hun"))
+	r.Input([]byte("ter2\r"))
+	r.Output([]byte("\xe4\xbd"))
+	r.Output([]byte("\xa0\r\n"))
+	r.Resize(100, 30)
+	r.Input([]byte("exit"))
+	r.Close()
+	r.Output([]byte("after close"))
+
+	data, err := store.Read("abc")
+	assert.NoError(t, err)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lines [][]byte
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	assert.Len(t, lines, 6)
+
+	h := &header{}
+	assert.NoError(t, jsoniter.Unmarshal(lines[0], h))
+	assert.Equal(t, 2, h.Version)
+	assert.Equal(t, 120, h.Width)
+	assert.Equal(t, 40, h.Height)
+	assert.Equal(t, "alice@pod-0", h.Title)
+
+	var events [][]interface{}
+	for _, l := range lines[1:] {
+		var e []interface{}
+		assert.NoError(t, jsoniter.Unmarshal(l, &e))
+		events = append(events, e)
+	}
+	assert.Equal(t, []interface{}{"o", "[app@pod-0 ~]$ "}, events[0][1:])
+	assert.Equal(t, []interface{}{"i", "mysql --password=******\r"}, events[1][1:])
+	assert.Equal(t, []interface{}{"o", "你\r\n"}, events[2][1:])
+	assert.Equal(t, []interface{}{"r", "100x30"}, events[3][1:])
+	assert.Equal(t, []interface{}{"i", "exit"}, events[4][1:])
+
+	meta, err := store.Get("abc")
+	assert.NoError(t, err)
+	assert.False(t, meta.End.IsZero())
+	_, err = store.Get("missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestDiskStoreList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewDiskStore(dir, time.Hour, 3)
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, store.Create(&Meta{Id: id, User: []string{"alice", "bob"}[i%2], Start: now.Add(time.Duration(i) * time.Minute)}))
+	}
+	assert.NoError(t, store.Finish(&Meta{Id: "old", Start: now.Add(-2 * time.Hour)}))
+
+	metas, err := store.List(&Query{})
+	assert.NoError(t, err)
+	var ids []string
+	for _, m := range metas {
+		ids = append(ids, m.Id)
+	}
+	assert.Equal(t, []string{"d", "c", "b", "old"}, ids)
+
+	metas, err = store.List(&Query{User: "bob", Offset: 1, Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, metas, 1) {
+		assert.Equal(t, "b", metas[0].Id)
+	}
+
+	assert.NoError(t, store.Create(&Meta{Id: "e", Start: now.Add(time.Hour)}))
+	metas, err = store.List(&Query{Offset: 1, Limit: 5})
+	assert.NoError(t, err)
+	assert.Len(t, metas, 2)
+	assert.Equal(t, "d", metas[0].Id)
+
+	assert.True(t, ValidId("3f2a9c0b1d"))
+	assert.False(t, ValidId("../etc/passwd"))
+}
+
+func TestRedisStoreList(t *testing.T) {
+	rc, err := redis.NewRedisClient()
+	assert.NoError(t, err)
+	if err := rc.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+
+	store := NewRedisStore(rc, time.Hour, 0)
+	user := fmt.Sprintf("list-%d", time.Now().UnixNano())
+	for i := 0; i < listBatch+5; i++ {
+		meta := &Meta{Id: fmt.Sprintf("%s-%d", user, i), User: "other"}
+		if i%50 == 0 {
+			meta.User = user
+		}
+		assert.NoError(t, store.Create(meta))
+	}
+
+	metas, err := store.List(&Query{User: user, Limit: 2})
+	assert.NoError(t, err)
+	var ids []string
+	for _, m := range metas {
+		ids = append(ids, m.Id)
+	}
+	assert.Equal(t, []string{user + "-100", user + "-50"}, ids)
+
+	metas, err = store.List(&Query{User: user, Offset: 2, Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, metas, 1) {
+		assert.Equal(t, user+"-0", metas[0].Id)
+	}
+}
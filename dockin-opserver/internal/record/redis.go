@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package record
+
+import (
+	"strings"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// listBatch is how many recordings List reads from the index at a time.
+const listBatch = 100
+
+// RedisStore keeps the cast of each recording in a list of chunks, and the
+// ids of the latest recordings in an index list.
+type RedisStore struct {
+	redisClient *redis.RedisClient
+	expire      time.Duration
+	maxEntries  int64
+}
+
+func NewRedisStore(rc *redis.RedisClient, expire time.Duration, maxEntries int64) *RedisStore {
+	return &RedisStore{redisClient: rc, expire: expire, maxEntries: maxEntries}
+}
+
+func (rs *RedisStore) Create(meta *Meta) error {
+	if err := rs.Finish(meta); err != nil {
+		return err
+	}
+	if err := rs.redisClient.LPush(keys.RecordingIndexKey(), meta.Id); err != nil {
+		return err
+	}
+	if rs.maxEntries > 0 {
+		if err := rs.redisClient.LTrim(keys.RecordingIndexKey(), 0, rs.maxEntries-1); err != nil {
+			log.Logger.Warnf("failed to trim recording index, err=%s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (rs *RedisStore) Append(id string, data []byte) error {
+	if err := rs.redisClient.RPush(keys.RecordingKey(id), string(data)); err != nil {
+		return err
+	}
+	if rs.expire > 0 {
+		return rs.redisClient.Expire(keys.RecordingKey(id), rs.expire)
+	}
+	return nil
+}
+
+func (rs *RedisStore) Finish(meta *Meta) error {
+	data, err := jsoniter.MarshalToString(meta)
+	if err != nil {
+		return err
+	}
+	return rs.redisClient.Set(keys.RecordingMetaKey(meta.Id), data, rs.expire)
+}
+
+func (rs *RedisStore) Get(id string) (*Meta, error) {
+	data, err := rs.redisClient.Get(keys.RecordingMetaKey(id))
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta := &Meta{}
+	if err := jsoniter.UnmarshalFromString(data.(string), meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func (rs *RedisStore) Read(id string) ([]byte, error) {
+	if _, err := rs.Get(id); err != nil {
+		return nil, err
+	}
+	chunks, err := rs.redisClient.LRange(keys.RecordingKey(id), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(chunks, "")), nil
+}
+
+// List returns the latest matching recordings first, expired ones are
+// skipped. It scans the index until limit recordings match.
+func (rs *RedisStore) List(q *Query) ([]*Meta, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	var (
+		metas   = make([]*Meta, 0)
+		skipped int64
+	)
+	for start := int64(0); ; start += listBatch {
+		ids, err := rs.redisClient.LRange(keys.RecordingIndexKey(), start, start+listBatch-1)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			meta, err := rs.Get(id)
+			if err != nil {
+				if err != ErrNotFound {
+					log.Logger.Warnf("failed to get recording %s, err=%s", id, err.Error())
+				}
+				continue
+			}
+			if !q.match(meta) {
+				continue
+			}
+			if skipped < q.Offset {
+				skipped++
+				continue
+			}
+			metas = append(metas, meta)
+			if int64(len(metas)) == limit {
+				return metas, nil
+			}
+		}
+		if int64(len(ids)) < listBatch {
+			return metas, nil
+		}
+	}
+}
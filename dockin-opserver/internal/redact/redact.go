@@ -0,0 +1,364 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package redact
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+)
+
+const DefaultMask = "******"
+
+// builtin detectors
+const (
+	Password   = "password"
+	Token      = "token"
+	PrivateKey = "private-key"
+	IDCard     = "id-card"
+	BankCard   = "bank-card"
+	Entropy    = "entropy"
+)
+
+const (
+	defaultEntropyMinLength = 24
+	defaultEntropyThreshold = 4.0
+)
+
+var (
+	keyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	keyEnd   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	keyBody  = regexp.MustCompile(`[^\r\n]+`)
+
+	builtin = map[string][]*detector{
+		Password: {
+			{re: regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret)["']?\s*[:=]\s*["']?)([^\s"',;]+)`), group: 2},
+			{re: regexp.MustCompile(`(?i)(IDENTIFIED BY\s+['"]?)([^\s"';]+)`), group: 2},
+		},
+		Token: {
+			{re: regexp.MustCompile(`(?i)((?:token|api[_-]?key|access[_-]?key|secret[_-]?key|authorization)["']?\s*[:=]\s*["']?(?:bearer\s+|basic\s+)?)([^\s"',;]+)`), group: 2},
+			{re: regexp.MustCompile(`(?i)(\bbearer\s+)([A-Za-z0-9\-._~+/]{8,}=*)`), group: 2},
+			{re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+			{re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+		},
+		IDCard: {
+			{re: regexp.MustCompile(`\b[1-9]\d{5}(?:19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`), valid: validIDCard},
+		},
+		BankCard: {
+			{re: regexp.MustCompile(`\b\d(?:[ -]?\d){14,18}\b`), valid: luhn},
+		},
+	}
+
+	customLock sync.Mutex
+	custom     map[string][]*detector
+)
+
+type detector struct {
+	re *regexp.Regexp
+	// group is the submatch which is masked, the whole match when 0
+	group int
+	valid func(string) bool
+}
+
+// Redactor masks secrets in text with the detectors selected for a rule or
+// namespace. A nil Redactor returns text unchanged.
+type Redactor struct {
+	detectors  []*detector
+	privateKey bool
+	entropy    bool
+	minLength  int
+	threshold  float64
+	mask       string
+}
+
+// ForRule returns the redactor configured for the rule, or for the namespace
+// when the rule has no own detectors, or the default one. It returns nil when
+// redaction is disabled.
+func ForRule(rule, namespace string) *Redactor {
+	conf := config.OpsConfig.Redact
+	if !conf.Enabled {
+		return nil
+	}
+	names, ok := conf.Rules[rule]
+	if !ok {
+		names, ok = conf.Namespaces[namespace]
+	}
+	if !ok {
+		names = conf.Default
+	}
+	if names == nil {
+		names = []string{Password, Token, PrivateKey, IDCard, BankCard, Entropy}
+	}
+	return New(names)
+}
+
+func New(names []string) *Redactor {
+	conf := config.OpsConfig.Redact
+	r := &Redactor{
+		mask:      conf.Mask,
+		minLength: conf.Entropy.MinLength,
+		threshold: conf.Entropy.Threshold,
+	}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+	if r.minLength <= 0 {
+		r.minLength = defaultEntropyMinLength
+	}
+	if r.threshold <= 0 {
+		r.threshold = defaultEntropyThreshold
+	}
+	for _, name := range names {
+		switch name {
+		case PrivateKey:
+			r.privateKey = true
+		case Entropy:
+			r.entropy = true
+		default:
+			if ds, ok := builtin[name]; ok {
+				r.detectors = append(r.detectors, ds...)
+			} else if ds, ok := customDetectors()[name]; ok {
+				r.detectors = append(r.detectors, ds...)
+			} else {
+				log.Logger.Warnf("unknown redact detector %s, ignore it", name)
+			}
+		}
+	}
+	return r
+}
+
+func customDetectors() map[string][]*detector {
+	customLock.Lock()
+	defer customLock.Unlock()
+	if custom != nil {
+		return custom
+	}
+	custom = map[string][]*detector{}
+	for _, d := range config.OpsConfig.Redact.Detectors {
+		re, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			log.Logger.Warnf("invalid pattern of redact detector %s, err=%s", d.Name, err.Error())
+			continue
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		custom[d.Name] = append(custom[d.Name], &detector{re: re, group: group})
+	}
+	return custom
+}
+
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.NewStream().redact(s)
+}
+
+func (r *Redactor) Bytes(b []byte) []byte {
+	if r == nil {
+		return b
+	}
+	return []byte(r.String(string(b)))
+}
+
+// NewStream returns a Stream for output which arrives in chunks.
+func (r *Redactor) NewStream() *Stream {
+	return &Stream{r: r}
+}
+
+func (r *Redactor) redact(s string) string {
+	for _, d := range r.detectors {
+		s = d.mask(s, r.mask)
+	}
+	if r.entropy {
+		s = entropyToken.ReplaceAllStringFunc(s, func(token string) string {
+			if len(token) < r.minLength || !mixed(token) || shannon(token) < r.threshold {
+				return token
+			}
+			return r.mask
+		})
+	}
+	return s
+}
+
+func (d *detector) mask(s, mask string) string {
+	matches := d.re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 || start < last {
+			continue
+		}
+		if d.valid != nil && !d.valid(s[start:end]) {
+			continue
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(mask)
+		last = end
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// maxPending is how much text without a line break a Stream holds back.
+const maxPending = 4096
+
+// Stream keeps the state between the chunks of a stream. The text after the
+// last line break is held back, up to maxPending bytes, so that a secret
+// split between chunks is redacted as a whole, and a private key is masked
+// even when it is written line by line.
+type Stream struct {
+	r       *Redactor
+	inKey   bool
+	pending string
+}
+
+// String returns the redacted text of the chunk and of the text held back
+// before it, up to the last line break. Typed input ends its lines with \r.
+func (s *Stream) String(chunk string) string {
+	if s == nil || s.r == nil {
+		return chunk
+	}
+	text := s.pending + chunk
+	end := strings.LastIndexAny(text, "\r\n") + 1
+	if end == 0 && len(text) >= maxPending {
+		end = len(text)
+	}
+	s.pending = text[end:]
+	return s.redact(text[:end])
+}
+
+// Flush returns the redacted text held back, once no more output follows
+// soon.
+func (s *Stream) Flush() string {
+	if s == nil || s.r == nil {
+		return ""
+	}
+	text := s.pending
+	s.pending = ""
+	return s.redact(text)
+}
+
+func (s *Stream) redact(chunk string) string {
+	var sb strings.Builder
+	for chunk != "" {
+		if s.inKey {
+			loc := keyEnd.FindStringIndex(chunk)
+			if loc == nil {
+				sb.WriteString(keyBody.ReplaceAllString(chunk, s.r.mask))
+				break
+			}
+			sb.WriteString(keyBody.ReplaceAllString(chunk[:loc[0]], s.r.mask))
+			sb.WriteString(chunk[loc[0]:loc[1]])
+			chunk = chunk[loc[1]:]
+			s.inKey = false
+			continue
+		}
+		var loc []int
+		if s.r.privateKey {
+			loc = keyBegin.FindStringIndex(chunk)
+		}
+		if loc == nil {
+			sb.WriteString(s.r.redact(chunk))
+			break
+		}
+		sb.WriteString(s.r.redact(chunk[:loc[0]]))
+		sb.WriteString(chunk[loc[0]:loc[1]])
+		chunk = chunk[loc[1]:]
+		s.inKey = true
+	}
+	return sb.String()
+}
+
+func (s *Stream) Bytes(chunk []byte) []byte {
+	if s == nil || s.r == nil {
+		return chunk
+	}
+	return []byte(s.String(string(chunk)))
+}
+
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+_\-]+`)
+
+// mixed reports whether the token has upper and lower case letters and
+// digits, so that words, hex ids and uuids are not masked.
+func mixed(token string) bool {
+	return strings.ContainsAny(token, "0123456789") &&
+		strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz") &&
+		strings.ContainsAny(token, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// shannon returns the entropy of the token in bits per character.
+func shannon(token string) float64 {
+	freq := map[rune]float64{}
+	for _, c := range token {
+		freq[c]++
+	}
+	var e float64
+	n := float64(len(token))
+	for _, f := range freq {
+		p := f / n
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+func digits(s string) []int {
+	ds := make([]int, 0, len(s))
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			ds = append(ds, int(c-'0'))
+		}
+	}
+	return ds
+}
+
+func luhn(s string) bool {
+	ds := digits(s)
+	if len(ds) < 13 {
+		return false
+	}
+	sum := 0
+	for i := range ds {
+		d := ds[len(ds)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+var idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// validIDCard checks the check code of a resident identity card number.
+func validIDCard(s string) bool {
+	sum := 0
+	for i, w := range idCardWeights {
+		sum += int(s[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(s[17:])[0]
+}
@@ -0,0 +1,492 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package remote
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxAnalyzeDepth bounds how many nested scripts and wrappers are unwrapped
+// before the command line is rejected.
+const maxAnalyzeDepth = 8
+
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "ksh": true, "dash": true,
+	"ash": true, "csh": true, "tcsh": true, "fish": true,
+}
+
+// wrapper describes a command that runs another command given as its
+// trailing arguments.
+type wrapper struct {
+	valueFlags  []string // flags followed by a value
+	scriptFlags []string // flags whose value is run by a shell
+	operands    int      // operands preceding the wrapped command
+	assignments bool     // NAME=VALUE words may precede the wrapped command
+	script      bool     // the wrapped command words are joined and run by a shell
+	noCommand   bool     // operands are never run as a command
+}
+
+var wrappers = map[string]*wrapper{
+	"env":     {valueFlags: []string{"-u", "--unset", "-C", "--chdir"}, scriptFlags: []string{"-S", "--split-string"}, assignments: true},
+	"sudo":    {valueFlags: []string{"-u", "--user", "-g", "--group", "-C", "--close-from", "-D", "--chdir", "-h", "--host", "-p", "--prompt", "-r", "--role", "-t", "--type", "-T", "--command-timeout", "-U", "--other-user"}, assignments: true},
+	"su":      {valueFlags: []string{"-s", "--shell", "-g", "--group", "-G", "--supp-group"}, scriptFlags: []string{"-c", "--command"}, noCommand: true},
+	"nohup":   {},
+	"time":    {valueFlags: []string{"-f", "--format", "-o", "--output"}},
+	"nice":    {valueFlags: []string{"-n", "--adjustment"}},
+	"ionice":  {valueFlags: []string{"-c", "--class", "-n", "--classdata"}},
+	"timeout": {valueFlags: []string{"-s", "--signal", "-k", "--kill-after"}, operands: 1},
+	"stdbuf":  {valueFlags: []string{"-i", "--input", "-o", "--output", "-e", "--error"}},
+	"setsid":  {},
+	"exec":    {valueFlags: []string{"-a"}},
+	"command": {},
+	"builtin": {},
+	"busybox": {},
+	"chroot":  {valueFlags: []string{"--userspec", "--groups"}, operands: 1},
+	"taskset": {operands: 1},
+	"flock":   {valueFlags: []string{"-w", "--timeout", "-E", "--conflict-exit-code"}, scriptFlags: []string{"-c", "--command"}, operands: 1},
+	"xargs":   {valueFlags: []string{"-a", "--arg-file", "-d", "--delimiter", "-E", "-I", "-L", "-n", "--max-args", "-P", "--max-procs", "-s", "--max-chars"}},
+	"strace":  {valueFlags: []string{"-o", "-e", "-p", "-s", "-u", "-E", "-P"}},
+	"watch":   {valueFlags: []string{"-n", "--interval", "-q", "--equexit"}, script: true},
+}
+
+var findExecActions = []string{"-exec", "-execdir", "-ok", "-okdir"}
+
+// analyzer collects every command a command line would run. Anything it
+// cannot resolve to a literal command is reported as an error so callers
+// can fail closed.
+type analyzer struct {
+	units []*CmdUnit
+	err   error
+	piped map[*syntax.Stmt]bool
+}
+
+func analyzeCmdline(cmdline string) ([]*CmdUnit, error) {
+	a := &analyzer{piped: make(map[*syntax.Stmt]bool)}
+	a.parse(cmdline, 0)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a.units, nil
+}
+
+func (a *analyzer) fail(format string, args ...interface{}) {
+	if a.err == nil {
+		a.err = errors.Errorf(format, args...)
+	}
+}
+
+func (a *analyzer) parse(script string, depth int) {
+	if depth > maxAnalyzeDepth {
+		a.fail("command is nested too deeply")
+		return
+	}
+	prog, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		a.fail("unable to parse command, %s", err)
+		return
+	}
+
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		if a.err != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+				a.piped[n.Y] = true
+			}
+		case *syntax.Stmt:
+			a.stmt(n, depth)
+		case *syntax.DeclClause:
+			a.units = append(a.units, &CmdUnit{cmd: n.Variant.Value})
+		}
+		return true
+	})
+}
+
+func (a *analyzer) stmt(stmt *syntax.Stmt, depth int) {
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		if len(cmd.Args) > 0 {
+			a.call(cmd.Args, stmt, depth)
+		}
+	case *syntax.Subshell:
+		if a.piped[stmt] {
+			a.markPiped(cmd.Stmts)
+		}
+	case *syntax.Block:
+		if a.piped[stmt] {
+			a.markPiped(cmd.Stmts)
+		}
+	}
+}
+
+func (a *analyzer) markPiped(stmts []*syntax.Stmt) {
+	for _, s := range stmts {
+		a.piped[s] = true
+	}
+}
+
+// call records the command and descends into the commands it runs. stmt is
+// nil for commands unwrapped from the arguments of another command.
+func (a *analyzer) call(words []*syntax.Word, stmt *syntax.Stmt, depth int) {
+	if depth > maxAnalyzeDepth {
+		a.fail("command is nested too deeply")
+		return
+	}
+	name, err := commandName(words[0])
+	if err != nil {
+		a.err = err
+		return
+	}
+	cu := newCmdUnit(name, words)
+	if stmt != nil {
+		cu.redirs = stmt.Redirs
+	}
+	a.units = append(a.units, cu)
+
+	args := words[1:]
+	switch {
+	case name == "eval":
+		a.eval(args, depth)
+	case name == "source" || name == ".":
+		a.source(args, stmt, depth)
+	case name == "alias":
+		a.alias(args, depth)
+	case name == "trap":
+		a.trap(args, depth)
+	case name == "find":
+		a.find(args, depth)
+	case shells[name]:
+		a.shell(name, args, stmt, depth)
+	case wrappers[name] != nil:
+		a.unwrap(name, wrappers[name], args, stmt, depth)
+	}
+}
+
+func (a *analyzer) eval(args []*syntax.Word, depth int) {
+	values, ok := literals(args)
+	if !ok {
+		a.fail("arguments of eval must be literal")
+		return
+	}
+	a.parse(strings.Join(values, " "), depth+1)
+}
+
+func (a *analyzer) source(args []*syntax.Word, stmt *syntax.Stmt, depth int) {
+	if len(args) == 0 {
+		return
+	}
+	file, ok := literal(args[0])
+	if !ok {
+		a.fail("file of source must be literal")
+		return
+	}
+	if isStdin(file) {
+		a.stdin("source", stmt, depth)
+	}
+}
+
+func (a *analyzer) alias(args []*syntax.Word, depth int) {
+	for _, w := range args {
+		v, ok := literal(w)
+		if !ok {
+			a.fail("arguments of alias must be literal")
+			return
+		}
+		if idx := strings.Index(v, "="); idx > 0 {
+			a.parse(v[idx+1:], depth+1)
+		}
+	}
+}
+
+func (a *analyzer) trap(args []*syntax.Word, depth int) {
+	for i, w := range args {
+		v, ok := literal(w)
+		if !ok {
+			a.fail("arguments of trap must be literal")
+			return
+		}
+		if v == "-" {
+			return
+		}
+		if v == "--" || (strings.HasPrefix(v, "-") && i == 0) {
+			continue
+		}
+		a.parse(v, depth+1)
+		return
+	}
+}
+
+func (a *analyzer) find(args []*syntax.Word, depth int) {
+	for i := 0; i < len(args); i++ {
+		v, _ := literal(args[i])
+		if !contains(findExecActions, v) {
+			continue
+		}
+		end := i + 1
+		for end < len(args) {
+			t, _ := literal(args[end])
+			if t == ";" || t == "+" {
+				break
+			}
+			end++
+		}
+		if end == i+1 {
+			a.fail("find %s without command", v)
+			return
+		}
+		a.call(args[i+1:end], nil, depth+1)
+		if a.err != nil {
+			return
+		}
+		i = end
+	}
+}
+
+func (a *analyzer) shell(name string, args []*syntax.Word, stmt *syntax.Stmt, depth int) {
+	command, readStdin := false, false
+	for i := 0; i < len(args); i++ {
+		v, ok := literal(args[i])
+		if !ok {
+			a.fail("arguments of %s must be literal", name)
+			return
+		}
+		if v == "--" || v == "-" {
+			continue
+		}
+		if strings.HasPrefix(v, "--") {
+			continue
+		}
+		if strings.HasPrefix(v, "-") || strings.HasPrefix(v, "+") {
+			opts := v[1:]
+			if opts == "o" || opts == "O" {
+				i++
+				continue
+			}
+			command = command || strings.Contains(opts, "c")
+			readStdin = readStdin || strings.Contains(opts, "s")
+			continue
+		}
+		if command {
+			a.parse(v, depth+1)
+			return
+		}
+		if readStdin || isStdin(v) {
+			break
+		}
+		// a script file, only the shell itself is checked
+		return
+	}
+	if command {
+		a.fail("%s -c without command", name)
+		return
+	}
+	a.stdin(name, stmt, depth)
+}
+
+// stdin analyzes the commands a shell reads from its standard input.
+// Interactive shells are left to the following command lines.
+func (a *analyzer) stdin(name string, stmt *syntax.Stmt, depth int) {
+	if stmt == nil {
+		a.fail("%s reading commands from an unknown input is not allowed", name)
+		return
+	}
+	if a.piped[stmt] {
+		a.fail("%s reading commands from a pipe is not allowed", name)
+		return
+	}
+	for _, r := range stmt.Redirs {
+		if r.N != nil && r.N.Value != "0" {
+			continue
+		}
+		switch r.Op {
+		case syntax.Hdoc, syntax.DashHdoc:
+			if r.Hdoc != nil {
+				a.parse(printWord(r.Hdoc), depth+1)
+			}
+		case syntax.WordHdoc:
+			a.parse(wordValue(r.Word), depth+1)
+		case syntax.RdrIn, syntax.RdrInOut:
+			a.fail("%s reading commands from a file is not allowed", name)
+		}
+	}
+}
+
+// unwrap finds the command run by a wrapper. The wrapped command shares
+// the stdin of stmt, except for xargs which consumes it.
+func (a *analyzer) unwrap(name string, w *wrapper, args []*syntax.Word, stmt *syntax.Stmt, depth int) {
+	i, operands, flagsDone := 0, w.operands, false
+	for ; i < len(args); i++ {
+		v, ok := literal(args[i])
+		if !ok {
+			a.fail("arguments of %s must be literal", name)
+			return
+		}
+		if !flagsDone && v == "--" {
+			flagsDone = true
+			continue
+		}
+		if !flagsDone && strings.HasPrefix(v, "-") && len(v) > 1 {
+			flag, value, attached := v, "", false
+			if idx := strings.Index(v, "="); idx > 0 && strings.HasPrefix(v, "--") {
+				flag, value, attached = v[:idx], v[idx+1:], true
+			}
+			if contains(w.scriptFlags, flag) {
+				if !attached {
+					i++
+					if i >= len(args) {
+						a.fail("%s %s without command", name, flag)
+						return
+					}
+					if value, ok = literal(args[i]); !ok {
+						a.fail("arguments of %s must be literal", name)
+						return
+					}
+				}
+				a.parse(value, depth+1)
+				if a.err != nil {
+					return
+				}
+			} else if contains(w.valueFlags, flag) && !attached {
+				i++
+			}
+			continue
+		}
+		if w.assignments && operands == w.operands && strings.Contains(v, "=") {
+			continue
+		}
+		if operands > 0 {
+			operands--
+			continue
+		}
+		if w.noCommand {
+			continue
+		}
+		break
+	}
+
+	if i >= len(args) || w.noCommand {
+		return
+	}
+	if w.script {
+		values, ok := literals(args[i:])
+		if !ok {
+			a.fail("arguments of %s must be literal", name)
+			return
+		}
+		a.parse(strings.Join(values, " "), depth+1)
+		return
+	}
+	if name == "xargs" {
+		stmt = nil
+	}
+	a.call(args[i:], stmt, depth+1)
+}
+
+// commandName resolves the command a word runs. Words which only the shell
+// could resolve, by expansion, globbing or quoting tricks, are rejected.
+func commandName(w *syntax.Word) (string, error) {
+	name, ok := literal(w)
+	if !ok {
+		return "", errors.Errorf("command %s is not a literal", printWord(w))
+	}
+	if name == "" || strings.ContainsAny(name, " \t\n;&|<>()$`\\\"'*?[]{}") {
+		return "", errors.Errorf("command %q is ambiguous", name)
+	}
+	if idx := strings.LastIndex(name, "/"); idx != -1 {
+		name = name[idx+1:]
+	}
+	return name, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isStdin(file string) bool {
+	return file == "-" || file == "/dev/stdin" ||
+		strings.HasPrefix(file, "/dev/fd/") || strings.HasPrefix(file, "/proc/self/fd/")
+}
+
+func literals(words []*syntax.Word) ([]string, bool) {
+	values := make([]string, 0, len(words))
+	for _, w := range words {
+		v, ok := literal(w)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return values, true
+}
+
+// literal returns the value the shell would pass for w, ok is false if
+// the value depends on any expansion.
+func literal(w *syntax.Word) (string, bool) {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescape(p.Value, ""))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			for _, dp := range p.Parts {
+				lit, ok := dp.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(unescape(lit.Value, "$`\"\\\n"))
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// unescape removes backslash escapes, only escapes of chars in special are
+// removed unless special is empty.
+func unescape(s, special string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (special == "" || strings.IndexByte(special, s[i+1]) != -1) {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -23,6 +23,10 @@ const (
 
 	pingPeriod = (pongWait * 9) / 10
 
+	// outputFlushDelay is how long output after the last newline is held
+	// back for redaction when no more output follows, such as a prompt
+	outputFlushDelay = 20 * time.Millisecond
+
 	maxMessageSize = 1024
 )
 
@@ -16,6 +16,7 @@ package remote
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/webankfintech/dockin-opserver/internal/log"
@@ -33,6 +34,7 @@ type ShellPrompt struct {
 	user     string
 	instance string
 	dir      string
+	cwd      string
 }
 
 func (sp *ShellPrompt) String() string {
@@ -40,35 +42,10 @@ func (sp *ShellPrompt) String() string {
 }
 
 func ParserShellPrompt(content string) (*ShellPrompt, error) {
-	if !IsShellPrompt([]byte(content)) {
+	sp := defaultPrompts.Match([]byte(content))
+	if sp == nil {
 		return nil, fmt.Errorf("not a validate shell prompt")
 	}
-	index := strings.Index(content, "]")
-	if index == -1 {
-		return nil, fmt.Errorf("not right ] exist")
-	}
-	buf := content[1:index]
-	indexAt := strings.Index(buf, "@")
-	if indexAt == -1 {
-		return nil, fmt.Errorf("no @ exist in shell prompt")
-	}
-
-	sp := &ShellPrompt{}
-	sp.user = buf[:indexAt]
-	buf = buf[indexAt+1:]
-
-	indexSpace := strings.Index(buf, " ")
-	if indexSpace == -1 {
-		return nil, fmt.Errorf("no space exist in shell prompt")
-	}
-	sp.instance = buf[:indexSpace]
-	buf = buf[indexSpace+1:]
-
-	if len(buf) == 0 {
-		return nil, fmt.Errorf("no work dir exist in shell prompt")
-	}
-	sp.dir = buf
-
 	return sp, nil
 }
 
@@ -78,26 +55,47 @@ type SSHContext struct {
 	CommandFinished       bool
 	WorkingDir            string
 	LastDir               string
+	LastExitCode          int
+	prompts               *PromptMatcher
+	promptKnown           bool
 }
 
 func NewSSHContext() *SSHContext {
-	sshc := &SSHContext{}
+	sshc := &SSHContext{prompts: defaultPrompts}
 	//sshc.LastInputRune = input.LastInputRune
 	sshc.WorkingDir = "/data"
 	return sshc
 }
 
-func (s *SSHContext) setEnvironment(output string) {
-	if IsShellPrompt([]byte(output)) {
-		if ok, cu := isCd(s.CurrentRunningCommand); ok {
-			sp, err := ParserShellPrompt(output)
-			if err != nil {
-				return
-			}
-			log.Logger.Infof("update environment shell prompt:%s", sp.String())
-			s.setCurrentWorkingDir(sp, cu.target)
-		}
+// matchPrompt returns the prompt at the end of a command, the working
+// directory of an OSC 7 sequence is taken as is.
+func (s *SSHContext) matchPrompt(output []byte) *ShellPrompt {
+	if dir, ok := matchOSC7(output); ok {
+		s.promptKnown = true
+		return &ShellPrompt{cwd: dir}
 	}
+	sp := s.prompts.Match(output)
+	if sp != nil {
+		s.promptKnown = true
+	}
+	return sp
+}
+
+func (s *SSHContext) setEnvironment(sp *ShellPrompt) {
+	if sp.cwd != "" {
+		s.setWorkingDir(sp.cwd)
+		return
+	}
+	if ok, cu := isCd(s.CurrentRunningCommand); ok {
+		log.Logger.Infof("update environment shell prompt:%s", sp.String())
+		s.setCurrentWorkingDir(sp, cu.target)
+	}
+}
+
+// forgetPrompt is called when the prompt may change, commands are checked
+// on every line until a known prompt is seen again.
+func (s *SSHContext) forgetPrompt() {
+	s.promptKnown = false
 }
 
 func isCd(cmd string) (bool, *CmdUnit) {
@@ -119,19 +117,27 @@ func isExecSuccess(output string) bool {
 }
 
 func (s *SSHContext) setCurrentWorkingDir(ps *ShellPrompt, target string) {
-	if ps.dir == "~" {
+	if ps.dir == "" {
+		s.LastDir, s.WorkingDir = s.WorkingDir, ""
+		log.Logger.Warnf("no dir in the shell prompt, the current dir is unknown, click [%s]", target)
+		return
+	}
+	if ps.dir == "~" || strings.HasPrefix(ps.dir, "~/") {
 		s.LastDir = s.WorkingDir
 		if ps.user == "app" {
 			s.WorkingDir = AppUserDir
 		} else {
 			s.WorkingDir = RootUserDir
 		}
+		if ps.dir != "~" {
+			s.WorkingDir = path.Join(s.WorkingDir, ps.dir[2:])
+		}
 		log.Logger.Infof("~, update current dir from [%s] to [%s], click [%s]", s.LastDir, s.WorkingDir, target)
 		return
 	}
-	if ps.dir == "/" {
+	if strings.HasPrefix(ps.dir, "/") {
 		s.LastDir = s.WorkingDir
-		s.WorkingDir = "/"
+		s.WorkingDir = ps.dir
 		log.Logger.Infof("/, update current dir from [%s] to [%s], click [%s]", s.LastDir, s.WorkingDir, target)
 		return
 	}
@@ -150,7 +156,26 @@ func (s *SSHContext) setCurrentWorkingDir(ps *ShellPrompt, target string) {
 		s.LastDir = s.WorkingDir
 		s.WorkingDir = maybe
 		log.Logger.Infof("has suffix, update current dir from [%s] to [%s], click [%s]", s.LastDir, s.WorkingDir, target)
+		return
 	}
+	if s.WorkingDir == "" || path.Base(s.WorkingDir) != ps.dir {
+		s.LastDir, s.WorkingDir = s.WorkingDir, ""
+		log.Logger.Warnf("dir %s of the shell prompt does not match [%s], the current dir is unknown", ps.dir, maybe)
+	}
+}
+
+// setWorkingDir sets the working directory the shell hook reported.
+func (s *SSHContext) setWorkingDir(dir string) {
+	if dir == "" || dir == s.WorkingDir {
+		return
+	}
+	s.LastDir, s.WorkingDir = s.WorkingDir, dir
+}
+
+func (s *SSHContext) setCommandFinished(status int, dir string) {
+	s.CommandFinished = true
+	s.LastExitCode = status
+	s.setWorkingDir(dir)
 }
 
 func (s *SSHContext) getCurrentWorkingDir() string {
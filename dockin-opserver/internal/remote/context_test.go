@@ -60,3 +60,55 @@ func Test_ParserShellPrompt(t *testing.T) {
 		assert.Equal(t, ps.dir, "appsystems")
 	})
 }
+
+func Test_PromptMatcher(t *testing.T) {
+	pm := NewPromptMatcher([]string{`(?P<user>\w+)@(?P<host>[\w.-]+):(?P<dir>[^$#]+)[$#] $`, "("})
+
+	sp := pm.Match([]byte("done\r\napp@pod-0:~/logs$ "))
+	assert.NotNil(t, sp)
+	assert.Equal(t, "app", sp.user)
+	assert.Equal(t, "pod-0", sp.instance)
+	assert.Equal(t, "~/logs", sp.dir)
+	assert.Nil(t, pm.Match([]byte("[app@pod-0 ~]$ ")))
+	assert.NotNil(t, NewPromptMatcher(nil).Match([]byte("[app@pod-0 ~]$ ")))
+
+	dir, ok := matchOSC7([]byte("\x1b]7;file://pod-0/data/my%20app\x1b\\$ "))
+	assert.True(t, ok)
+	assert.Equal(t, "/data/my app", dir)
+}
+
+func Test_setCurrentWorkingDir(t *testing.T) {
+	sc := NewSSHContext()
+	sc.setCurrentWorkingDir(&ShellPrompt{user: "app", dir: "~/logs"}, "logs")
+	assert.Equal(t, "/data/app/logs", sc.WorkingDir)
+	sc.setCurrentWorkingDir(&ShellPrompt{dir: "/tmp/x"}, "/tmp/x")
+	assert.Equal(t, "/tmp/x", sc.WorkingDir)
+	sc.setCurrentWorkingDir(&ShellPrompt{dir: "x"}, "missing")
+	assert.Equal(t, "/tmp/x", sc.WorkingDir)
+	sc.setCurrentWorkingDir(&ShellPrompt{dir: "current"}, "link")
+	assert.Equal(t, "", sc.WorkingDir)
+	sc.setCurrentWorkingDir(&ShellPrompt{}, "/data")
+	assert.Equal(t, "", sc.WorkingDir)
+}
+
+func Test_UnknownPrompt(t *testing.T) {
+	im := NewSSHIOManager(NewSSHContext(), NewIOFilter())
+	im.AddFilter(NewBlacklistFilter(func() []string {
+		return []string{"rm"}
+	}))
+
+	im.OnOutput([]byte("bash-4.2$ "))
+	assert.NoError(t, im.OnInput([]byte("\r")))
+	assert.Equal(t, CollectMode, im.inputMode)
+	im.OnOutput([]byte("[app@pod-0 ~]$ "))
+	assert.NoError(t, im.OnInput([]byte("\r")))
+	assert.Equal(t, InteractMode, im.inputMode)
+
+	im.OnOutput([]byte("\x1b]7;file://pod-0/data\a"))
+	assert.Equal(t, CollectMode, im.inputMode)
+	assert.Equal(t, "/data", im.sshContext.WorkingDir)
+
+	assert.True(t, changesPrompt("export PS1='> '"))
+	assert.True(t, changesPrompt("exec bash"))
+	assert.False(t, changesPrompt("ls -l"))
+}
@@ -183,6 +183,7 @@ func (de *DockerExecutor) Shell(ctx context.Context, execParam *DockinExecParam,
 	for _, e := range execParam.Env {
 		params.Add("env", e)
 	}
+	params.Add("user", execParam.User)
 	params.Add("workDir", execParam.WorkDir)
 	uri.RawQuery = params.Encode()
 
@@ -211,6 +212,9 @@ func (de *DockerExecutor) Shell(ctx context.Context, execParam *DockinExecParam,
 	return nil
 }
 
+// DebugShell runs the command in a debug container of the pod. No endpoint of
+// opserver opens it yet, one that does has to register the session with
+// api.RegisterSession so that the session limits apply.
 func (de *DockerExecutor) DebugShell(ctx context.Context, execParam *DockinExecParam, cmdStream *InteractStream) error {
 	uri, err := url.Parse(fmt.Sprintf("http://%s:%d/dockin/opagent/debug", de.Address, de.Port))
 	if err != nil {
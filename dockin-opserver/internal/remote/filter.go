@@ -16,49 +16,168 @@ package remote
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
-	"time"
+	"sync"
 
-	"github.com/webankfintech/dockin-opserver/internal/config"
-
-	"github.com/pkg/errors"
+	"github.com/webankfintech/dockin-opserver/internal/policy"
 
 	"github.com/webankfintech/dockin-opserver/internal/log"
 )
 
 type FilterChan struct {
-	filters []Filter
+	filters     []Filter
+	enforced    []Filter
+	mode        FuncGetFilterMode
+	record      FuncRecordViolation
+	grant       FuncGrant
+	recordGrant FuncRecordViolation
 }
 
+type FuncGetFilterMode func() string
+
+type FuncRecordViolation func(cmd, reason string)
+
+// FuncGrant is called with a command line the filters denied. It returns the
+// reason why the command line is allowed anyway, or an empty reason and the
+// error to deny it with.
+type FuncGrant func(cmdLine string, denied error) (string, error)
+
 func NewFilterChan() *FilterChan {
 	return &FilterChan{}
 }
 
 func (fc *FilterChan) AddFilter(cf Filter) {
+	if _, ok := cf.(*enforcedFilter); ok {
+		fc.enforced = append(fc.enforced, cf)
+		return
+	}
 	fc.filters = append(fc.filters, cf)
 }
 
+// SetMode sets how violations are handled, the chan enforces its filters
+// when no mode is set. Enforced filters ignore the mode.
+func (fc *FilterChan) SetMode(mode FuncGetFilterMode, record FuncRecordViolation) {
+	fc.mode = mode
+	fc.record = record
+}
+
+// SetGrant lets grant allow command lines the filters deny, record is called
+// with every granted command line and the reason.
+func (fc *FilterChan) SetGrant(grant FuncGrant, record FuncRecordViolation) {
+	fc.grant = grant
+	fc.recordGrant = record
+}
+
+// Reload makes the filters load their candidates again.
+func (fc *FilterChan) Reload() {
+	for _, filters := range [][]Filter{fc.enforced, fc.filters} {
+		for _, f := range filters {
+			if r, ok := f.(Reloader); ok {
+				r.Reload()
+			}
+		}
+	}
+}
+
 func (fc *FilterChan) Do(cmdLine string) error {
+	mode := policy.FilterModeEnforce
+	if fc.mode != nil {
+		mode = fc.mode()
+	}
+	if mode == policy.FilterModeOff && len(fc.enforced) == 0 {
+		return nil
+	}
+
 	cul, err := ParseCmdlineToCmdUnitList(cmdLine)
 	if err != nil {
 		log.Logger.Warnf("failed to parse the command:%s, err:%s", cmdLine, err)
+		err = fmt.Errorf("command is not allowd to exec, %s.", err)
+		// the enforced filters can not check a line which does not parse
+		if len(fc.enforced) > 0 || mode == policy.FilterModeEnforce {
+			return err
+		}
+		if mode == policy.FilterModeAudit {
+			fc.audit(cmdLine, err)
+		}
 		return nil
 	}
-	for _, f := range fc.filters {
+	for _, f := range fc.enforced {
 		if err := f.Do(cul); err != nil {
 			return err
 		}
 	}
+	switch mode {
+	case policy.FilterModeOff:
+		return nil
+	case policy.FilterModeAudit:
+		fc.auditUnits(cul)
+		return nil
+	}
+	for _, f := range fc.filters {
+		if err := f.Do(cul); err != nil {
+			return fc.granted(cmdLine, err)
+		}
+	}
 	return nil
 }
 
+func (fc *FilterChan) granted(cmdLine string, denied error) error {
+	if fc.grant == nil {
+		return denied
+	}
+	reason, err := fc.grant(cmdLine, denied)
+	if reason == "" {
+		return err
+	}
+	log.Logger.Warnf("command [%s] is denied, %s, but %s", cmdLine, denied, reason)
+	if fc.recordGrant != nil {
+		fc.recordGrant(cmdLine, reason)
+	}
+	return nil
+}
+
+// auditUnits checks every command on its own, so that all of them are
+// reported instead of the first denied one.
+func (fc *FilterChan) auditUnits(cul []*CmdUnit) {
+	for _, cu := range cul {
+		for _, f := range fc.filters {
+			if err := f.Do([]*CmdUnit{cu}); err != nil {
+				fc.audit(cu.cmd, err)
+				break
+			}
+		}
+	}
+}
+
+func (fc *FilterChan) audit(cmd string, err error) {
+	log.Logger.Warnf("audit mode, command [%s] would be denied, %s", cmd, err)
+	if fc.record != nil {
+		fc.record(cmd, err.Error())
+	}
+}
+
+type enforcedFilter struct {
+	Filter
+}
+
+// NewEnforcedFilter returns a filter which denies whatever the filter mode
+// is, its denials are not granted either.
+func NewEnforcedFilter(f Filter) Filter {
+	return &enforcedFilter{Filter: f}
+}
+
+func (ef *enforcedFilter) Reload() {
+	if r, ok := ef.Filter.(Reloader); ok {
+		r.Reload()
+	}
+}
+
 type FuncGetCandidates func() []string
 
 type CommandFilter struct {
+	lock              sync.RWMutex
 	candidates        []string
 	funcGetCandidates FuncGetCandidates
-	updateTicker      *time.Ticker
 }
 
 func (b *CommandFilter) updateCandidates() {
@@ -66,23 +185,32 @@ func (b *CommandFilter) updateCandidates() {
 	if b.funcGetCandidates == nil {
 		return
 	}
-	b.candidates = b.funcGetCandidates()
+	candidates := b.funcGetCandidates()
+	b.lock.Lock()
+	b.candidates = candidates
+	b.lock.Unlock()
+}
 
-	/*
-		go func() {
-			defer log.Logger.Infof("end to funcGetCandidates")
-			select {
-				case <-b.updateTicker.C:
-										b.candidates = b.funcGetCandidates()
-			}
-		}()
-	*/
+func (b *CommandFilter) Reload() {
+	b.updateCandidates()
+}
+
+func (b *CommandFilter) getCandidates() []string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.candidates
 }
 
 type Filter interface {
 	Do([]*CmdUnit) error
 }
 
+// Reloader is implemented by filters whose candidates can change while the
+// session is open.
+type Reloader interface {
+	Reload()
+}
+
 type WhitelistFilter struct {
 	CommandFilter
 }
@@ -91,7 +219,6 @@ func NewWhitelistFilter(funcs FuncGetCandidates) Filter {
 	wl := &WhitelistFilter{
 		CommandFilter: CommandFilter{
 			candidates:        []string{},
-			updateTicker:      time.NewTicker(time.Minute),
 			funcGetCandidates: funcs,
 		},
 	}
@@ -100,12 +227,13 @@ func NewWhitelistFilter(funcs FuncGetCandidates) Filter {
 }
 
 func (w *WhitelistFilter) Do(cua []*CmdUnit) error {
-	log.Logger.Infof("check whitelist=%v", w.candidates)
+	candidates := w.getCandidates()
+	log.Logger.Infof("check whitelist=%v", candidates)
 
 	check := func(cmd string) bool {
 		log.Logger.Infof("cmd=%s", cmd)
-		for _, c := range w.candidates {
-			if strings.EqualFold(strings.TrimSpace(cmd), c) {
+		for _, c := range candidates {
+			if c == policy.Wildcard || strings.EqualFold(strings.TrimSpace(cmd), c) {
 				return true
 			}
 		}
@@ -131,7 +259,6 @@ func NewBlacklistFilter(funcs FuncGetCandidates) Filter {
 	bl := &BlacklistFilter{
 		CommandFilter: CommandFilter{
 			candidates:        []string{},
-			updateTicker:      time.NewTicker(time.Minute),
 			funcGetCandidates: funcs,
 		},
 	}
@@ -140,11 +267,12 @@ func NewBlacklistFilter(funcs FuncGetCandidates) Filter {
 }
 
 func (w *BlacklistFilter) Do(cua []*CmdUnit) error {
-	log.Logger.Infof("check blacklist=%v", w.candidates)
+	candidates := w.getCandidates()
+	log.Logger.Infof("check blacklist=%v", candidates)
 
 	for _, cu := range cua {
 		log.Logger.Infof("cmd=%s", cu.cmd)
-		for _, c := range w.candidates {
+		for _, c := range candidates {
 			if strings.EqualFold(strings.TrimSpace(cu.cmd), c) {
 				return fmt.Errorf("command [%s] is not allowd to exec, please contact Dockin_helper for help.", cu.cmd)
 			}
@@ -156,66 +284,55 @@ func (w *BlacklistFilter) Do(cua []*CmdUnit) error {
 
 type FuncGetCurrentWorkDir func() string
 
-type VIMFilter struct {
-	Executor   Executor
-	Limit      int64
-	CurrentDir FuncGetCurrentWorkDir
-	Container  string
+type FuncGetRules func() ([]*policy.CommandRule, error)
+
+type RuleFilter struct {
+	lock         sync.RWMutex
+	rules        []*policy.CommandRule
+	loadErr      error
+	funcGetRules FuncGetRules
+	CurrentDir   FuncGetCurrentWorkDir
 }
 
-func NewVimFilter(container string, executor Executor, funs FuncGetCurrentWorkDir) Filter {
-	vf := &VIMFilter{
-		Executor:   executor,
-		Limit:      config.OpsConfig.Limits.ViFileMaxSize * 1024 * 1024,
-		CurrentDir: funs,
-		Container:  container,
-	}
-	return vf
+func NewRuleFilter(funcs FuncGetRules, dir FuncGetCurrentWorkDir) Filter {
+	rf := &RuleFilter{funcGetRules: funcs, CurrentDir: dir}
+	rf.Reload()
+	return rf
 }
 
-func (vf *VIMFilter) Do(cua []*CmdUnit) error {
-	log.Logger.Infof("check vi")
-	var (
-		targetFile string
-	)
-	isViCmd := false
+func (rf *RuleFilter) Reload() {
+	rules, err := rf.funcGetRules()
+	rf.lock.Lock()
+	rf.rules, rf.loadErr = rules, err
+	rf.lock.Unlock()
+}
+
+func (rf *RuleFilter) Do(cua []*CmdUnit) error {
+	rf.lock.RLock()
+	rules, loadErr := rf.rules, rf.loadErr
+	rf.lock.RUnlock()
+	if loadErr != nil {
+		return fmt.Errorf("command rules are unavailable, please try again later.")
+	}
+	cwd := rf.CurrentDir()
+	// the directory of the commands is unknown once the line changes it,
+	// a loop may even run a command after a cd which follows it
 	for _, cu := range cua {
-		log.Logger.Infof("cmd=%s", cu.cmd)
-		if strings.EqualFold(cu.cmd, "vi") || strings.EqualFold(cu.cmd, "vim") {
-			isViCmd = true
-			targetFile = cu.target
+		if changesDir(cu.cmd) {
+			cwd = ""
 		}
 	}
-	if !isViCmd {
-		return nil
-	}
-
-	ios, _, stdout, stderr := NewIOStreams()
-	err := vf.Executor.Exec(&DockinExecParam{
-		Cmd:  []string{"du", "-shb", targetFile},
-		User: "root",
-		//WorkDir: vf.CurrentDir(),
-		WorkDir:       vf.CurrentDir(),
-		ContainerName: vf.Container,
-	}, ios)
-	if err != nil {
-		log.Logger.Warnf("failed to vi file as stderr:%s, err:%v", stderr.String(), err)
-		stderrstr := stderr.String()
-		if strings.HasSuffix(stderrstr, "\n") {
-			stderrstr = strings.TrimSuffix(stderrstr, "\n") + ", if "
+	for _, cu := range cua {
+		for _, r := range rules {
+			if reason := r.Check(cu.cmd, cu.args, cwd); reason != "" {
+				log.Logger.Infof("command %s %v is denied by rule %s, cwd=%s", cu.cmd, cu.args, r.Name, cwd)
+				return fmt.Errorf("command [%s] is not allowd to exec, %s.", cu.cmd, reason)
+			}
 		}
-		return errors.New(stderrstr)
-	}
-
-	size, err := strconv.ParseInt(strings.Split(stdout.String(), "\t")[0], 10, 64)
-	if err != nil {
-		log.Logger.Warnf("failed to parse the stdout to file size, stdout:%s, err:%s", stdout.String(), err)
-		return errors.Errorf("ParseInt vi file=%s size failed", targetFile)
 	}
-	if size > vf.Limit {
-		log.Logger.Infof("the file edited is larger than %d, and it's size to %d", vf.Limit, size)
-		return fmt.Errorf("the file edited is larger than %d, and it's size to %d", vf.Limit, size)
-	}
-
 	return nil
 }
+
+func changesDir(cmd string) bool {
+	return cmd == "cd" || cmd == "pushd" || cmd == "popd"
+}
@@ -15,8 +15,13 @@
 package remote
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,3 +64,243 @@ func Test_Blacklist(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func Test_RuleFilter(t *testing.T) {
+	rf := NewRuleFilter(func() ([]*policy.CommandRule, error) {
+		return []*policy.CommandRule{
+			{Name: "rm-recursive", Command: "rm", Flags: []string{"-r"}, OutsidePaths: []string{"/data/app/tmp"}},
+		}, nil
+	}, func() string { return "/data/app" })
+
+	t.Run("allowed", func(t *testing.T) {
+		cu, err := ParseCmdlineToCmdUnitList("rm -rf tmp/cache; rm conf.yaml")
+		assert.NoError(t, err)
+		assert.NoError(t, rf.Do(cu))
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		cu, err := ParseCmdlineToCmdUnitList("ls && rm -rf 'logs'")
+		assert.NoError(t, err)
+		assert.Error(t, rf.Do(cu))
+	})
+
+	t.Run("cd in the line", func(t *testing.T) {
+		for _, line := range []string{"cd / && rm -r tmp", "for d in a b; do rm -r tmp; cd /; done", "rm -r tmp/$(cd /)"} {
+			cu, err := ParseCmdlineToCmdUnitList(line)
+			assert.NoError(t, err)
+			assert.Error(t, rf.Do(cu), line)
+		}
+		cu, err := ParseCmdlineToCmdUnitList("cd / && rm -r /data/app/tmp/x")
+		assert.NoError(t, err)
+		assert.NoError(t, rf.Do(cu))
+	})
+
+	t.Run("rules unavailable", func(t *testing.T) {
+		rf := NewRuleFilter(func() ([]*policy.CommandRule, error) {
+			return nil, assert.AnError
+		}, func() string { return "/" })
+		cu, err := ParseCmdlineToCmdUnitList("ls")
+		assert.NoError(t, err)
+		assert.Error(t, rf.Do(cu))
+	})
+}
+
+func Test_FilterChan(t *testing.T) {
+	fc := NewFilterChan()
+	fc.AddFilter(NewWhitelistFilter(func() []string {
+		return []string{"ls", "bash", "xargs"}
+	}))
+
+	assert.NoError(t, fc.Do("ls -al | xargs ls"))
+	assert.Error(t, fc.Do("bash -c 'ls; rm -rf /'"))
+	assert.Error(t, fc.Do("ls $(rm -rf /)"))
+	assert.Error(t, fc.Do("ls 'unterminated"))
+}
+
+func Test_FilterChanMode(t *testing.T) {
+	var (
+		mode       = policy.FilterModeAudit
+		violations []string
+	)
+	fc := NewFilterChan()
+	fc.AddFilter(NewWhitelistFilter(func() []string {
+		return []string{"ls"}
+	}))
+	fc.SetMode(func() string {
+		return mode
+	}, func(cmd, reason string) {
+		violations = append(violations, cmd)
+	})
+
+	assert.NoError(t, fc.Do("ls; rm -rf /tmp/x | kill 1"))
+	assert.NoError(t, fc.Do("ls 'unterminated"))
+	assert.Equal(t, []string{"rm", "kill", "ls 'unterminated"}, violations)
+
+	mode = policy.FilterModeOff
+	assert.NoError(t, fc.Do("rm -rf /"))
+	assert.Len(t, violations, 3)
+
+	mode = policy.FilterModeEnforce
+	assert.Error(t, fc.Do("rm -rf /"))
+}
+
+func Test_FilterChanEnforced(t *testing.T) {
+	var (
+		mode       = policy.FilterModeOff
+		violations []string
+	)
+	fc := NewFilterChan()
+	fc.AddFilter(NewWhitelistFilter(func() []string {
+		return []string{"ls"}
+	}))
+	fc.AddFilter(NewEnforcedFilter(NewWhitelistFilter(func() []string {
+		return []string{"ls", "rm"}
+	})))
+	fc.SetMode(func() string {
+		return mode
+	}, func(cmd, reason string) {
+		violations = append(violations, cmd)
+	})
+	fc.SetGrant(func(cmdLine string, denied error) (string, error) {
+		return "granted", nil
+	}, nil)
+
+	for _, mode = range []string{policy.FilterModeOff, policy.FilterModeAudit, policy.FilterModeEnforce} {
+		assert.Error(t, fc.Do("ls; kill 1"), mode)
+		assert.Error(t, fc.Do("ls 'unterminated"), mode)
+		assert.NoError(t, fc.Do("rm -rf /tmp/x"), mode)
+	}
+	assert.Equal(t, []string{"rm"}, violations)
+}
+
+func Test_FilterChanReload(t *testing.T) {
+	allowed := []string{"ls", "rm"}
+	fc := NewFilterChan()
+	fc.AddFilter(NewWhitelistFilter(func() []string {
+		return allowed
+	}))
+	assert.NoError(t, fc.Do("rm x"))
+
+	allowed = []string{"ls"}
+	assert.NoError(t, fc.Do("rm x"))
+	fc.Reload()
+	assert.Error(t, fc.Do("rm x"))
+
+	allowed = []string{policy.Wildcard}
+	fc.Reload()
+	assert.NoError(t, fc.Do("rm x"))
+}
+
+func Test_FilterChanGrant(t *testing.T) {
+	var granted []string
+	fc := NewFilterChan()
+	fc.AddFilter(NewWhitelistFilter(func() []string {
+		return []string{"ls"}
+	}))
+	fc.SetGrant(func(cmdLine string, denied error) (string, error) {
+		if strings.HasPrefix(cmdLine, "rm ") {
+			return "granted", nil
+		}
+		return "", fmt.Errorf("%s, ask for approval", denied)
+	}, func(cmd, reason string) {
+		granted = append(granted, cmd)
+	})
+
+	assert.NoError(t, fc.Do("ls"))
+	assert.NoError(t, fc.Do("rm -rf /tmp/x"))
+	err := fc.Do("kill 1")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ask for approval")
+	}
+	assert.Equal(t, []string{"rm -rf /tmp/x"}, granted)
+}
+
+type fakeExecutor struct {
+	stdout string
+	cmd    []string
+}
+
+func (fe *fakeExecutor) Exec(execParam *DockinExecParam, streams *IOStreams) error {
+	fe.cmd = execParam.Cmd
+	fmt.Fprint(streams.Out, fe.stdout)
+	return nil
+}
+
+func (fe *fakeExecutor) ExecInteractive(execParam *DockinExecParam, cmdStream *InteractStream) error {
+	return nil
+}
+
+func (fe *fakeExecutor) Resize(width, height int) error {
+	return nil
+}
+
+func (fe *fakeExecutor) Shell(ctx context.Context, execParam *DockinExecParam, interStream *InteractStream) error {
+	return nil
+}
+
+func (fe *fakeExecutor) DebugShell(ctx context.Context, execParam *DockinExecParam, interStream *InteractStream) error {
+	return nil
+}
+
+func Test_LargeFileFilter(t *testing.T) {
+	fe := &fakeExecutor{}
+	lf := &LargeFileFilter{
+		Executor:    fe,
+		Commands:    []string{"vi", "less", "sort", "awk", "cat"},
+		MemoryRatio: 0.5,
+		Fallback:    10,
+		CurrentDir:  func() string { return "/data" },
+	}
+	check := func(cmdline, stdout string) error {
+		fe.stdout, fe.cmd = stdout, nil
+		cu, err := ParseCmdlineToCmdUnitList(cmdline)
+		assert.NoError(t, err)
+		return lf.Do(cu)
+	}
+
+	assert.NoError(t, check("ls -l | grep x", ""))
+	assert.Nil(t, fe.cmd)
+
+	stat := "limit 1000\nusage 700\ninactive 300\ntotal %d\n"
+	assert.NoError(t, check("vi +10 app.log", fmt.Sprintf(stat, 300)))
+	assert.Contains(t, fe.cmd[2], "-- app.log 2>")
+	assert.Error(t, check("less -p ERROR logs/*.log", fmt.Sprintf(stat, 301)))
+	assert.Contains(t, fe.cmd[2], "-- logs/*.log 2>")
+	assert.NoError(t, check("awk '{print $1}' a.txt | sort -k 2 -o out.txt", fmt.Sprintf(stat, 10)))
+	assert.Contains(t, fe.cmd[2], "-- a.txt 2>")
+
+	assert.Error(t, check("vi app.log", "limit 9223372036854771712\nusage 700\ntotal 11\n"))
+	assert.NoError(t, check("vi app.log", "limit\nusage\ninactive\ntotal 10\n"))
+	assert.Error(t, check("vi app.log", "limit 1000\n"))
+
+	assert.NoError(t, check("sort -k 2 < data.txt", fmt.Sprintf(stat, 10)))
+	assert.Contains(t, fe.cmd[2], "-- data.txt 2>")
+	assert.Error(t, check("nice sort 0<big.log", fmt.Sprintf(stat, 301)))
+	assert.Contains(t, fe.cmd[2], "-- big.log 2>")
+	for _, cmdline := range []string{"cat $F", `less "$(ls -S | head -1)"`, "vi app.{log,bak}", "sort < $F", "ls | sh -c 'cat $0' x"} {
+		fe.cmd = nil
+		assert.Error(t, check(cmdline, fmt.Sprintf(stat, 0)), cmdline)
+		assert.Nil(t, fe.cmd, cmdline)
+	}
+}
+
+func Test_largeFileOperands(t *testing.T) {
+	for cmdline, expect := range map[string][]string{
+		"vi +10 a.log":                 {"a.log"},
+		"sort -k 2 -t , -o out a b":    {"a", "b"},
+		"awk -F : '{print $1}' /etc/x": {"/etc/x"},
+		"awk -f prog.awk data":         {"data"},
+		"less -- -weird":               {"-weird"},
+		"cat - a 'my file'":            {"a", "my file"},
+		"vi":                           nil,
+		"sort -u < a 2> err":           {"a"},
+		"cat '$a' \"{b}\"":             {"$a", "{b}"},
+	} {
+		cu, err := ParseCmdlineToCmdUnitList(cmdline)
+		assert.NoError(t, err)
+		files, err := largeFileOperands(cu[0])
+		assert.NoError(t, err, cmdline)
+		assert.Equal(t, expect, files, cmdline)
+	}
+	assert.Equal(t, `~/logs/app\ \$x*.log`, globWord("~/logs/app $x*.log"))
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/webankfintech/dockin-opserver/internal/log"
 
 	"github.com/gorilla/websocket"
+	utilexec "k8s.io/client-go/util/exec"
 )
 
 var (
@@ -39,3 +40,12 @@ func HandleWSError(ws *websocket.Conn, err error) bool {
 	}
 	return false
 }
+
+// ExitCode returns the exit status of the remote command if err reports it,
+// other errors mean the command did not run to its end.
+func ExitCode(err error) (int, bool) {
+	if exitErr, ok := err.(utilexec.ExitError); ok && exitErr.Exited() {
+		return exitErr.ExitStatus(), true
+	}
+	return 0, false
+}
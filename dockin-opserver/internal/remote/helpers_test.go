@@ -0,0 +1,32 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package remote
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func TestExitCode(t *testing.T) {
+	code, exited := ExitCode(utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 2"), Code: 2})
+	assert.True(t, exited)
+	assert.Equal(t, 2, code)
+
+	_, exited = ExitCode(fmt.Errorf("stream with opagent failed"))
+	assert.False(t, exited)
+}
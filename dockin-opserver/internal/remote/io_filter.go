@@ -16,9 +16,9 @@ package remote
 
 import (
 	"bytes"
-	"strings"
 
 	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
 	"github.com/webankfintech/dockin-opserver/internal/remote/prompt"
 )
 
@@ -39,12 +39,15 @@ type IOFilter struct {
 	InBytes           []byte
 	isEsc             bool
 	isEscape          bool
+	redactor          *redact.Redactor
 }
 
 func NewIOFilter() *IOFilter {
+	// the output before the first input is the login banner and prompt
 	return &IOFilter{
-		parser: prompt.NewStandardInputParser(),
-		buf:    prompt.NewBuffer(),
+		parser:      prompt.NewStandardInputParser(),
+		buf:         prompt.NewBuffer(),
+		LastCtrlKey: prompt.Enter,
 	}
 }
 
@@ -54,20 +57,15 @@ func (io *IOFilter) handleInput(data []byte) {
 }
 
 func (io *IOFilter) handleOutput(data []byte) {
-	log.Logger.Infof("receive output:%v, byte:%v", string(data), data)
+	log.Logger.Infof("receive output:%v", io.redactor.String(string(data)))
 	switch io.LastCtrlKey {
 	case prompt.Enter, prompt.ControlC, prompt.ControlJ:
-		log.Logger.Infof("last click is enter or contro-c, ignore the response:%s", string(data))
+		log.Logger.Infof("last click is enter or contro-c, ignore the response:%s", io.redactor.String(string(data)))
 		return
 	}
-	if IsShellPrompt(data) {
-
-		return
-	}
-
-	log.Logger.Infof("before filter output is :%v, index:%v", io.buf.Text(), io.buf.Document().CursorPositionCol())
+	log.Logger.Infof("before filter output is :%v, index:%v", io.redactor.String(io.buf.Text()), io.buf.Document().CursorPositionCol())
 	prompt.WalkRemoteOutput(data, io.buf)
-	log.Logger.Infof("after filter output is :%v, index:%v", io.buf.Text(), io.buf.Document().CursorPositionCol())
+	log.Logger.Infof("after filter output is :%v, index:%v", io.redactor.String(io.buf.Text()), io.buf.Document().CursorPositionCol())
 }
 
 func (io *IOFilter) handleASCIICodeBinding(b []byte) bool {
@@ -98,13 +96,5 @@ func (io *IOFilter) handleKeyBinding(key prompt.Key) {
 }
 
 func IsShellPrompt(buffer []byte) bool {
-	//if !strings.HasPrefix(string(buffer), "[") {
-	//	return false
-	//}
-	if strings.Contains(string(buffer), AppSuffix) {
-		return true
-	} else if strings.Contains(string(buffer), RootSuffix) {
-		return true
-	}
-	return false
+	return defaultPrompts.Match(buffer) != nil
 }
@@ -16,16 +16,57 @@ package remote
 
 import (
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/webankfintech/dockin-opserver/internal/log"
 	"github.com/webankfintech/dockin-opserver/internal/remote/prompt"
 )
 
+var (
+	hookSetupTimeout = 5 * time.Second
+	hookHoldTimeout  = 3 * time.Second
+)
+
+type hookState int
+
+const (
+	// hookOff rebuilds commands from the terminal echo
+	hookOff hookState = iota
+	hookSetup
+	hookOn
+)
+
+// FuncReportCommand is called with every command line the session checks,
+// err is the reason it was denied, audited are the reasons it would have been
+// denied in the audit filter mode, granted are the reasons a grant allowed it
+// although it was denied. cwd is empty when it is unknown.
+type FuncReportCommand func(cmd, cwd string, err error, audited, granted []string)
+
+// FuncReportExit is called with the exit status of the last command line,
+// when the shell hook reports it.
+type FuncReportExit func(status int)
+
 type SSHIOManager struct {
 	filterChan *FilterChan
 	sshContext *SSHContext
 	inputMode  InputMode
 	ioFilter   *IOFilter
+
+	lock       sync.Mutex
+	write      func([]byte)
+	report     FuncReportCommand
+	reportExit FuncReportExit
+	violations []string
+	grants     []string
+	hook       hookState
+	parser     hookParser
+	atPrompt   bool
+	submitted  bool
+	running    bool
+	holding    bool
+	held       [][]byte
+	holdSeq    int
 }
 
 func NewSSHIOManager(ctx *SSHContext, ioFilter *IOFilter) *SSHIOManager {
@@ -43,6 +84,150 @@ func (im *SSHIOManager) AddFilter(cf Filter) {
 	im.filterChan.AddFilter(cf)
 }
 
+func (im *SSHIOManager) SetFilterMode(mode FuncGetFilterMode, record FuncRecordViolation) {
+	im.filterChan.SetMode(mode, func(cmd, reason string) {
+		im.violations = append(im.violations, reason)
+		if record != nil {
+			record(cmd, reason)
+		}
+	})
+}
+
+func (im *SSHIOManager) SetGrant(grant FuncGrant) {
+	im.filterChan.SetGrant(grant, func(cmd, reason string) {
+		im.grants = append(im.grants, reason)
+	})
+}
+
+func (im *SSHIOManager) ReloadFilters() {
+	im.filterChan.Reload()
+}
+
+func (im *SSHIOManager) SetCommandReporter(report FuncReportCommand, exit FuncReportExit) {
+	im.report, im.reportExit = report, exit
+}
+
+// check runs the filters on the command line and reports the verdict.
+func (im *SSHIOManager) check(cmd, cwd string) error {
+	im.violations, im.grants = nil, nil
+	var err error
+	if !im.IsExitCmd(strings.TrimSpace(cmd)) {
+		if im.hook == hookOn {
+			err = checkHookedCommand(cmd)
+		}
+		if err == nil {
+			err = im.filterChan.Do(cmd)
+		}
+	}
+	if err != nil {
+		log.Logger.Infof(err.Error())
+	}
+	if im.report != nil {
+		im.report(cmd, cwd, err, im.violations, im.grants)
+	}
+	return err
+}
+
+// StartHook installs the shell hook, the input is held and the output is
+// dropped until the hook is ready. Commands are rebuilt from the terminal
+// echo if the shell does not report ready in time.
+func (im *SSHIOManager) StartHook() {
+	im.lock.Lock()
+	defer im.lock.Unlock()
+	im.hook = hookSetup
+	im.holding = true
+	im.write([]byte(shellHookScript))
+	time.AfterFunc(hookSetupTimeout, im.hookTimeout)
+}
+
+func (im *SSHIOManager) hookTimeout() {
+	im.lock.Lock()
+	if im.hook != hookSetup {
+		im.lock.Unlock()
+		return
+	}
+	log.Logger.Warnf("shell hook is not ready in %s, rebuild commands from the terminal echo", hookSetupTimeout)
+	im.hook = hookOff
+	im.holding = false
+	held := im.held
+	im.held = nil
+	im.lock.Unlock()
+
+	for _, data := range held {
+		im.echoInput(data)
+	}
+}
+
+// HandleInput checks and writes the input of the client to the shell.
+func (im *SSHIOManager) HandleInput(data []byte) {
+	im.lock.Lock()
+	if im.hook == hookOff {
+		im.lock.Unlock()
+		im.echoInput(data)
+		return
+	}
+	defer im.lock.Unlock()
+	im.hookedInput(data)
+}
+
+// hookedInput holds the input from the end of a command line at the prompt
+// until the hook reported the line, so that no input of the user reaches
+// the shell while the hook waits for the verdict.
+func (im *SSHIOManager) hookedInput(data []byte) {
+	if im.holding {
+		im.held = append(im.held, data)
+		return
+	}
+	if im.hook == hookOn && im.atPrompt {
+		if idx := strings.IndexAny(string(data), "\r\n"); idx != -1 {
+			im.write(data[:idx+1])
+			im.submitted = true
+			im.hold()
+			if idx+1 < len(data) {
+				im.held = append(im.held, data[idx+1:])
+			}
+			return
+		}
+	}
+	im.write(data)
+}
+
+func (im *SSHIOManager) hold() {
+	im.holding = true
+	im.holdSeq++
+	seq := im.holdSeq
+	time.AfterFunc(hookHoldTimeout, func() {
+		im.lock.Lock()
+		defer im.lock.Unlock()
+		if im.holding && im.holdSeq == seq {
+			log.Logger.Infof("no command reported in %s, release the input", hookHoldTimeout)
+			im.submitted = false
+			im.release()
+		}
+	})
+}
+
+func (im *SSHIOManager) release() {
+	im.holding = false
+	held := im.held
+	im.held = nil
+	for _, data := range held {
+		im.hookedInput(data)
+	}
+}
+
+func (im *SSHIOManager) echoInput(data []byte) {
+	if err := im.OnInput(data); err != nil {
+		im.write([]byte{CharInterrupt})
+		time.Sleep(time.Millisecond * 100)
+		im.write([]byte(err.Error()))
+		time.Sleep(time.Millisecond * 100)
+		im.write([]byte{CharInterrupt})
+		return
+	}
+	im.write(data)
+}
+
 func (im *SSHIOManager) OnInput(data []byte) error {
 	im.ioFilter.handleInput(data)
 	if im.inputMode == CollectMode {
@@ -50,7 +235,11 @@ func (im *SSHIOManager) OnInput(data []byte) error {
 		case prompt.Enter, prompt.ControlJ:
 			err := im.handleCommand()
 			log.Logger.Infof("after handle the command:%v", err)
-			im.inputMode = InteractMode
+			if im.sshContext.promptKnown {
+				im.inputMode = InteractMode
+			} else {
+				log.Logger.Warnf("the shell prompt is unknown, check every line as a command")
+			}
 			return err
 		case prompt.ControlC:
 			im.ioFilter.buf = prompt.NewBuffer()
@@ -64,38 +253,147 @@ func (im *SSHIOManager) OnInput(data []byte) error {
 func (im *SSHIOManager) handleCommand() error {
 	cmd := im.ioFilter.buf.Text()
 	im.ioFilter.buf = prompt.NewBuffer()
-	log.Logger.Infof("handle command:%s", cmd)
+	log.Logger.Infof("handle command:%s", im.ioFilter.redactor.String(cmd))
 
 	im.sshContext.setCurrentCommand(cmd)
+	if changesPrompt(cmd) {
+		im.sshContext.forgetPrompt()
+	}
 
 	if strings.TrimSpace(cmd) == "" {
 		return nil
 	}
-	if im.IsExitCmd(cmd) {
-		return nil
+	return im.check(cmd, im.sshContext.getCurrentWorkingDir())
+}
+
+func (im *SSHIOManager) IsExitCmd(cmd string) bool {
+	return cmd == "exit" || cmd == "quit"
+}
+
+// HandleOutput returns the output of the shell to send to the client.
+func (im *SSHIOManager) HandleOutput(buffer []byte) []byte {
+	im.lock.Lock()
+	if im.hook == hookOff {
+		im.lock.Unlock()
+		im.OnOutput(buffer)
+		return buffer
 	}
-	if err := im.filterChan.Do(cmd); err != nil {
-		log.Logger.Infof(err.Error())
-		return err
+	defer im.lock.Unlock()
+
+	var out []byte
+	for _, item := range im.parser.parse(buffer) {
+		if item.marker == nil {
+			if im.hook == hookOn {
+				out = append(out, item.text...)
+			}
+			continue
+		}
+		out = append(out, im.handleMarker(item.marker)...)
 	}
+	return out
+}
 
+// handleMarker handles a marker of the shell hook. The output of the
+// commands may contain forged markers, so a command marker is only accepted
+// while the input is held after a line was submitted at the prompt, and a
+// done or continue marker only after the command marker of the line, or
+// for a line which ran no command. Other markers are dropped.
+func (im *SSHIOManager) handleMarker(m *hookMarker) []byte {
+	switch m.kind {
+	case hookReady:
+		if im.hook == hookSetup {
+			log.Logger.Infof("shell hook is ready")
+			im.hook = hookOn
+			im.running = true
+			im.releaseToCommand()
+			return nil
+		}
+	case hookDone:
+		if !im.running && !im.submitted {
+			break
+		}
+		if im.running {
+			status, cwd, err := parseHookDone(m)
+			if err != nil {
+				log.Logger.Warnf("failed to parse the hook marker, err=%s", err.Error())
+				return nil
+			}
+			log.Logger.Infof("command finished, status=%d, cwd=%s", status, cwd)
+			im.sshContext.setCommandFinished(status, cwd)
+			if im.reportExit != nil {
+				im.reportExit(status)
+			}
+		}
+		im.toPrompt()
+		return nil
+	case hookContinue:
+		if !im.submitted {
+			break
+		}
+		im.toPrompt()
+		return nil
+	case hookCommand:
+		if im.hook != hookOn || !im.submitted || !im.holding {
+			break
+		}
+		req, err := parseHookRequest(m)
+		if err != nil {
+			log.Logger.Warnf("failed to parse the hook marker, err=%s", err.Error())
+			return nil
+		}
+		err = im.checkHookedCommand(req)
+		im.write(req.verdict(err))
+		im.atPrompt, im.submitted, im.running = false, false, true
+		im.releaseToCommand()
+		if err != nil {
+			return []byte(err.Error() + "\r\n")
+		}
+		return nil
+	}
+	log.Logger.Warnf("drop the unexpected hook marker %s", m.kind)
 	return nil
 }
 
-func (im *SSHIOManager) IsExitCmd(cmd string) bool {
-	return cmd == "exit" || cmd == "quit"
+// toPrompt releases the input once the shell is back at the prompt.
+func (im *SSHIOManager) toPrompt() {
+	im.atPrompt, im.submitted, im.running = true, false, false
+	if im.holding {
+		im.release()
+	}
+}
+
+// releaseToCommand releases the input to the command which starts running.
+// Held lines, such as the rest of a paste, stay held until the prompt, or
+// the shell would read them as command lines while the input is not held.
+func (im *SSHIOManager) releaseToCommand() {
+	for _, data := range im.held {
+		if strings.ContainsAny(string(data), "\r\n") {
+			im.hold()
+			return
+		}
+	}
+	im.release()
+}
+
+func (im *SSHIOManager) checkHookedCommand(req *hookRequest) error {
+	log.Logger.Infof("handle command:%s, cwd:%s", im.ioFilter.redactor.String(req.cmd), req.cwd)
+	im.sshContext.setCurrentCommand(req.cmd)
+	im.sshContext.setWorkingDir(req.cwd)
+	return im.check(req.cmd, req.cwd)
 }
 
 func (im *SSHIOManager) OnOutput(buffer []byte) {
-	if im.inputMode == CollectMode {
+	sp := im.sshContext.matchPrompt(buffer)
+	if im.inputMode == CollectMode && sp == nil {
 		im.ioFilter.handleOutput(buffer)
 	}
 
-	im.updateCommandExecFinished(buffer)
-
-	switch im.ioFilter.LastCtrlKey {
-	case prompt.Enter, prompt.ControlC, prompt.ControlJ:
-		im.sshContext.setEnvironment(string(buffer))
+	im.sshContext.CommandFinished = sp != nil
+	if sp != nil {
+		switch im.ioFilter.LastCtrlKey {
+		case prompt.Enter, prompt.ControlC, prompt.ControlJ:
+			im.sshContext.setEnvironment(sp)
+		}
 	}
 
 	if im.sshContext.CommandFinished {
@@ -104,7 +402,3 @@ func (im *SSHIOManager) OnOutput(buffer []byte) {
 		//im.ioFilter.buf = prompt.NewBuffer()
 	}
 }
-
-func (im *SSHIOManager) updateCommandExecFinished(buffer []byte) {
-	im.sshContext.CommandFinished = IsShellPrompt(buffer)
-}
@@ -0,0 +1,275 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package remote
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	"github.com/pkg/errors"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	defaultMemoryRatio       = 0.5
+	unlimitedMemory    int64 = 1 << 60
+)
+
+var (
+	defaultLargeFileCommands = []string{"vi", "vim", "view", "less", "more", "cat", "tac", "sort", "awk", "gawk", "jq"}
+
+	// largeFileValueFlags lists the flags whose value is not a file to load.
+	largeFileValueFlags = map[string][]string{
+		"sort": {"-k", "-t", "-o", "-S", "-T", "--key", "--field-separator", "--output", "--buffer-size", "--temporary-directory"},
+		"awk":  {"-F", "-v", "-f"},
+		"gawk": {"-F", "-v", "-f"},
+		"jq":   {"--arg", "--argjson", "-f", "--from-file", "--indent"},
+		"less": {"-b", "-h", "-j", "-p", "-P", "-t", "-T", "-x", "-y", "-z", "-o", "-O"},
+	}
+
+	// largeFilePrograms lists the commands whose first operand is a program,
+	// unless the program is read from a file with the flag.
+	largeFilePrograms = map[string][]string{
+		"awk":  {"-f"},
+		"gawk": {"-f"},
+		"jq":   {"-f", "--from-file"},
+	}
+)
+
+// largeFileScript prints the memory limit and usage of the container, read
+// from cgroup v2 or v1, and the total size of the files.
+const largeFileScript = `d=/sys/fs/cgroup
+if [ -f $d/memory.max ]; then l=$d/memory.max u=$d/memory.current s=$d/memory.stat k=inactive_file
+else l=$d/memory/memory.limit_in_bytes u=$d/memory/memory.usage_in_bytes s=$d/memory/memory.stat k=total_inactive_file; fi
+echo limit $(cat $l 2>/dev/null)
+echo usage $(cat $u 2>/dev/null)
+echo inactive $(grep "^$k " $s 2>/dev/null | cut -d' ' -f2)
+echo total $(du -cbL -- %s 2>/dev/null | tail -n 1 | cut -f1)
+`
+
+// LargeFileFilter denies commands which load whole files into memory, when
+// the files are larger than the pod could hold. The limit is a share of the
+// memory the pod has left, or vi-file-max-size when the pod has no limit.
+type LargeFileFilter struct {
+	Executor    Executor
+	Commands    []string
+	MemoryRatio float64
+	Fallback    int64
+	CurrentDir  FuncGetCurrentWorkDir
+	Container   string
+}
+
+func NewLargeFileFilter(container string, executor Executor, funs FuncGetCurrentWorkDir) Filter {
+	conf := config.OpsConfig.Limits
+	lf := &LargeFileFilter{
+		Executor:    executor,
+		Commands:    conf.LargeFile.Commands,
+		MemoryRatio: conf.LargeFile.MemoryRatio,
+		Fallback:    conf.ViFileMaxSize * 1024 * 1024,
+		CurrentDir:  funs,
+		Container:   container,
+	}
+	if len(lf.Commands) == 0 {
+		lf.Commands = defaultLargeFileCommands
+	}
+	if lf.MemoryRatio <= 0 {
+		lf.MemoryRatio = defaultMemoryRatio
+	}
+	return lf
+}
+
+func (lf *LargeFileFilter) Do(cua []*CmdUnit) error {
+	var (
+		cmds  []string
+		files []string
+	)
+	for _, cu := range cua {
+		if !contains(lf.Commands, cu.cmd) {
+			continue
+		}
+		operands, err := largeFileOperands(cu)
+		if err != nil {
+			return err
+		}
+		if len(operands) > 0 {
+			cmds = append(cmds, cu.cmd)
+			files = append(files, operands...)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	log.Logger.Infof("check the size of files %v loaded by %v", files, cmds)
+	dir := lf.CurrentDir()
+	if dir == "" {
+		for _, f := range files {
+			if !strings.HasPrefix(f, "/") && !strings.HasPrefix(f, "~") {
+				return fmt.Errorf("the current dir of the session is unknown, please use the absolute path of %s.", f)
+			}
+		}
+	}
+
+	words := make([]string, 0, len(files))
+	for _, f := range files {
+		words = append(words, globWord(f))
+	}
+	ios, _, stdout, stderr := NewIOStreams()
+	err := lf.Executor.Exec(&DockinExecParam{
+		Cmd:           []string{"/bin/sh", "-c", fmt.Sprintf(largeFileScript, strings.Join(words, " "))},
+		User:          "root",
+		WorkDir:       dir,
+		ContainerName: lf.Container,
+	}, ios)
+	if err != nil {
+		log.Logger.Warnf("failed to check the size of files %v, stderr:%s, err:%v", files, stderr.String(), err)
+		return errors.Errorf("failed to check the size of %s, please try again later.", strings.Join(files, " "))
+	}
+
+	stat := parseLargeFileStat(stdout.String())
+	size, ok := stat["total"]
+	if !ok {
+		log.Logger.Warnf("failed to parse the size of files %v, stdout:%s", files, stdout.String())
+		return errors.Errorf("failed to check the size of %s, please try again later.", strings.Join(files, " "))
+	}
+	limit := lf.limit(stat)
+	if size > limit {
+		log.Logger.Infof("the files %v loaded by %v take %d bytes, larger than %d", files, cmds, size, limit)
+		return fmt.Errorf("the files loaded by %s take %d bytes, larger than the %d bytes allowed by the memory of the pod",
+			strings.Join(cmds, ", "), size, limit)
+	}
+	return nil
+}
+
+// limit returns the share of the memory left in the pod, the working set
+// excludes the inactive page cache like the kubelet does.
+func (lf *LargeFileFilter) limit(stat map[string]int64) int64 {
+	memLimit, ok := stat["limit"]
+	usage, hasUsage := stat["usage"]
+	if !ok || !hasUsage || memLimit <= 0 || memLimit >= unlimitedMemory {
+		return lf.Fallback
+	}
+	if inactive := stat["inactive"]; inactive < usage {
+		usage -= inactive
+	}
+	if usage >= memLimit {
+		return 0
+	}
+	return int64(float64(memLimit-usage) * lf.MemoryRatio)
+}
+
+func parseLargeFileStat(out string) map[string]int64 {
+	stat := map[string]int64{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			stat[fields[0]] = v
+		}
+	}
+	return stat
+}
+
+// largeFileOperands returns the files the command loads, including the file
+// redirected to its stdin. Files only the shell could name are reported as an
+// error, as their size can not be checked.
+func largeFileOperands(cu *CmdUnit) ([]string, error) {
+	var (
+		files     []string
+		flagsDone bool
+	)
+	valueFlags := largeFileValueFlags[cu.cmd]
+	programFlags, program := largeFilePrograms[cu.cmd]
+	for i := 0; i < len(cu.args); i++ {
+		arg := cu.args[i]
+		if !flagsDone && strings.HasPrefix(arg, "-") && arg != "-" {
+			if arg == "--" {
+				flagsDone = true
+				continue
+			}
+			if contains(programFlags, arg) {
+				program = false
+			}
+			if contains(valueFlags, arg) {
+				i++
+			}
+			continue
+		}
+		if program {
+			program = false
+			continue
+		}
+		if arg == "-" || strings.HasPrefix(arg, "+") {
+			continue
+		}
+		if i < len(cu.words) && !staticWord(cu.words[i]) {
+			return nil, unknownLargeFile(cu, cu.words[i])
+		}
+		files = append(files, arg)
+	}
+	for _, r := range cu.redirs {
+		if (r.N != nil && r.N.Value != "0") || (r.Op != syntax.RdrIn && r.Op != syntax.RdrInOut) {
+			continue
+		}
+		file, ok := literal(r.Word)
+		if !ok || !staticWord(r.Word) {
+			return nil, unknownLargeFile(cu, r.Word)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// staticWord tells whether w names the same file in the container, globs
+// and a leading ~ aside which are expanded there too. Braces are expanded by
+// the shell only.
+func staticWord(w *syntax.Word) bool {
+	if _, ok := literal(w); !ok {
+		return false
+	}
+	for _, part := range w.Parts {
+		if lit, ok := part.(*syntax.Lit); ok && strings.ContainsAny(lit.Value, "{}") {
+			return false
+		}
+	}
+	return true
+}
+
+func unknownLargeFile(cu *CmdUnit, w *syntax.Word) error {
+	return fmt.Errorf("the size of %s loaded by %s can not be checked, please name the file literally.", printWord(w), cu.cmd)
+}
+
+// globWord quotes the file for the shell, keeping glob characters and a
+// leading ~ so that they are expanded in the container.
+func globWord(file string) string {
+	if strings.ContainsAny(file, "\r\n") {
+		return quoteArg(file)
+	}
+	var sb strings.Builder
+	for i, c := range file {
+		switch {
+		case strings.ContainsRune("*?[]", c), i == 0 && c == '~':
+		case !strings.ContainsRune(safeArgChars, c):
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
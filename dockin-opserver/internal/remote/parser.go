@@ -20,97 +20,114 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
+// ParseCmdlineToCmdList returns the name of every command the command line
+// would run, see ParseCmdlineToCmdUnitList.
 func ParseCmdlineToCmdList(cmd string) ([]string, error) {
-	var cmdList []string
-	cmdReader := strings.NewReader(cmd)
-	prog, err := syntax.NewParser().Parse(cmdReader, "")
+	cul, err := ParseCmdlineToCmdUnitList(cmd)
 	if err != nil {
 		return nil, err
 	}
 
-	syntax.Walk(prog, func(node syntax.Node) bool {
-		switch node.(type) {
-		case *syntax.CallExpr:
-			callExpr := node.(*syntax.CallExpr)
-			if len(callExpr.Args) > 0 {
-				cmdList = append(cmdList, callExpr.Args[0].Lit())
-			}
-		case *syntax.DeclClause:
-			dcl := node.(*syntax.DeclClause)
-			cmdList = append(cmdList, dcl.Variant.Value)
-		}
-		return true
-	})
-
-	var filterCmd []string
-	for _, c := range cmdList {
-		idx := strings.LastIndex(c, "/")
-		if idx != -1 {
-			c = c[idx+1:]
-		}
-		filterCmd = append(filterCmd, c)
+	var cmdList []string
+	for _, cu := range cul {
+		cmdList = append(cmdList, cu.cmd)
 	}
-
-	return filterCmd, nil
+	return cmdList, nil
 }
 
+const safeArgChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+:,./-"
+
 type CmdUnit struct {
 	cmd    string
 	params []string
 	target string
+	args   []string
+	words  []*syntax.Word     // the args as written
+	redirs []*syntax.Redirect // the redirections of the statement running cmd
 }
 
+// ParseCmdlineToCmdUnitList returns every command the command line would run,
+// including commands nested in substitutions, eval, sh -c, heredocs fed to
+// a shell and wrappers such as env, sudo, xargs or find -exec. An error is
+// returned if any of them can not be determined.
 func ParseCmdlineToCmdUnitList(cmdline string) ([]*CmdUnit, error) {
-	cmdReader := strings.NewReader(cmdline)
-	prog, err := syntax.NewParser().Parse(cmdReader, "")
-	if err != nil {
-		return nil, err
+	return analyzeCmdline(cmdline)
+}
+
+// JoinArgs joins the argv of a command into a command line which parses back
+// into the same argv, so that the arguments of sh -c are analyzed as a script.
+func JoinArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, quoteArg(arg))
 	}
+	return strings.Join(quoted, " ")
+}
 
-	var cmdList []*CmdUnit
-	syntax.Walk(prog, func(node syntax.Node) bool {
-		switch node.(type) {
-		case *syntax.CallExpr:
-			callExpr := node.(*syntax.CallExpr)
-			num := len(callExpr.Args)
-			if num == 1 {
-				cmdList = append(cmdList, &CmdUnit{
-					cmd: callExpr.Args[0].Lit(),
-				})
-			} else if num == 2 {
-				cmdList = append(cmdList, &CmdUnit{
-					cmd:    callExpr.Args[0].Lit(),
-					target: callExpr.Args[1].Lit(),
-				})
-			} else if num > 2 {
-				cu := &CmdUnit{
-					cmd:    callExpr.Args[0].Lit(),
-					target: callExpr.Args[num-1].Lit(),
-				}
-				for _, p := range callExpr.Args[1:] {
-					if strings.HasPrefix(p.Lit(), "-") {
-						cu.params = append(cu.params, p.Lit())
-					} else {
-						cu.target = p.Lit()
-					}
-				}
-				cmdList = append(cmdList, cu)
+func quoteArg(arg string) string {
+	if arg != "" && strings.Trim(arg, safeArgChars) == "" {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+func newCmdUnit(name string, words []*syntax.Word) *CmdUnit {
+	num := len(words)
+	cu := &CmdUnit{
+		cmd:   name,
+		args:  wordValues(words[1:]),
+		words: words[1:],
+	}
+	if num == 2 {
+		cu.target = words[1].Lit()
+	} else if num > 2 {
+		cu.target = words[num-1].Lit()
+		for _, p := range words[1:] {
+			if strings.HasPrefix(p.Lit(), "-") {
+				cu.params = append(cu.params, p.Lit())
+			} else {
+				cu.target = p.Lit()
 			}
-		case *syntax.DeclClause:
-			dcl := node.(*syntax.DeclClause)
-			cmdList = append(cmdList, &CmdUnit{
-				cmd: dcl.Variant.Value,
-			})
 		}
-		return true
-	})
+	}
+	return cu
+}
 
-	for _, cu := range cmdList {
-		idx := strings.LastIndex(cu.cmd, "/")
-		if idx != -1 {
-			cu.cmd = cu.cmd[idx+1:]
+// wordValues returns the words with quotes removed, words with expansions
+// are kept in their source form.
+func wordValues(words []*syntax.Word) []string {
+	values := make([]string, 0, len(words))
+	for _, w := range words {
+		values = append(values, wordValue(w))
+	}
+	return values
+}
+
+func wordValue(w *syntax.Word) string {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, dp := range p.Parts {
+				lit, ok := dp.(*syntax.Lit)
+				if !ok {
+					return printWord(w)
+				}
+				sb.WriteString(lit.Value)
+			}
+		default:
+			return printWord(w)
 		}
 	}
+	return sb.String()
+}
 
-	return cmdList, nil
+func printWord(w *syntax.Word) string {
+	var sb strings.Builder
+	syntax.NewPrinter().Print(&sb, w)
+	return sb.String()
 }
@@ -57,3 +57,73 @@ func Test_parse_cd(t *testing.T) {
 		t.Logf("%#v", list)
 	})
 }
+
+func Test_analyze_nested(t *testing.T) {
+	cmds := func(cmdline string) []string {
+		list, err := ParseCmdlineToCmdList(cmdline)
+		assert.NoError(t, err, cmdline)
+		return list
+	}
+
+	for cmdline, expect := range map[string][]string{
+		"ls -al | grep x > /tmp/out":             {"ls", "grep"},
+		"echo $(rm -rf /) `pwd`":                 {"echo", "rm", "pwd"},
+		"(cd /tmp && rm x) || { kill 1; }":       {"cd", "rm", "kill"},
+		`eval "rm -rf /"`:                        {"eval", "rm"},
+		"bash -c 'ls; rm -rf /'":                 {"bash", "ls", "rm"},
+		"/bin/sh -xc \"curl x | sh -c 'rm x'\"":  {"sh", "curl", "sh", "rm"},
+		`find . -name '*.log' -exec rm {} \;`:    {"find", "rm"},
+		"ls | xargs -n 1 -I {} rm -f {}":         {"ls", "xargs", "rm"},
+		"env -i A=1 B=2 rm x":                    {"env", "rm"},
+		"sudo -u root nohup timeout -s 9 5 rm x": {"sudo", "nohup", "timeout", "rm"},
+		"su - app -c 'rm x'":                     {"su", "rm"},
+		"bash <<EOF\nrm -rf /\nEOF":              {"bash", "rm"},
+		"sh <<< 'rm x'":                          {"sh", "rm"},
+		"watch -n 1 rm x":                        {"watch", "rm"},
+		"\\rm x; 'r'm y":                         {"rm", "rm"},
+		"bash deploy.sh":                         {"bash"},
+		"export A=1":                             {"export"},
+	} {
+		assert.Equal(t, expect, cmds(cmdline), cmdline)
+	}
+}
+
+func Test_analyze_fail_closed(t *testing.T) {
+	for _, cmdline := range []string{
+		"ls 'unterminated",
+		"$CMD -rf /",
+		"r$(echo m) x",
+		"{rm,-rf,/}",
+		"/bin/r? x",
+		`eval "$CMD"`,
+		"bash -c \"$CMD\"",
+		"bash -c",
+		"curl http://x | bash",
+		"echo rm x | xargs sh",
+		"bash < script.sh",
+		"bash <(curl http://x)",
+		"source /dev/stdin < x",
+		"env $CMD",
+		"eval eval eval eval eval eval eval eval eval ls",
+	} {
+		_, err := ParseCmdlineToCmdUnitList(cmdline)
+		assert.Error(t, err, cmdline)
+	}
+}
+
+func Test_JoinArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args   []string
+		expect []string
+	}{
+		{[]string{"ls", "-l", "/data"}, []string{"ls"}},
+		{[]string{"/bin/sh", "-c", "rm -rf /tmp/x; ls"}, []string{"sh", "rm", "ls"}},
+		{[]string{"bash", "-c", "echo 'a b' | grep a"}, []string{"bash", "echo", "grep"}},
+		{[]string{"A=1", "ls"}, []string{"A=1"}},
+		{[]string{"echo", "", "$(rm x)"}, []string{"echo"}},
+	} {
+		list, err := ParseCmdlineToCmdList(JoinArgs(tc.args))
+		assert.NoError(t, err, tc.args)
+		assert.Equal(t, tc.expect, list, tc.args)
+	}
+}
@@ -17,11 +17,15 @@ package remote
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/webankfintech/dockin-opserver/internal/audit"
 	"github.com/webankfintech/dockin-opserver/internal/config"
-
 	"github.com/webankfintech/dockin-opserver/internal/log"
+	"github.com/webankfintech/dockin-opserver/internal/record"
+	"github.com/webankfintech/dockin-opserver/internal/redact"
+	"github.com/webankfintech/dockin-opserver/internal/sessions"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -52,6 +56,24 @@ type ExecSession struct {
 	execMode       ExecMode
 	IsRemoteClosed bool
 	dockinParm     *DockinExecParam
+	redactor       *redact.Redactor
+	outRedactor    *redact.Stream
+	recorder       *record.Recorder
+	auditor        CommandAuditor
+	lastCommand    *audit.Event
+	live           *sessions.Session
+	killed         chan string
+	notices        chan string
+	cancel         context.CancelFunc
+}
+
+// CommandAuditor records the command lines of a session.
+type CommandAuditor interface {
+	// AuditCommand records the command line with its verdict and returns the
+	// event.
+	AuditCommand(cmd, cwd string, err error, audited, granted []string) *audit.Event
+	// AuditExit records the exit status of the command of the event.
+	AuditExit(e *audit.Event, status int)
 }
 
 func (base *ExecSession) HandleReceiveClientMsg(ctx context.Context, traceId string) {
@@ -72,7 +94,7 @@ func (base *ExecSession) HandleReceiveClientMsg(ctx context.Context, traceId str
 			}
 			break
 		}
-		log.Logger.Infof("receive request msg, clientIp:%s, msg:%s, traceId=%s", base.clientIp, string(body), traceId)
+		log.Logger.Infof("receive request msg, clientIp:%s, msg:%s, traceId=%s", base.clientIp, base.redactor.String(string(body)), traceId)
 
 		msg, err := ParserToMessage(body)
 		if err != nil {
@@ -82,12 +104,15 @@ func (base *ExecSession) HandleReceiveClientMsg(ctx context.Context, traceId str
 
 		switch msg.Type {
 		case MsgCmd:
+			base.recorder.Input([]byte(msg.Cmd))
+			base.live.Input(len(msg.Cmd))
 			if err := base.handleClientInput(msg); err != nil {
 				log.Logger.Warnf("write byte to ssh remote:%s, err:%v, traceId=%s", base.clientIp, err, traceId)
 				return
 			}
 		case MsgResize:
 			base.Executor.Resize(msg.Cols, msg.Rows)
+			base.recorder.Resize(msg.Cols, msg.Rows)
 		}
 	}
 }
@@ -95,9 +120,12 @@ func (base *ExecSession) HandleReceiveClientMsg(ctx context.Context, traceId str
 func (base *ExecSession) HandleWriteClientMsg(ctx context.Context, traceId string) {
 	log.Logger.Infof("start to HandleWriteClientMsg traceId=%s", traceId)
 	ticker := time.NewTicker(pingPeriod)
+	flush := time.NewTimer(outputFlushDelay)
+	flush.Stop()
 	defer func() {
 		log.Logger.Infof("close goroutine handle websocket write,traceId=%s", traceId)
 		ticker.Stop()
+		flush.Stop()
 		base.Close()
 	}()
 
@@ -108,24 +136,31 @@ func (base *ExecSession) HandleWriteClientMsg(ctx context.Context, traceId strin
 			return
 		case buf := <-base.InterStream.outBuffer:
 			if len(buf) > 0 {
-				base.handleRemoteOutput([]byte(buf))
-				base.conn.SetWriteDeadline(time.Now().Add(writeWait))
-				w, err := base.conn.NextWriter(websocket.TextMessage)
-				if err != nil {
-					log.Logger.Infof("create writer err:%v, traceId:%s", err, traceId)
-					return
+				buf = string(base.handleRemoteOutput([]byte(buf)))
+				if len(buf) == 0 {
+					continue
 				}
-				if _, err := w.Write([]byte(buf)); err != nil {
-					log.Logger.Warnf("write date to client, err:%v, traceId:%s", err, traceId)
-					return
-				}
-
-				if err := w.Close(); err != nil {
-					log.Logger.Infof("close writer err:%v, traceId:%s", err, traceId)
+				flush.Reset(outputFlushDelay)
+				if err := base.writeOutput(base.outRedactor.String(buf), traceId); err != nil {
 					return
 				}
 				//base.InterStream.outBuffer.Reset()
 			}
+		case <-flush.C:
+			if err := base.writeOutput(base.outRedactor.Flush(), traceId); err != nil {
+				return
+			}
+		case message := <-base.killed:
+			log.Logger.Infof("session is killed, message=%s, traceId:%s", message, traceId)
+			base.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			base.conn.WriteMessage(websocket.TextMessage, []byte("\r\n\r\n"+message+"\r\n"))
+			base.cancel()
+			return
+		case message := <-base.notices:
+			base.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := base.conn.WriteMessage(websocket.TextMessage, []byte("\r\n"+message+"\r\n")); err != nil {
+				return
+			}
 		case <-ticker.C:
 			base.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := base.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -135,6 +170,31 @@ func (base *ExecSession) HandleWriteClientMsg(ctx context.Context, traceId strin
 	}
 }
 
+// writeOutput records the redacted output and writes it to the client.
+func (base *ExecSession) writeOutput(buf, traceId string) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	base.recorder.Output([]byte(buf))
+	base.live.Output([]byte(buf))
+	base.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	w, err := base.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		log.Logger.Infof("create writer err:%v, traceId:%s", err, traceId)
+		return err
+	}
+	if _, err := w.Write([]byte(buf)); err != nil {
+		log.Logger.Warnf("write date to client, err:%v, traceId:%s", err, traceId)
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		log.Logger.Infof("close writer err:%v, traceId:%s", err, traceId)
+		return err
+	}
+	return nil
+}
+
 func (base *ExecSession) Close() {
 	time.Sleep(100 * time.Millisecond)
 	if base.isClosed {
@@ -147,15 +207,15 @@ func (base *ExecSession) Close() {
 	base.conn.Close()
 }
 
-func (base *ExecSession) handleRemoteOutput(buffer []byte) {
+func (base *ExecSession) handleRemoteOutput(buffer []byte) []byte {
 	if base.execMode != SSHExecMode {
-		return
+		return buffer
 	}
 	if len(buffer) == 0 {
-		return
+		return buffer
 	}
 
-	base.sshIOManager.OnOutput(buffer)
+	return base.sshIOManager.HandleOutput(buffer)
 }
 
 func (base *ExecSession) handleClientInput(msg *Message) error {
@@ -163,39 +223,130 @@ func (base *ExecSession) handleClientInput(msg *Message) error {
 		log.CommandLogger.Info("ssh",
 			zap.String("operator", base.dockinParm.UserName),
 			zap.String("ip", base.clientIp),
-			zap.String("command", msg.CmdLine),
+			zap.String("command", base.redactor.String(msg.CmdLine)),
 			zap.String("timestamp", fmt.Sprintf("%d", time.Now().Unix())),
 			zap.String("containerName", base.dockinParm.ContainerName),
 			zap.String("rule", base.dockinParm.Rule))
 
-		if err := base.sshIOManager.OnInput([]byte(msg.Cmd)); err != nil {
-			base.InterStream.inputBuffer <- []byte{CharInterrupt}
-			time.Sleep(time.Millisecond * 100)
-			base.InterStream.inputBuffer <- []byte(err.Error())
-			time.Sleep(time.Millisecond * 100)
-			base.InterStream.inputBuffer <- []byte{CharInterrupt}
-			return nil
-		}
+		base.sshIOManager.HandleInput([]byte(msg.Cmd))
+		return nil
 	}
 
 	base.InterStream.inputBuffer <- []byte(msg.Cmd)
 	return nil
 }
 
+func (base *ExecSession) reportCommand(cmd, cwd string, err error, audited, granted []string) {
+	verdict, reason := audit.VerdictAllowed, strings.Join(audited, "; ")
+	if err != nil {
+		verdict, reason = audit.VerdictDenied, err.Error()
+	} else if len(granted) > 0 {
+		verdict, reason = audit.VerdictGranted, strings.Join(granted, "; ")
+	} else if len(audited) > 0 {
+		verdict = audit.VerdictAudited
+	}
+	log.CommandLogger.Info("ssh-command",
+		zap.String("operator", base.dockinParm.UserName),
+		zap.String("ip", base.clientIp),
+		zap.String("command", base.redactor.String(cmd)),
+		zap.String("cwd", cwd),
+		zap.String("verdict", verdict),
+		zap.String("reason", reason),
+		zap.String("timestamp", fmt.Sprintf("%d", time.Now().Unix())),
+		zap.String("containerName", base.dockinParm.ContainerName),
+		zap.String("rule", base.dockinParm.Rule))
+
+	if base.auditor == nil {
+		return
+	}
+	e := base.auditor.AuditCommand(base.redactor.String(cmd), cwd, err, audited, granted)
+	base.lastCommand = nil
+	if err == nil {
+		base.lastCommand = e
+	}
+}
+
+// reportExit records the exit status of the last command line.
+func (base *ExecSession) reportExit(status int) {
+	if base.auditor == nil || base.lastCommand == nil {
+		return
+	}
+	e := base.lastCommand
+	base.lastCommand = nil
+	base.auditor.AuditExit(e, status)
+}
+
 func (base *ExecSession) Start(ctx context.Context, traceId string) error {
 	go base.HandleReceiveClientMsg(ctx, traceId)
 	go base.HandleWriteClientMsg(ctx, traceId)
+	if base.execMode == SSHExecMode && config.OpsConfig.ShellHook {
+		base.sshIOManager.StartHook()
+	}
 	return nil
 }
 
+// Track reports the traffic of the session to the registry, which may
+// notify the user and kill the session.
+func (base *ExecSession) Track(s *sessions.Session) {
+	base.live = s
+	s.Attach(base)
+}
+
+// Notify shows the message to the user, it is dropped if earlier messages
+// are not shown yet.
+func (base *ExecSession) Notify(message string) {
+	select {
+	case base.notices <- message:
+	default:
+	}
+}
+
+// Kill shows the message to the user and ends the session.
+func (base *ExecSession) Kill(message string) {
+	select {
+	case base.killed <- message:
+	default:
+	}
+}
+
+// SetAuditor records every command line of the session with its verdict.
+func (base *ExecSession) SetAuditor(a CommandAuditor) {
+	base.auditor = a
+}
+
+// SetRecorder records the input, output and resizes of the session.
+func (base *ExecSession) SetRecorder(r *record.Recorder) {
+	base.recorder = r
+}
+
 func (base *ExecSession) AddFilter(cf Filter) {
 	base.sshIOManager.AddFilter(cf)
 }
 
+func (base *ExecSession) SetFilterMode(mode FuncGetFilterMode, record FuncRecordViolation) {
+	base.sshIOManager.SetFilterMode(mode, record)
+}
+
+func (base *ExecSession) SetGrant(grant FuncGrant) {
+	base.sshIOManager.SetGrant(grant)
+}
+
+func (base *ExecSession) ReloadFilters() {
+	base.sshIOManager.ReloadFilters()
+}
+
+func (base *ExecSession) WorkingDir() string {
+	return base.sshIOManager.sshContext.getCurrentWorkingDir()
+}
+
 func CreateExecSession(ctx context.Context, dockinParm *DockinExecParam, conn *websocket.Conn, mode ExecMode) (*ExecSession, error) {
 	log.Logger.Infof("start to CreateExecSession")
+	ctx, cancel := context.WithCancel(ctx)
 	docker := &ExecSession{
-		conn: conn,
+		conn:    conn,
+		killed:  make(chan string, 1),
+		notices: make(chan string, 4),
+		cancel:  cancel,
 		InterStream: &InteractStream{
 			outBuffer:   make(chan string),
 			ctx:         ctx,
@@ -205,13 +356,21 @@ func CreateExecSession(ctx context.Context, dockinParm *DockinExecParam, conn *w
 	}
 	ioFilter := NewIOFilter()
 	sshContext := NewSSHContext()
+	sshContext.prompts = PromptMatcherForRule(dockinParm.Rule)
 	if dockinParm.WorkDir != "" {
 		sshContext.WorkingDir = dockinParm.WorkDir
 	}
 	docker.execMode = mode
 	docker.Executor = NewDockerExecutor(dockinParm.HostIP, config.OpsConfig.OpAgentPort)
+	docker.redactor = redact.ForRule(dockinParm.Rule, dockinParm.Namespace)
+	docker.outRedactor = docker.redactor.NewStream()
+	ioFilter.redactor = docker.redactor
 	docker.sshIOManager = NewSSHIOManager(sshContext, ioFilter)
-	docker.AddFilter(NewVimFilter(dockinParm.ContainerName, docker.Executor, sshContext.getCurrentWorkingDir))
+	docker.sshIOManager.write = func(data []byte) {
+		docker.InterStream.inputBuffer <- data
+	}
+	docker.sshIOManager.SetCommandReporter(docker.reportCommand, docker.reportExit)
+	docker.AddFilter(NewLargeFileFilter(dockinParm.ContainerName, docker.Executor, sshContext.getCurrentWorkingDir))
 	log.Logger.Infof("success to CreateExecSession %v", docker)
 	return docker, nil
 }
@@ -0,0 +1,239 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package remote
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hookOSC starts the sequences the shell hook reports with, they are removed
+// from the output before it reaches the client.
+const hookOSC = "\x1b]6973;"
+
+// markers reported by the shell hook
+const (
+	hookReady    = "R"
+	hookCommand  = "C"
+	hookDone     = "D"
+	hookContinue = "P"
+)
+
+// shellHookScript installs the hook in bash. Before every command line runs,
+// the DEBUG trap reports the line from history with its cwd and waits for the
+// verdict, which opserver writes to the input as "<id>:allow" or "<id>:deny";
+// the line is skipped through extdebug unless it is allowed. PROMPT_COMMAND
+// reports the exit status and cwd once the line finished, and PS2 reports
+// that the shell waits for the rest of a multi-line command. Commands run by
+// programmable completion are denied, they are not in history.
+var shellHookScript = strings.Join([]string{
+	` stty -echo`,
+	`__dockin_done=1 __dockin_denied= __dockin_prompt= __dockin_ready= __dockin_status=0`,
+	`__dockin_osc() { builtin printf '\033]6973;%s\007' "$1"; }`,
+	`__dockin_hex() { local LC_ALL=C s="$1" i c h=; for ((i=0;i<${#s};i++)); do builtin printf -v c '%d' "'${s:i:1}"; builtin printf -v h '%s%02x' "$h" $((c&255)); done; REPLY=$h; }`,
+	`__dockin_histno() { local h; h=$(HISTTIMEFORMAT= builtin history 1); h="${h#"${h%%[![:space:]]*}"}"; REPLY=${h%%[!0-9]*}; }`,
+	`__dockin_start() { __dockin_status=$?; __dockin_prompt=1; return $__dockin_status; }`,
+	`__dockin_precmd() { __dockin_histno; __dockin_hist=$REPLY; __dockin_done= __dockin_denied= __dockin_prompt=; __dockin_hex "$PWD"; __dockin_osc "D;$__dockin_status;$REPLY"; }`,
+	`__dockin_preexec() { [ -n "$__dockin_ready" ] || return 0; [ -n "$__dockin_prompt" ] && return 0; [ -n "$COMP_LINE" ] && return 1; ` +
+		`case "$BASH_COMMAND" in __dockin_start|__dockin_precmd) return 0;; esac; ` +
+		`if [ -n "$__dockin_done" ]; then [ -z "$__dockin_denied" ]; return; fi; __dockin_done=1; ` +
+		`local id="${SRANDOM:-$RANDOM}$RANDOM$RANDOM" line verdict cmd; __dockin_histno; ` +
+		`if [ "$REPLY" != "$__dockin_hist" ]; then line=$(HISTTIMEFORMAT= builtin history 1); line="${line#"${line%%[![:space:]]*}"}"; line="${line#*[0-9][ *] }"; fi; ` +
+		`__dockin_hist=$REPLY; __dockin_hex "$line"; cmd=$REPLY; __dockin_hex "$PWD"; __dockin_osc "C;$id;$cmd;$REPLY"; ` +
+		`while builtin read -rs -t 10 verdict; do case "$verdict" in "$id:allow") return 0;; "$id:deny") break;; esac; done; ` +
+		`__dockin_denied=1; return 1; }`,
+	`HISTCONTROL= HISTIGNORE=; set -o history; shopt -s extdebug`,
+	`PROMPT_COMMAND="__dockin_start${PROMPT_COMMAND:+;$PROMPT_COMMAND};__dockin_precmd"`,
+	`PS2="\[\033]6973;P\007\]$PS2"`,
+	`trap __dockin_preexec DEBUG`,
+	`__dockin_ready=1; stty echo; __dockin_osc R`,
+}, "; ") + "\r"
+
+// hookTamper matches command lines which could disable or fool the hook.
+var hookTamper = regexp.MustCompile(`__dockin|\b(PROMPT_COMMAND|PS2|HISTCONTROL|HISTIGNORE|HISTSIZE|HISTCMD|extdebug)\b|` +
+	`\btrap\b[^;&|]*\bDEBUG\b|\b(set|shopt)\b[^;&|]*\bhistory\b`)
+
+// hookBypass are the commands which could run or replace the builtins the
+// hook relies on, or run commands the hook does not see through completion,
+// they are only denied in command position.
+var hookBypass = map[string]bool{
+	"builtin":  true,
+	"enable":   true,
+	"complete": true,
+	"compgen":  true,
+}
+
+type hookMarker struct {
+	kind string
+	args []string
+}
+
+// hookItem is either output text or a marker, in the order of the output.
+type hookItem struct {
+	text   []byte
+	marker *hookMarker
+}
+
+// hookParser takes the markers out of the output, a marker may be split
+// between writes.
+type hookParser struct {
+	pending []byte
+}
+
+func (hp *hookParser) parse(data []byte) []*hookItem {
+	data = append(hp.pending, data...)
+	hp.pending = nil
+
+	var items []*hookItem
+	text := func(b []byte) {
+		if len(b) > 0 {
+			items = append(items, &hookItem{text: b})
+		}
+	}
+	for len(data) > 0 {
+		idx := bytes.Index(data, []byte(hookOSC))
+		if idx == -1 {
+			keep := partialPrefix(data, hookOSC)
+			text(data[:len(data)-keep])
+			hp.pending = append(hp.pending, data[len(data)-keep:]...)
+			break
+		}
+		text(data[:idx])
+		rest := data[idx+len(hookOSC):]
+		end := bytes.IndexByte(rest, CharBell)
+		if end == -1 {
+			hp.pending = append(hp.pending, data[idx:]...)
+			break
+		}
+		fields := strings.Split(string(rest[:end]), ";")
+		items = append(items, &hookItem{marker: &hookMarker{kind: fields[0], args: fields[1:]}})
+		data = rest[end+1:]
+	}
+	return items
+}
+
+// partialPrefix returns the length of the longest suffix of data which is a
+// prefix of s.
+func partialPrefix(data []byte, s string) int {
+	for n := len(s) - 1; n > 0; n-- {
+		if len(data) >= n && string(data[len(data)-n:]) == s[:n] {
+			return n
+		}
+	}
+	return 0
+}
+
+// hookRequest is a command line reported by the hook.
+type hookRequest struct {
+	id  string
+	cmd string
+	cwd string
+}
+
+func parseHookRequest(m *hookMarker) (*hookRequest, error) {
+	if len(m.args) != 3 {
+		return nil, fmt.Errorf("invalid command marker %v", m.args)
+	}
+	cmd, err := hex.DecodeString(m.args[1])
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := hex.DecodeString(m.args[2])
+	if err != nil {
+		return nil, err
+	}
+	return &hookRequest{id: m.args[0], cmd: string(cmd), cwd: string(cwd)}, nil
+}
+
+func parseHookDone(m *hookMarker) (int, string, error) {
+	if len(m.args) != 2 {
+		return 0, "", fmt.Errorf("invalid done marker %v", m.args)
+	}
+	status, err := strconv.Atoi(m.args[0])
+	if err != nil {
+		return 0, "", err
+	}
+	cwd, err := hex.DecodeString(m.args[1])
+	if err != nil {
+		return 0, "", err
+	}
+	return status, string(cwd), nil
+}
+
+func (hr *hookRequest) verdict(err error) []byte {
+	if err != nil {
+		return []byte(hr.id + ":deny\r")
+	}
+	return []byte(hr.id + ":allow\r")
+}
+
+// checkHookedCommand denies command lines the hook can not be trusted with:
+// lines missing from history, lines changing the hook and nested interactive
+// shells, whose commands the hook would not see.
+func checkHookedCommand(cmd string) error {
+	if strings.TrimSpace(cmd) == "" {
+		return fmt.Errorf("command is not allowd to exec, it is not recorded in the shell history.")
+	}
+	if hookTamper.MatchString(cmd) {
+		return fmt.Errorf("command is not allowd to exec, it changes the command audit of the session.")
+	}
+	cul, err := ParseCmdlineToCmdUnitList(cmd)
+	if err != nil {
+		return fmt.Errorf("command is not allowd to exec, %s.", err)
+	}
+	for _, cu := range cul {
+		if hookBypass[cu.cmd] {
+			return fmt.Errorf("command [%s] is not allowd to exec, it changes the command audit of the session.", cu.cmd)
+		}
+		if opensShell(cu) {
+			return fmt.Errorf("command [%s] is not allowd to exec, nested interactive shells are not supported.", cu.cmd)
+		}
+	}
+	return nil
+}
+
+// opensShell reports whether the command starts an interactive shell.
+func opensShell(cu *CmdUnit) bool {
+	switch {
+	case shells[cu.cmd]:
+		for i := 0; i < len(cu.args); i++ {
+			arg := cu.args[i]
+			switch {
+			case arg == "--rcfile" || arg == "--init-file" || arg == "-o" || arg == "-O":
+				i++
+			case !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "+"):
+				// a script file
+				return false
+			case !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c"):
+				return false
+			}
+		}
+		return true
+	case cu.cmd == "su":
+		return !contains(cu.args, "-c") && !contains(cu.args, "--command")
+	case cu.cmd == "sudo":
+		for _, arg := range cu.args {
+			switch arg {
+			case "-i", "-s", "--login", "--shell":
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package remote
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/webankfintech/dockin-opserver/internal/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hookOutput(fields ...string) string {
+	out := hookOSC
+	for i, f := range fields {
+		if i > 0 {
+			out += ";"
+		}
+		out += f
+	}
+	return out + "\a"
+}
+
+func hexString(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+func Test_hookParser(t *testing.T) {
+	hp := &hookParser{}
+	out := "total 0\r\n" + hookOutput(hookDone, "0", hexString("/data")) + "[app@pod data]$ "
+
+	var items []*hookItem
+	for _, chunk := range []string{out[:12], out[12:20], out[20:]} {
+		items = append(items, hp.parse([]byte(chunk))...)
+	}
+	assert.Len(t, items, 3)
+	assert.Equal(t, "total 0\r\n", string(items[0].text))
+	assert.Equal(t, hookDone, items[1].marker.kind)
+	status, cwd, err := parseHookDone(items[1].marker)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "/data", cwd)
+	assert.Equal(t, "[app@pod data]$ ", string(items[2].text))
+
+	items = hp.parse([]byte("\x1b]0;title\a\x1b]69"))
+	assert.Equal(t, "\x1b]0;title\a", string(items[0].text))
+	items = hp.parse([]byte("73;R\a"))
+	assert.Equal(t, hookReady, items[0].marker.kind)
+}
+
+func Test_parseHookRequest(t *testing.T) {
+	req, err := parseHookRequest(&hookMarker{kind: hookCommand, args: []string{"42", hexString("ls -l 'a b'"), hexString("/tmp")}})
+	assert.NoError(t, err)
+	assert.Equal(t, "ls -l 'a b'", req.cmd)
+	assert.Equal(t, "/tmp", req.cwd)
+	assert.Equal(t, "42:allow\r", string(req.verdict(nil)))
+	assert.Equal(t, "42:deny\r", string(req.verdict(assert.AnError)))
+
+	_, err = parseHookRequest(&hookMarker{kind: hookCommand, args: []string{"42", "zz", ""}})
+	assert.Error(t, err)
+}
+
+func Test_checkHookedCommand(t *testing.T) {
+	for _, cmd := range []string{"ls -l", "bash -c 'ls'", "bash deploy.sh", "sh -ec true", "su app -c ls", "sudo ls",
+		"systemctl enable foo", "grep enable app.conf", "cat builtin.txt", "ls -l /data/enable", "grep complete app.log"} {
+		assert.NoError(t, checkHookedCommand(cmd), cmd)
+	}
+	for _, cmd := range []string{"", "trap - DEBUG", "unset PROMPT_COMMAND", "set +o history", "builtin history -c",
+		"bash", "bash --rcfile x.rc", "ls; sh -i", "su - app", "sudo -i", "echo $(__dockin_ready=)",
+		"enable -n trap", "ls; builtin trap - INT", "command enable -n history", "sudo enable -n set", "echo $(builtin history -c)",
+		"complete -C 'touch /tmp/x' zz", "complete -F _x zz", "ls; compgen -C 'touch /tmp/x' z"} {
+		assert.Error(t, checkHookedCommand(cmd), cmd)
+	}
+}
+
+func Test_SSHIOManagerHook(t *testing.T) {
+	var written []string
+	im := NewSSHIOManager(NewSSHContext(), NewIOFilter())
+	im.write = func(data []byte) {
+		written = append(written, string(data))
+	}
+	var reported []string
+	var exits []int
+	im.SetCommandReporter(func(cmd, cwd string, err error, audited, granted []string) {
+		reported = append(reported, cmd)
+	}, func(status int) {
+		exits = append(exits, status)
+	})
+	im.AddFilter(NewBlacklistFilter(func() []string {
+		return []string{"rm"}
+	}))
+
+	im.StartHook()
+	assert.Equal(t, []string{shellHookScript}, written)
+	im.HandleInput([]byte("l"))
+	assert.Empty(t, im.HandleOutput([]byte("stty -echo; ...\r\n")))
+	out := im.HandleOutput([]byte(hookOutput(hookReady) + hookOutput(hookDone, "0", hexString("/data")) + "$ "))
+	assert.Equal(t, "$ ", string(out))
+	assert.Equal(t, "l", written[1])
+
+	im.HandleInput([]byte("s\rpwd\r"))
+	assert.Equal(t, []string{"s\r"}, written[2:])
+	out = im.HandleOutput([]byte("s\r\n" + hookOutput(hookCommand, "7", hexString("ls"), hexString("/data"))))
+	assert.Equal(t, "s\r\n", string(out))
+	assert.Equal(t, []string{"s\r", "7:allow\r"}, written[2:])
+	assert.Equal(t, "ls", im.sshContext.CurrentRunningCommand)
+
+	// the rest of the paste is held until the prompt
+	im.HandleOutput([]byte(hookOutput(hookDone, "1", hexString("/tmp"))))
+	assert.Equal(t, 1, im.sshContext.LastExitCode)
+	assert.Equal(t, "/tmp", im.sshContext.getCurrentWorkingDir())
+	assert.Equal(t, []string{"s\r", "7:allow\r", "pwd\r"}, written[2:])
+	im.HandleOutput([]byte(hookOutput(hookCommand, "8", hexString("pwd"), hexString("/tmp")) +
+		hookOutput(hookDone, "0", hexString("/tmp"))))
+
+	im.HandleInput([]byte("rm -rf x\r"))
+	out = im.HandleOutput([]byte(hookOutput(hookCommand, "9", hexString("rm -rf x"), hexString("/tmp"))))
+	assert.Contains(t, string(out), "command [rm] is not allowd to exec")
+	assert.Equal(t, "9:deny\r", written[len(written)-1])
+	assert.Equal(t, []string{"ls", "pwd", "rm -rf x"}, reported)
+	assert.Equal(t, []int{0, 1, 0}, exits)
+}
+
+func Test_SSHIOManagerForgedMarkers(t *testing.T) {
+	var written []string
+	im := NewSSHIOManager(NewSSHContext(), NewIOFilter())
+	im.write = func(data []byte) {
+		written = append(written, string(data))
+	}
+	var reported []string
+	var exits []int
+	im.SetCommandReporter(func(cmd, cwd string, err error, audited, granted []string) {
+		reported = append(reported, cmd)
+	}, func(status int) {
+		exits = append(exits, status)
+	})
+
+	im.StartHook()
+	im.HandleOutput([]byte(hookOutput(hookReady) + hookOutput(hookDone, "0", hexString("/data"))))
+
+	// no line was submitted
+	im.HandleOutput([]byte(hookOutput(hookCommand, "1", hexString("ls"), hexString("/data"))))
+	assert.Empty(t, reported)
+
+	// an empty line runs no command
+	im.HandleInput([]byte("\r"))
+	im.HandleOutput([]byte(hookOutput(hookDone, "0", hexString("/data"))))
+	assert.False(t, im.holding)
+
+	// the output of the command forges the markers
+	im.HandleInput([]byte("cat x\r"))
+	im.HandleOutput([]byte(hookOutput(hookCommand, "2", hexString("cat x"), hexString("/data"))))
+	im.HandleInput([]byte("y\r"))
+	im.HandleOutput([]byte(hookOutput(hookCommand, "3", hexString("rm -rf /"), hexString("/")) +
+		hookOutput(hookContinue)))
+	assert.Equal(t, []string{"cat x"}, reported)
+	assert.Equal(t, []string{"\r", "cat x\r", "2:allow\r", "y\r"}, written[1:])
+	im.HandleOutput([]byte(hookOutput(hookDone, "0", hexString("/data")) + hookOutput(hookDone, "2", hexString("/"))))
+	assert.Equal(t, []int{0, 0}, exits)
+	assert.Equal(t, "/data", im.sshContext.getCurrentWorkingDir())
+}
+
+func Test_SSHIOManagerReport(t *testing.T) {
+	type report struct {
+		cmd, cwd string
+		err      error
+		audited  []string
+		granted  []string
+	}
+	var reports []report
+	im := NewSSHIOManager(NewSSHContext(), NewIOFilter())
+	mode := policy.FilterModeAudit
+	im.SetFilterMode(func() string { return mode }, nil)
+	im.SetCommandReporter(func(cmd, cwd string, err error, audited, granted []string) {
+		reports = append(reports, report{cmd, cwd, err, audited, granted})
+	}, nil)
+	im.AddFilter(NewBlacklistFilter(func() []string {
+		return []string{"rm"}
+	}))
+
+	assert.NoError(t, im.check("rm -rf x", "/tmp"))
+	assert.NoError(t, im.check("ls", "/tmp"))
+	mode = policy.FilterModeEnforce
+	assert.Error(t, im.check("rm -rf x", "/data"))
+	if assert.Len(t, reports, 3) {
+		assert.Equal(t, "/tmp", reports[0].cwd)
+		assert.NoError(t, reports[0].err)
+		assert.Len(t, reports[0].audited, 1)
+		assert.Empty(t, reports[1].audited)
+		assert.Error(t, reports[2].err)
+		assert.Equal(t, "/data", reports[2].cwd)
+	}
+
+	im.SetGrant(func(cmdLine string, denied error) (string, error) {
+		if cmdLine == "rm -rf x" {
+			return "granted by approval request a1", nil
+		}
+		return "", denied
+	})
+	assert.NoError(t, im.check("rm -rf x", "/data"))
+	assert.Error(t, im.check("rm -rf y", "/data"))
+	if assert.Len(t, reports, 5) {
+		assert.NoError(t, reports[3].err)
+		assert.Equal(t, []string{"granted by approval request a1"}, reports[3].granted)
+		assert.Error(t, reports[4].err)
+		assert.Empty(t, reports[4].granted)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package remote
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+)
+
+// defaultPromptPattern matches the [user@host dir]$ prompt of the base images.
+const defaultPromptPattern = `\[(?P<user>[^@\[\]\s]+)@(?P<host>[^\s\]]+) (?P<dir>[^\]]+)\][$#] `
+
+var (
+	defaultPrompts = NewPromptMatcher(nil)
+
+	// osc7 is the sequence shells print with the working directory before
+	// the prompt, as file://host/path.
+	osc7 = regexp.MustCompile(`\x1b\]7;file://[^/\x07\x1b]*(/[^\x07\x1b]*)(?:\x07|\x1b\\)`)
+)
+
+// PromptMatcher recognizes the shell prompt in the output. The patterns are
+// regular expressions with the optional named groups user, host and dir.
+type PromptMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+func NewPromptMatcher(patterns []string) *PromptMatcher {
+	pm := &PromptMatcher{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Logger.Warnf("invalid prompt pattern %s, err=%s", p, err.Error())
+			continue
+		}
+		pm.patterns = append(pm.patterns, re)
+	}
+	if len(pm.patterns) == 0 {
+		pm.patterns = []*regexp.Regexp{regexp.MustCompile(defaultPromptPattern)}
+	}
+	return pm
+}
+
+// PromptMatcherForRule returns the prompt patterns configured for the rule,
+// or the default ones.
+func PromptMatcherForRule(rule string) *PromptMatcher {
+	if patterns, ok := config.OpsConfig.Prompt.Rules[rule]; ok {
+		return NewPromptMatcher(patterns)
+	}
+	return NewPromptMatcher(config.OpsConfig.Prompt.Patterns)
+}
+
+// Match returns the last prompt in the output, or nil if there is none.
+func (pm *PromptMatcher) Match(output []byte) *ShellPrompt {
+	for _, re := range pm.patterns {
+		all := re.FindAllSubmatch(output, -1)
+		if len(all) == 0 {
+			continue
+		}
+		m := all[len(all)-1]
+		sp := &ShellPrompt{}
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "user":
+				sp.user = string(m[i])
+			case "host":
+				sp.instance = string(m[i])
+			case "dir":
+				sp.dir = strings.TrimSpace(string(m[i]))
+			}
+		}
+		return sp
+	}
+	return nil
+}
+
+// matchOSC7 returns the working directory of the last OSC 7 sequence in the
+// output.
+func matchOSC7(output []byte) (string, bool) {
+	all := osc7.FindAllSubmatch(output, -1)
+	if len(all) == 0 {
+		return "", false
+	}
+	dir, err := url.PathUnescape(string(all[len(all)-1][1]))
+	if err != nil {
+		log.Logger.Warnf("invalid working directory in OSC 7, err=%s", err.Error())
+		return "", false
+	}
+	return dir, true
+}
+
+// promptChange matches command lines which may change the prompt.
+var promptChange = regexp.MustCompile(`\b(PS1|PROMPT_COMMAND)\b`)
+
+// changesPrompt reports whether the prompt may look different after the
+// command line, so it is no longer known.
+func changesPrompt(cmd string) bool {
+	if promptChange.MatchString(cmd) {
+		return true
+	}
+	cul, err := ParseCmdlineToCmdUnitList(cmd)
+	if err != nil {
+		return true
+	}
+	for _, cu := range cul {
+		if cu.cmd == "exec" || cu.cmd == "source" || cu.cmd == "." || opensShell(cu) {
+			return true
+		}
+	}
+	return false
+}
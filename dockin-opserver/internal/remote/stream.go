@@ -76,7 +76,7 @@ func (is *InteractStream) Read(p []byte) (n int, err error) {
 
 func (is *InteractStream) Write(p []byte) (n int, err error) {
 	n = len(p)
-	log.Logger.Debugf("write data to client, in string=%s", string(p))
+	log.Logger.Debugf("write data to client, len=%d", n)
 	is.outBuffer <- string(p)
 	return
 }
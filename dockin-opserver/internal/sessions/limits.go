@@ -0,0 +1,208 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package sessions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	"github.com/pkg/errors"
+)
+
+var checkInterval = time.Second
+
+// reserveScript drops the stale slots of the user and the pod, checks the
+// caps and adds the slot of the session to both in one step. It returns 0,
+// or the index of the key at its cap with the number of its slots.
+const reserveScript = `
+local caps = {tonumber(ARGV[4]), tonumber(ARGV[5])}
+for i, key in ipairs(KEYS) do
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', '(' .. ARGV[3])
+	local n = redis.call('ZCARD', key)
+	if caps[i] > 0 and n >= caps[i] then
+		return {i, n}
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call('ZADD', key, ARGV[2], ARGV[1])
+	redis.call('PEXPIRE', key, ARGV[6])
+end
+return {0, 0}
+`
+
+// Limits of the sessions of a rule, zero disables a limit.
+type Limits struct {
+	IdleTimeout time.Duration
+	MaxDuration time.Duration
+	Warning     time.Duration
+	MaxPerUser  int
+	MaxPerPod   int
+}
+
+// LimitsForRule returns the limits of session.rules for the rule, or the
+// default limits of session if the rule has none.
+func LimitsForRule(rule string) *Limits {
+	c, ok := config.OpsConfig.Session.Rules[rule]
+	if !ok {
+		c = config.OpsConfig.Session.SessionLimits
+	}
+	return &Limits{
+		IdleTimeout: time.Duration(c.IdleTimeout) * time.Second,
+		MaxDuration: time.Duration(c.MaxDuration) * time.Second,
+		Warning:     time.Duration(c.Warning) * time.Second,
+		MaxPerUser:  c.MaxPerUser,
+		MaxPerPod:   c.MaxPerPod,
+	}
+}
+
+// admit reserves a slot of the user and the pod for the session. The slots
+// of every instance are kept in redis and reserved by one script, so that
+// sessions opened on several instances at once can not exceed the caps.
+// Without redis only the sessions of this instance are counted.
+func (r *Registry) admit(info *Info, limits *Limits) error {
+	if r.redisClient == nil {
+		return r.admitLocal(info, limits)
+	}
+	now := time.Now()
+	res, err := r.redisClient.Eval(reserveScript, slotKeys(info),
+		info.Id, unixMillis(now), unixMillis(now.Add(-staleAfter)), limits.MaxPerUser, limits.MaxPerPod,
+		int64(staleAfter/time.Millisecond))
+	if err != nil {
+		log.Logger.Warnf("failed to reserve session slots of %s, err=%s", info.Id, err.Error())
+		if limits.MaxPerUser > 0 || limits.MaxPerPod > 0 {
+			return errors.Wrap(err, "failed to check the session limits")
+		}
+		return nil
+	}
+	var full, n int64
+	if v, ok := res.([]interface{}); ok && len(v) == 2 {
+		full, _ = v[0].(int64)
+		n, _ = v[1].(int64)
+	}
+	return capError(info, limits, int(full), int(n))
+}
+
+func (r *Registry) admitLocal(info *Info, limits *Limits) error {
+	var users, pods int
+	r.lock.RLock()
+	for _, s := range r.sessions {
+		if s.info.User == info.User {
+			users++
+		}
+		if s.info.Pod == info.Pod {
+			pods++
+		}
+	}
+	r.lock.RUnlock()
+	if limits.MaxPerUser > 0 && users >= limits.MaxPerUser {
+		return capError(info, limits, 1, users)
+	}
+	if limits.MaxPerPod > 0 && pods >= limits.MaxPerPod {
+		return capError(info, limits, 2, pods)
+	}
+	return nil
+}
+
+// capError returns the error of the cap the session is over, full is 1 for
+// the user, 2 for the pod and 0 if the session is admitted.
+func capError(info *Info, limits *Limits, full, n int) error {
+	switch full {
+	case 1:
+		return errors.Errorf("user %s already has %d running sessions, at most %d are allowed", info.User, n, limits.MaxPerUser)
+	case 2:
+		return errors.Errorf("pod %s already has %d running sessions, at most %d are allowed", info.Pod, n, limits.MaxPerPod)
+	}
+	return nil
+}
+
+// touchSlots keeps the slots of a running session from going stale.
+func (r *Registry) touchSlots(info *Info) {
+	score := float64(unixMillis(time.Now()))
+	for _, key := range slotKeys(info) {
+		if err := r.redisClient.ZAdd(key, score, info.Id); err != nil {
+			log.Logger.Warnf("failed to refresh session slot %s of %s, err=%s", key, info.Id, err.Error())
+			continue
+		}
+		r.redisClient.Expire(key, staleAfter)
+	}
+}
+
+func (r *Registry) releaseSlots(info *Info) {
+	for _, key := range slotKeys(info) {
+		if err := r.redisClient.ZRem(key, info.Id); err != nil {
+			log.Logger.Warnf("failed to release session slot %s of %s, err=%s", key, info.Id, err.Error())
+		}
+	}
+}
+
+func slotKeys(info *Info) []string {
+	return []string{keys.LiveSessionSlotsKey("user", info.User), keys.LiveSessionSlotsKey("pod", info.Pod)}
+}
+
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// watchdog closes the session once it is idle or runs for too long, and
+// warns the user before.
+func (s *Session) watchdog(limits *Limits) {
+	if limits.IdleTimeout <= 0 && limits.MaxDuration <= 0 {
+		return
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	var idleWarned, durationWarned bool
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.lock.Lock()
+			start, last := s.info.Start, s.info.LastActive
+			s.lock.Unlock()
+
+			if limits.MaxDuration > 0 {
+				left := limits.MaxDuration - now.Sub(start)
+				if left <= 0 {
+					s.Kill(fmt.Sprintf("the session is closed as it reached the maximum duration of %s", limits.MaxDuration))
+					return
+				}
+				if !durationWarned && left <= limits.Warning {
+					durationWarned = true
+					s.notify(fmt.Sprintf("the session will be closed in %s as it reaches the maximum duration of %s",
+						left.Round(time.Second), limits.MaxDuration))
+				}
+			}
+			if limits.IdleTimeout > 0 {
+				idle := now.Sub(last)
+				if idle >= limits.IdleTimeout {
+					s.Kill(fmt.Sprintf("the session is closed after %s without input", limits.IdleTimeout))
+					return
+				}
+				if idle < limits.IdleTimeout-limits.Warning {
+					idleWarned = false
+				} else if !idleWarned {
+					idleWarned = true
+					s.notify(fmt.Sprintf("the session will be closed in %s without input, press any key to keep it",
+						(limits.IdleTimeout - idle).Round(time.Second)))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,536 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package sessions
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	refreshInterval = 10 * time.Second
+	staleAfter      = 3 * refreshInterval
+	watchBuffer     = 256
+
+	opKill    = "kill"
+	opWatch   = "watch"
+	opUnwatch = "unwatch"
+	opEnd     = "end"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Info describes a running session. Idle is the seconds since the last input
+// of the user, the byte counts are the input and output of the terminal.
+type Info struct {
+	Id         string    `json:"id"`
+	Node       string    `json:"node"`
+	Action     string    `json:"action"`
+	User       string    `json:"user"`
+	SourceIP   string    `json:"sourceIp"`
+	Cluster    string    `json:"cluster"`
+	Rule       string    `json:"rule"`
+	Namespace  string    `json:"namespace"`
+	Pod        string    `json:"pod"`
+	Container  string    `json:"container"`
+	Start      time.Time `json:"start"`
+	LastActive time.Time `json:"lastActive"`
+	Idle       int64     `json:"idle"`
+	BytesIn    int64     `json:"bytesIn"`
+	BytesOut   int64     `json:"bytesOut"`
+	Updated    time.Time `json:"updated"`
+}
+
+// Terminal is the terminal of the user of a session.
+type Terminal interface {
+	// Notify shows the message to the user.
+	Notify(message string)
+	// Kill shows the message to the user and ends the session.
+	Kill(message string)
+}
+
+type control struct {
+	Op      string `json:"op"`
+	Id      string `json:"id"`
+	Message string `json:"message,omitempty"`
+}
+
+// Session is a running session of this instance. Its methods do nothing on a
+// nil session, so sessions which are not registered need no checks.
+type Session struct {
+	registry *Registry
+
+	lock     sync.Mutex
+	info     Info
+	terminal Terminal
+	watchers map[int]chan []byte
+	nextId   int
+	remote   int
+	closed   bool
+	done     chan struct{}
+}
+
+// Attach sets the terminal the session is controlled by.
+func (s *Session) Attach(t Terminal) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.terminal = t
+}
+
+func (s *Session) Kill(message string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	t := s.terminal
+	s.lock.Unlock()
+	if t == nil {
+		log.Logger.Warnf("session %s can not be killed", s.info.Id)
+		return
+	}
+	log.Logger.Infof("kill session %s, message=%s", s.info.Id, message)
+	t.Kill(message)
+}
+
+func (s *Session) notify(message string) {
+	s.lock.Lock()
+	t := s.terminal
+	s.lock.Unlock()
+	if t != nil {
+		t.Notify(message)
+	}
+}
+
+// Input counts n bytes of input of the user.
+func (s *Session) Input(n int) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.info.BytesIn += int64(n)
+	s.info.LastActive = time.Now()
+}
+
+// Output counts the output sent to the user and copies it to the watchers. A
+// watcher which does not keep up misses output.
+func (s *Session) Output(data []byte) {
+	if s == nil || len(data) == 0 {
+		return
+	}
+	s.lock.Lock()
+	s.info.BytesOut += int64(len(data))
+	out := append([]byte{}, data...)
+	for _, w := range s.watchers {
+		select {
+		case w <- out:
+		default:
+		}
+	}
+	remote := s.remote > 0
+	s.lock.Unlock()
+
+	if remote {
+		s.registry.publish(keys.LiveSessionOutputChannel(s.info.Id), string(out))
+	}
+}
+
+// Close removes the session from the registry and ends its watches.
+func (s *Session) Close() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	for id, w := range s.watchers {
+		close(w)
+		delete(s.watchers, id)
+	}
+	remote := s.remote > 0
+	s.lock.Unlock()
+
+	s.registry.remove(s)
+	if remote {
+		s.registry.publishControl(&control{Op: opEnd, Id: s.info.Id})
+	}
+}
+
+func (s *Session) snapshot() *Info {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	info := s.info
+	info.Idle = int64(time.Since(info.LastActive) / time.Second)
+	info.Updated = time.Now()
+	return &info
+}
+
+func (s *Session) watch() (<-chan []byte, func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ch := make(chan []byte, watchBuffer)
+	if s.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	id := s.nextId
+	s.nextId++
+	s.watchers[id] = ch
+	return ch, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if w, ok := s.watchers[id]; ok {
+			delete(s.watchers, id)
+			close(w)
+		}
+	}
+}
+
+func (s *Session) addRemoteWatcher(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.remote += n; s.remote < 0 {
+		s.remote = 0
+	}
+}
+
+// Registry keeps the running sessions of this instance and shares them with
+// the other instances through redis. Kills and watches of a session of
+// another instance are relayed by redis pub/sub, the instance of the session
+// publishes its output only while it is watched from another instance.
+type Registry struct {
+	redisClient *redis.RedisClient
+	node        string
+
+	limits    func(rule string) *Limits
+	admitLock sync.Mutex
+
+	lock      sync.RWMutex
+	sessions  map[string]*Session
+	relays    map[string]map[int]func()
+	nextRelay int
+}
+
+func NewRegistry(rc *redis.RedisClient) *Registry {
+	node, _ := os.Hostname()
+	return &Registry{
+		redisClient: rc,
+		node:        node,
+		limits:      LimitsForRule,
+		sessions:    make(map[string]*Session),
+		relays:      make(map[string]map[int]func()),
+	}
+}
+
+// Start follows the kills and watches of other instances, and refreshes the
+// sessions of this instance in redis until stop is closed.
+func (r *Registry) Start(stop chan struct{}) {
+	if r.redisClient == nil {
+		return
+	}
+	ps := r.redisClient.Subscribe(keys.LiveSessionControlChannel())
+	go func() {
+		defer ps.Close()
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-stop:
+				log.Logger.Infof("exit live session subscriber")
+				return
+			case <-ticker.C:
+				r.refresh()
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				r.handle(msg.Payload)
+			}
+		}
+	}()
+}
+
+// Register adds a session unless it exceeds the limits of its rule, info.Id
+// is the traceId of the request which opened it. The session is closed when
+// it is idle or runs longer than the limits allow.
+func (r *Registry) Register(info *Info) (*Session, error) {
+	limits := r.limits(info.Rule)
+	r.admitLock.Lock()
+	defer r.admitLock.Unlock()
+	if err := r.admit(info, limits); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &Session{registry: r, info: *info, watchers: make(map[int]chan []byte), done: make(chan struct{})}
+	s.info.Node, s.info.Start, s.info.LastActive = r.node, now, now
+
+	r.lock.Lock()
+	r.sessions[info.Id] = s
+	r.lock.Unlock()
+	r.save(s)
+	go s.watchdog(limits)
+	return s, nil
+}
+
+// List returns the running sessions of every instance, the oldest first.
+func (r *Registry) List() []*Info {
+	all := make(map[string]*Info)
+	if r.redisClient != nil {
+		data, err := r.redisClient.HGetAll(keys.LiveSessionsKey())
+		if err != nil {
+			log.Logger.Warnf("failed to load live sessions, err=%s", err.Error())
+		}
+		for id, d := range data {
+			info := &Info{}
+			if err := jsoniter.UnmarshalFromString(d, info); err != nil {
+				log.Logger.Warnf("invalid live session %s", d)
+				continue
+			}
+			if time.Since(info.Updated) > staleAfter {
+				// the instance of the session is gone
+				r.redisClient.HDel(keys.LiveSessionsKey(), id)
+				continue
+			}
+			info.Idle = int64(time.Since(info.LastActive) / time.Second)
+			all[id] = info
+		}
+	}
+
+	r.lock.RLock()
+	for id, s := range r.sessions {
+		all[id] = s.snapshot()
+	}
+	r.lock.RUnlock()
+
+	list := make([]*Info, 0, len(all))
+	for _, info := range all {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Start.Before(list[j].Start)
+	})
+	return list
+}
+
+// Kill ends the session on whichever instance it runs.
+func (r *Registry) Kill(id, message string) error {
+	if s := r.local(id); s != nil {
+		s.Kill(message)
+		return nil
+	}
+	if !r.exists(id) {
+		return ErrNotFound
+	}
+	return r.publishControl(&control{Op: opKill, Id: id, Message: message})
+}
+
+// Watch returns the output the session sends to its user from now on. The
+// channel is closed when the session ends or cancel is called.
+func (r *Registry) Watch(id string) (output <-chan []byte, cancel func(), err error) {
+	if s := r.local(id); s != nil {
+		output, cancel = s.watch()
+		return output, cancel, nil
+	}
+	if !r.exists(id) {
+		return nil, nil, ErrNotFound
+	}
+	return r.relay(id)
+}
+
+func (r *Registry) relay(id string) (<-chan []byte, func(), error) {
+	ps := r.redisClient.Subscribe(keys.LiveSessionOutputChannel(id))
+	if _, err := ps.Receive(); err != nil {
+		ps.Close()
+		return nil, nil, err
+	}
+	if err := r.publishControl(&control{Op: opWatch, Id: id}); err != nil {
+		ps.Close()
+		return nil, nil, err
+	}
+
+	var (
+		ch   = make(chan []byte, watchBuffer)
+		stop = make(chan struct{})
+		once sync.Once
+	)
+	r.lock.Lock()
+	n := r.nextRelay
+	r.nextRelay++
+	cancel := func() {
+		once.Do(func() {
+			close(stop)
+			r.lock.Lock()
+			delete(r.relays[id], n)
+			if len(r.relays[id]) == 0 {
+				delete(r.relays, id)
+			}
+			r.lock.Unlock()
+		})
+	}
+	if r.relays[id] == nil {
+		r.relays[id] = make(map[int]func())
+	}
+	r.relays[id][n] = cancel
+	r.lock.Unlock()
+
+	go func() {
+		defer close(ch)
+		defer ps.Close()
+		defer r.publishControl(&control{Op: opUnwatch, Id: id})
+		msgs := ps.Channel()
+		for {
+			select {
+			case <-stop:
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case ch <- []byte(msg.Payload):
+				default:
+				}
+			}
+		}
+	}()
+	return ch, cancel, nil
+}
+
+func (r *Registry) handle(payload string) {
+	c := &control{}
+	if err := jsoniter.UnmarshalFromString(payload, c); err != nil {
+		log.Logger.Warnf("invalid live session control %s", payload)
+		return
+	}
+	if c.Op == opEnd {
+		r.lock.RLock()
+		cancels := make([]func(), 0, len(r.relays[c.Id]))
+		for _, cancel := range r.relays[c.Id] {
+			cancels = append(cancels, cancel)
+		}
+		r.lock.RUnlock()
+		for _, cancel := range cancels {
+			cancel()
+		}
+		return
+	}
+
+	s := r.local(c.Id)
+	if s == nil {
+		return
+	}
+	switch c.Op {
+	case opKill:
+		s.Kill(c.Message)
+	case opWatch:
+		s.addRemoteWatcher(1)
+	case opUnwatch:
+		s.addRemoteWatcher(-1)
+	}
+}
+
+func (r *Registry) local(id string) *Session {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.sessions[id]
+}
+
+func (r *Registry) exists(id string) bool {
+	for _, info := range r.List() {
+		if info.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) remove(s *Session) {
+	r.lock.Lock()
+	if r.sessions[s.info.Id] == s {
+		delete(r.sessions, s.info.Id)
+	}
+	r.lock.Unlock()
+	if r.redisClient == nil {
+		return
+	}
+	if err := r.redisClient.HDel(keys.LiveSessionsKey(), s.info.Id); err != nil {
+		log.Logger.Warnf("failed to remove live session %s, err=%s", s.info.Id, err.Error())
+	}
+	r.releaseSlots(&s.info)
+}
+
+func (r *Registry) refresh() {
+	r.lock.RLock()
+	list := make([]*Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		list = append(list, s)
+	}
+	r.lock.RUnlock()
+	for _, s := range list {
+		r.save(s)
+		r.touchSlots(&s.info)
+	}
+}
+
+func (r *Registry) save(s *Session) {
+	if r.redisClient == nil {
+		return
+	}
+	info := s.snapshot()
+	data, err := jsoniter.MarshalToString(info)
+	if err != nil {
+		return
+	}
+	if err := r.redisClient.HSet(keys.LiveSessionsKey(), info.Id, data); err != nil {
+		log.Logger.Warnf("failed to save live session %s, err=%s", info.Id, err.Error())
+	}
+}
+
+func (r *Registry) publishControl(c *control) error {
+	data, err := jsoniter.MarshalToString(c)
+	if err != nil {
+		return err
+	}
+	return r.publish(keys.LiveSessionControlChannel(), data)
+}
+
+func (r *Registry) publish(channel, message string) error {
+	if r.redisClient == nil {
+		return nil
+	}
+	if err := r.redisClient.Publish(channel, message); err != nil {
+		log.Logger.Warnf("failed to publish to %s, err=%s", channel, err.Error())
+		return err
+	}
+	return nil
+}
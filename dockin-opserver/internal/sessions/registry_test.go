@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package sessions
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTerminal struct {
+	lock     sync.Mutex
+	notified []string
+	killed   []string
+}
+
+func (t *fakeTerminal) Notify(message string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.notified = append(t.notified, message)
+}
+
+func (t *fakeTerminal) Kill(message string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.killed = append(t.killed, message)
+}
+
+func (t *fakeTerminal) messages() ([]string, []string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string{}, t.notified...), append([]string{}, t.killed...)
+}
+
+func newTestRegistry(limits *Limits) *Registry {
+	r := NewRegistry(nil)
+	r.limits = func(rule string) *Limits {
+		return limits
+	}
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	r := newTestRegistry(&Limits{})
+	s, err := r.Register(&Info{Id: "t1", User: "alice", Pod: "web-0"})
+	assert.NoError(t, err)
+	term := &fakeTerminal{}
+	s.Attach(term)
+
+	out, cancel, err := r.Watch("t1")
+	assert.NoError(t, err)
+	s.Input(3)
+	s.Output([]byte("ls\r\n"))
+	assert.Equal(t, "ls\r\n", string(<-out))
+
+	list := r.List()
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "alice", list[0].User)
+		assert.Equal(t, int64(3), list[0].BytesIn)
+		assert.Equal(t, int64(4), list[0].BytesOut)
+		assert.Equal(t, int64(0), list[0].Idle)
+	}
+
+	cancel()
+	_, ok := <-out
+	assert.False(t, ok)
+
+	assert.NoError(t, r.Kill("t1", "bye"))
+	r.handle(`{"op":"kill","id":"t1","message":"bye again"}`)
+	assert.Equal(t, []string{"bye", "bye again"}, term.killed)
+	assert.Equal(t, ErrNotFound, r.Kill("t2", "bye"))
+
+	out, _, err = r.Watch("t1")
+	assert.NoError(t, err)
+	s.Close()
+	select {
+	case _, ok = <-out:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("watch is not closed with the session")
+	}
+	assert.Empty(t, r.List())
+	_, _, err = r.Watch("t1")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRemoteWatcher(t *testing.T) {
+	r := newTestRegistry(&Limits{})
+	s, err := r.Register(&Info{Id: "t1"})
+	assert.NoError(t, err)
+	r.handle(`{"op":"watch","id":"t1"}`)
+	assert.Equal(t, 1, s.remote)
+	r.handle(`{"op":"unwatch","id":"t1"}`)
+	r.handle(`{"op":"unwatch","id":"t1"}`)
+	assert.Equal(t, 0, s.remote)
+
+	var cancelled bool
+	r.relays["t2"] = map[int]func(){0: func() { cancelled = true }}
+	r.handle(`{"op":"end","id":"t2"}`)
+	assert.True(t, cancelled)
+}
+
+func TestLimits(t *testing.T) {
+	r := newTestRegistry(&Limits{MaxPerUser: 2, MaxPerPod: 1})
+	_, err := r.Register(&Info{Id: "t1", User: "alice", Pod: "web-0"})
+	assert.NoError(t, err)
+	_, err = r.Register(&Info{Id: "t2", User: "bob", Pod: "web-0"})
+	assert.EqualError(t, err, "pod web-0 already has 1 running sessions, at most 1 are allowed")
+	s, err := r.Register(&Info{Id: "t3", User: "alice", Pod: "web-1"})
+	assert.NoError(t, err)
+	_, err = r.Register(&Info{Id: "t4", User: "alice", Pod: "web-2"})
+	assert.EqualError(t, err, "user alice already has 2 running sessions, at most 2 are allowed")
+	s.Close()
+	_, err = r.Register(&Info{Id: "t4", User: "alice", Pod: "web-2"})
+	assert.NoError(t, err)
+}
+
+func TestLimitsShared(t *testing.T) {
+	rc, err := redis.NewRedisClient()
+	assert.NoError(t, err)
+	if err := rc.Client.Ping().Err(); err != nil {
+		t.Skipf("redis is unavailable, err=%s", err.Error())
+	}
+	info := &Info{User: "dockin_test_user", Pod: "dockin-test-pod"}
+	for _, key := range slotKeys(info) {
+		rc.Del(key)
+		defer rc.Del(key)
+	}
+
+	// instances opening sessions at once share the caps
+	limits := &Limits{MaxPerUser: 5, MaxPerPod: 3}
+	registries := []*Registry{NewRegistry(rc), NewRegistry(rc)}
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		admitted []*Session
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := registries[i%2]
+			r.limits = func(rule string) *Limits { return limits }
+			s, err := r.Register(&Info{Id: fmt.Sprintf("dockin-test-%d", i), User: info.User, Pod: info.Pod})
+			if err == nil {
+				lock.Lock()
+				admitted = append(admitted, s)
+				lock.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, admitted, 3)
+
+	admitted[0].Close()
+	s, err := registries[1].Register(&Info{Id: "dockin-test-next", User: info.User, Pod: info.Pod})
+	assert.NoError(t, err)
+	_, err = registries[0].Register(&Info{Id: "dockin-test-over", User: info.User, Pod: info.Pod})
+	assert.Error(t, err)
+	s.Close()
+	for _, s := range admitted[1:] {
+		s.Close()
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	checkInterval = 10 * time.Millisecond
+	defer func() {
+		checkInterval = time.Second
+	}()
+
+	r := newTestRegistry(&Limits{IdleTimeout: 200 * time.Millisecond, Warning: 100 * time.Millisecond})
+	s, err := r.Register(&Info{Id: "t1"})
+	assert.NoError(t, err)
+	term := &fakeTerminal{}
+	s.Attach(term)
+	assert.Eventually(t, func() bool {
+		notified, _ := term.messages()
+		return len(notified) == 1
+	}, time.Second, 5*time.Millisecond)
+	s.Input(1)
+	assert.Eventually(t, func() bool {
+		_, killed := term.messages()
+		return len(killed) == 1
+	}, time.Second, 5*time.Millisecond)
+	notified, killed := term.messages()
+	assert.Len(t, notified, 2)
+	assert.Contains(t, notified[0], "without input")
+	assert.Equal(t, "the session is closed after 200ms without input", killed[0])
+
+	r = newTestRegistry(&Limits{MaxDuration: 100 * time.Millisecond, Warning: 50 * time.Millisecond})
+	s, err = r.Register(&Info{Id: "t2"})
+	assert.NoError(t, err)
+	term = &fakeTerminal{}
+	s.Attach(term)
+	assert.Eventually(t, func() bool {
+		_, killed := term.messages()
+		return len(killed) == 1
+	}, time.Second, 5*time.Millisecond)
+	notified, killed = term.messages()
+	assert.Len(t, notified, 1)
+	assert.Contains(t, killed[0], "maximum duration of 100ms")
+	s.Close()
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package token
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/cache/keys"
+	"github.com/webankfintech/dockin-opserver/internal/cache/redis"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrRevoked = errors.New("access token has been revoked, try to relogin")
+)
+
+func Revoke(rc *redis.RedisClient, tokenId string, expireAt time.Time) error {
+	ttl := time.Until(expireAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return rc.Set(keys.RevokedTokenKey(tokenId), "1", ttl)
+}
+
+func RevokeUser(rc *redis.RedisClient, userName string) error {
+	return rc.Set(keys.UserTokenNotBeforeKey(userName),
+		strconv.FormatInt(time.Now().UnixNano(), 10), time.Duration(Expire())*time.Second)
+}
+
+func CheckRevoked(rc *redis.RedisClient, tokenId, userName string, issuedAt time.Time) error {
+	if rc == nil {
+		return errors.New("revocation list is unavailable")
+	}
+
+	revoked, err := rc.Exists(keys.RevokedTokenKey(tokenId))
+	if err != nil {
+		return errors.Errorf("check token revocation failed, err=%s", err.Error())
+	}
+	if revoked {
+		return ErrRevoked
+	}
+
+	nbf, err := rc.Get(keys.UserTokenNotBeforeKey(userName))
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return errors.Errorf("check user token revocation failed, err=%s", err.Error())
+	}
+	ns, err := strconv.ParseInt(nbf.(string), 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid user token revocation, err=%s", err.Error())
+	}
+	if issuedAt.UnixNano() <= ns {
+		return ErrRevoked
+	}
+	return nil
+}
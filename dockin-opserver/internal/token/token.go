@@ -0,0 +1,223 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webankfintech/dockin-opserver/internal/config"
+	"github.com/webankfintech/dockin-opserver/internal/log"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	timeLayout = time.RFC3339
+	separator  = "."
+)
+
+var (
+	ErrNoSigningKey = fmt.Errorf("no active token signing key, check token keys in application.yaml")
+	ErrMalformed    = fmt.Errorf("access token is malformed")
+	ErrUnknownKey   = fmt.Errorf("access token is signed by an unknown or retired key")
+	ErrSignature    = fmt.Errorf("access token signature is invalid")
+)
+
+type Key struct {
+	Id        string
+	Secret    string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+func (k *Key) usable(now time.Time) bool {
+	if !k.NotBefore.IsZero() && now.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && !now.Before(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// Keyring holds the server side HMAC keys. The newest usable key signs new
+// tokens while every usable key is accepted on verify, so a rotated key keeps
+// validating tokens until its not-after passes.
+type Keyring struct {
+	keys []*Key
+}
+
+func NewKeyring(keys []*Key) (*Keyring, error) {
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.Id == "" || strings.Contains(k.Id, separator) {
+			return nil, errors.Errorf("invalid token key id [%s]", k.Id)
+		}
+		if k.Secret == "" {
+			return nil, errors.Errorf("token key [%s] has an empty secret", k.Id)
+		}
+		if seen[k.Id] {
+			return nil, errors.Errorf("duplicate token key id [%s]", k.Id)
+		}
+		seen[k.Id] = true
+	}
+
+	sorted := make([]*Key, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].NotBefore.After(sorted[j].NotBefore)
+	})
+	return &Keyring{keys: sorted}, nil
+}
+
+func (kr *Keyring) signingKey(now time.Time) (*Key, error) {
+	for _, k := range kr.keys {
+		if k.usable(now) {
+			return k, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+func (kr *Keyring) verifyKey(id string, now time.Time) (*Key, error) {
+	for _, k := range kr.keys {
+		if k.Id == id {
+			if !k.usable(now) {
+				return nil, ErrUnknownKey
+			}
+			return k, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Sign returns <keyId>.<payload>.<signature>, payload is the base64 encoded
+// json of claims and signature is HMAC-SHA256 over the first two parts.
+func (kr *Keyring) Sign(claims interface{}) (string, error) {
+	key, err := kr.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := jsoniter.Marshal(claims)
+	if err != nil {
+		return "", errors.Errorf("marshal token claims failed, err=%s", err.Error())
+	}
+
+	signed := key.Id + separator + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + separator + sign(key.Secret, signed), nil
+}
+
+func (kr *Keyring) Verify(token string, claims interface{}) error {
+	parts := strings.Split(token, separator)
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+
+	key, err := kr.verifyKey(parts[0], time.Now())
+	if err != nil {
+		return err
+	}
+
+	signed := parts[0] + separator + parts[1]
+	if !hmac.Equal([]byte(sign(key.Secret, signed)), []byte(parts[2])) {
+		return ErrSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := jsoniter.Unmarshal(payload, claims); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+func sign(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	keyring *Keyring
+	mu      sync.RWMutex
+)
+
+func init() {
+	kr, err := LoadKeyring()
+	if err != nil {
+		log.Logger.Warnf("load token keys failed, no access token can be signed, err=%s", err.Error())
+		kr = &Keyring{}
+	}
+	SetKeyring(kr)
+}
+
+func LoadKeyring() (*Keyring, error) {
+	var keys []*Key
+	for _, k := range config.OpsConfig.Token.Keys {
+		key := &Key{Id: k.Id, Secret: k.Secret}
+		if k.NotBefore != "" {
+			t, err := time.Parse(timeLayout, k.NotBefore)
+			if err != nil {
+				return nil, errors.Errorf("invalid not-before of token key [%s], err=%s", k.Id, err.Error())
+			}
+			key.NotBefore = t
+		}
+		if k.NotAfter != "" {
+			t, err := time.Parse(timeLayout, k.NotAfter)
+			if err != nil {
+				return nil, errors.Errorf("invalid not-after of token key [%s], err=%s", k.Id, err.Error())
+			}
+			key.NotAfter = t
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyring(keys)
+}
+
+func SetKeyring(kr *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	keyring = kr
+}
+
+func Sign(claims interface{}) (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return keyring.Sign(claims)
+}
+
+func Verify(token string, claims interface{}) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	return keyring.Verify(token, claims)
+}
+
+func Expire() int64 {
+	if config.OpsConfig.Token.Expire > 0 {
+		return config.OpsConfig.Token.Expire
+	}
+	return 3600
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) @2021 Webank Group Holding Limited
+ * <p>
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ * <p>
+ * http://www.apache.org/licenses/LICENSE-2.0
+ * <p>
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type claims struct {
+	UserName string `json:"userName"`
+}
+
+func TestSignAndVerify(t *testing.T) {
+	kr, err := NewKeyring([]*Key{{Id: "k1", Secret: "secret-1"}})
+	assert.NoError(t, err)
+
+	tk, err := kr.Sign(&claims{UserName: "bruceliu"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tk, "k1."))
+
+	c := &claims{}
+	assert.NoError(t, kr.Verify(tk, c))
+	assert.Equal(t, "bruceliu", c.UserName)
+}
+
+func TestVerifyTampered(t *testing.T) {
+	kr, _ := NewKeyring([]*Key{{Id: "k1", Secret: "secret-1"}})
+	tk, _ := kr.Sign(&claims{UserName: "bruceliu"})
+	parts := strings.Split(tk, ".")
+
+	forged, _ := kr.Sign(&claims{UserName: "root"})
+	fparts := strings.Split(forged, ".")
+	assert.Equal(t, ErrSignature, kr.Verify(parts[0]+"."+fparts[1]+"."+parts[2], &claims{}))
+
+	other, _ := NewKeyring([]*Key{{Id: "k1", Secret: "secret-2"}})
+	assert.Equal(t, ErrSignature, other.Verify(tk, &claims{}))
+
+	assert.Equal(t, ErrMalformed, kr.Verify("k1.abc", &claims{}))
+	assert.Equal(t, ErrUnknownKey, kr.Verify("k9."+parts[1]+"."+parts[2], &claims{}))
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Now()
+	old := &Key{Id: "k1", Secret: "secret-1", NotBefore: now.Add(-48 * time.Hour)}
+	kr, _ := NewKeyring([]*Key{old})
+	oldToken, _ := kr.Sign(&claims{UserName: "bruceliu"})
+
+	old.NotAfter = now.Add(time.Hour)
+	kr, err := NewKeyring([]*Key{old, {Id: "k2", Secret: "secret-2", NotBefore: now.Add(-time.Minute)}})
+	assert.NoError(t, err)
+
+	newToken, _ := kr.Sign(&claims{UserName: "bruceliu"})
+	assert.True(t, strings.HasPrefix(newToken, "k2."))
+	assert.NoError(t, kr.Verify(oldToken, &claims{}))
+	assert.NoError(t, kr.Verify(newToken, &claims{}))
+
+	old.NotAfter = now.Add(-time.Second)
+	assert.Equal(t, ErrUnknownKey, kr.Verify(oldToken, &claims{}))
+	assert.NoError(t, kr.Verify(newToken, &claims{}))
+}
+
+func TestPendingKeyNotUsed(t *testing.T) {
+	now := time.Now()
+	kr, _ := NewKeyring([]*Key{
+		{Id: "k1", Secret: "secret-1", NotBefore: now.Add(-time.Hour)},
+		{Id: "k2", Secret: "secret-2", NotBefore: now.Add(time.Hour)},
+	})
+	tk, err := kr.Sign(&claims{})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tk, "k1."))
+
+	empty, _ := NewKeyring(nil)
+	_, err = empty.Sign(&claims{})
+	assert.Equal(t, ErrNoSigningKey, err)
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	_, err := NewKeyring([]*Key{{Id: "k1"}})
+	assert.Error(t, err)
+	_, err = NewKeyring([]*Key{{Id: "k.1", Secret: "s"}})
+	assert.Error(t, err)
+	_, err = NewKeyring([]*Key{{Id: "k1", Secret: "s"}, {Id: "k1", Secret: "t"}})
+	assert.Error(t, err)
+}